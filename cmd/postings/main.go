@@ -2,13 +2,17 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"harshagw/postings/internal/index"
+	"harshagw/postings/internal/mapping"
+	"harshagw/postings/internal/query"
 	"harshagw/postings/internal/search"
 
 	"github.com/c-bata/go-prompt"
@@ -21,12 +25,28 @@ type REPL struct {
 }
 
 func main() {
+	mappingPath := flag.String("mapping", "", "JSON field mapping applied when the index is created")
+	flag.Parse()
+
 	fmt.Println("Postings Search Engine REPL")
 	fmt.Println()
 	printHelp()
 	fmt.Println()
 
 	config := index.DefaultConfig(IndexDir)
+	if *mappingPath != "" {
+		data, err := os.ReadFile(*mappingPath)
+		if err != nil {
+			fmt.Printf("Error reading mapping: %v\n", err)
+			os.Exit(1)
+		}
+		config.Mapping, err = mapping.Parse(data, config.Analyzer)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	idx, err := index.New(config)
 	if err != nil {
 		fmt.Printf("Error opening index: %v\n", err)
@@ -52,17 +72,27 @@ func printHelp() {
 	fmt.Println("  delete <docID>             - Delete document")
 	fmt.Println("  flush                      - Flush to segment")
 	fmt.Println("  merge                      - Merge all segments")
+	fmt.Println("  merges                     - Show background merges")
+	fmt.Println("  mapping                    - Show field mapping")
 	fmt.Println()
-	fmt.Println("  search <query>             - Search with query syntax:")
+	fmt.Println("  search [-from N] [-size N] <query> - Search with query syntax:")
 	fmt.Println("    term                     - Single term search")
 	fmt.Println("    field:term               - Field-specific search")
 	fmt.Println("    \"exact phrase\"           - Phrase search")
+	fmt.Println("    \"quick fox\"~2            - Phrase with terms up to 2 moves apart")
+	fmt.Println("    quick NEAR/3 fox         - Terms in any order, at most 3 words apart")
 	fmt.Println("    term1 AND term2          - Both must match")
 	fmt.Println("    term1 OR term2           - Either matches")
 	fmt.Println("    term1 -term2             - Exclude term2")
 	fmt.Println("    (a OR b) AND c           - Grouping")
 	fmt.Println("    term*                    - Prefix search")
+	fmt.Println("    field:[10 TO 100]        - Numeric range (also field:>N, field:<=N)")
+	fmt.Println("    field:[2024-01-01 TO now-7d] - Date range")
+	fmt.Println("    title:go^3               - Boost a clause's score")
 	fmt.Println()
+	fmt.Println("  multimatch [-type T] [-tie N] <fields> <text> - Search text in several fields,")
+	fmt.Println("                             e.g. multimatch title^3,body quick fox")
+	fmt.Println("  explain <docID> <query>    - Show how a document scores")
 	fmt.Println("  segments                   - List segments")
 	fmt.Println("  segment <id> stats         - Segment details")
 	fmt.Println("  doc <segment> <docNum>     - Load document")
@@ -92,8 +122,16 @@ func (r *REPL) executor(input string) {
 		r.cmdFlush()
 	case "merge":
 		r.cmdMerge()
+	case "merges":
+		r.cmdMerges()
+	case "mapping":
+		r.cmdMapping()
 	case "search":
 		r.cmdSearch(input)
+	case "multimatch":
+		r.cmdMultiMatch(input)
+	case "explain":
+		r.cmdExplain(input)
 	case "segments":
 		r.cmdSegments()
 	case "segment":
@@ -212,7 +250,7 @@ func (r *REPL) cmdIndexFile(args []string) {
 		fmt.Printf(" (%d errors)", errors)
 	}
 	fmt.Println()
-	fmt.Printf("Use 'flush' to persist to disk.\n")
+	fmt.Printf("Use 'flush' to write them to a segment.\n")
 }
 
 func (r *REPL) cmdDelete(args []string) {
@@ -245,12 +283,57 @@ func (r *REPL) cmdMerge() {
 	fmt.Printf("Merged. %d segments.\n", r.idx.NumSegments())
 }
 
+func (r *REPL) cmdMerges() {
+	stats := r.idx.MergeStats()
+	fmt.Printf("Merges: %d running, %d pending, %d completed, %d failed\n",
+		len(stats.Running), len(stats.Pending), stats.Completed, stats.Failed)
+	for _, m := range stats.Running {
+		fmt.Printf("  #%d running %v (%d bytes, %v)\n", m.ID, m.Segments, m.SizeBytes, time.Since(m.Started).Round(time.Millisecond))
+	}
+	for _, m := range stats.Pending {
+		fmt.Printf("  #%d pending %v (%d bytes)\n", m.ID, m.Segments, m.SizeBytes)
+	}
+	if stats.LastError != nil {
+		fmt.Printf("Last error: %v\n", stats.LastError)
+	}
+}
+
+func (r *REPL) cmdMapping() {
+	data, err := json.MarshalIndent(r.idx.Mapping(), "", "  ")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Println(string(data))
+}
+
 func (r *REPL) cmdSearch(input string) {
-	query := strings.TrimPrefix(input, "search")
-	query = strings.TrimSpace(query)
+	queryString := strings.TrimPrefix(input, "search")
+	queryString = strings.TrimSpace(queryString)
+
+	// Leading -from N and -size N select the page of hits.
+	req := search.SearchRequest{Highlight: &search.HighlightOptions{PreTag: "\033[1m", PostTag: "\033[0m"}}
+	for {
+		opt, rest, _ := strings.Cut(queryString, " ")
+		if opt != "-from" && opt != "-size" {
+			break
+		}
+		valueStr, rest, _ := strings.Cut(strings.TrimSpace(rest), " ")
+		value, err := strconv.Atoi(valueStr)
+		if err != nil {
+			fmt.Printf("Invalid %s: %v\n", opt, err)
+			return
+		}
+		if opt == "-from" {
+			req.From = value
+		} else {
+			req.Size = value
+		}
+		queryString = strings.TrimSpace(rest)
+	}
 
-	if query == "" {
-		fmt.Println("Usage: search <query>")
+	if queryString == "" {
+		fmt.Println("Usage: search [-from N] [-size N] <query>")
 		fmt.Println("Examples:")
 		fmt.Println("  search hello")
 		fmt.Println("  search title:hello")
@@ -259,9 +342,73 @@ func (r *REPL) cmdSearch(input string) {
 		fmt.Println("  search hello OR world")
 		fmt.Println("  search hello -spam")
 		fmt.Println("  search hel*")
+		fmt.Println("  search price:[10 TO 100]")
+		fmt.Println("  search -from 10 -size 20 hello")
 		return
 	}
 
+	tokens, err := query.Tokenize(queryString)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	req.Query, err = query.Parse(tokens)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	r.runSearch(req, queryString)
+}
+
+func (r *REPL) cmdMultiMatch(input string) {
+	args := strings.TrimSpace(strings.TrimPrefix(input, "multimatch"))
+
+	// Leading -type T and -tie N select how fields combine.
+	q := &query.MultiMatchQuery{}
+	for {
+		opt, rest, _ := strings.Cut(args, " ")
+		if opt != "-type" && opt != "-tie" {
+			break
+		}
+		value, rest, _ := strings.Cut(strings.TrimSpace(rest), " ")
+		var err error
+		if opt == "-type" {
+			q.Type, err = query.ParseMultiMatchType(value)
+		} else {
+			q.TieBreaker, err = strconv.ParseFloat(value, 64)
+		}
+		if err != nil {
+			fmt.Printf("Invalid %s: %v\n", opt, err)
+			return
+		}
+		args = strings.TrimSpace(rest)
+	}
+
+	fields, text, _ := strings.Cut(args, " ")
+	text = strings.TrimSpace(text)
+	if text == "" {
+		fmt.Println("Usage: multimatch [-type best_fields|most_fields|cross_fields] [-tie N] <fields> <text>")
+		fmt.Println("Examples:")
+		fmt.Println("  multimatch title^3,body quick fox")
+		fmt.Println("  multimatch -type cross_fields first,last john smith")
+		return
+	}
+	var err error
+	q.Fields, err = query.ParseFieldBoosts(fields)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	q.Text = text
+
+	r.runSearch(search.SearchRequest{
+		Query:     q,
+		Highlight: &search.HighlightOptions{PreTag: "\033[1m", PostTag: "\033[0m"},
+	}, q.String())
+}
+
+// runSearch runs a request and prints its hits; label names the query.
+func (r *REPL) runSearch(req search.SearchRequest, label string) {
 	snap, err := r.idx.Snapshot()
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
@@ -270,26 +417,73 @@ func (r *REPL) cmdSearch(input string) {
 	defer snap.Close()
 
 	searcher := search.New(snap)
-	results, err := searcher.RunQueryString(query)
+	defer searcher.Close()
+	resp, err := searcher.Search(req)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
 
-	if len(results) == 0 {
-		fmt.Printf("No results for: %s\n", query)
+	if resp.Total == 0 {
+		fmt.Printf("No results for: %s\n", label)
 	} else {
-		fmt.Printf("Found %d results for: %s\n", len(results), query)
-		for _, res := range results {
+		fmt.Printf("Found %d results for: %s (showing %d from %d)\n", resp.Total, label, len(resp.Hits), req.From)
+		for _, res := range resp.Hits {
 			if len(res.MatchedTerms) > 0 {
 				fmt.Printf("  %s (%.4f) [%s]\n", res.DocID, res.Score, strings.Join(res.MatchedTerms, ", "))
 			} else {
 				fmt.Printf("  %s (%.4f)\n", res.DocID, res.Score)
 			}
+			fields := make([]string, 0, len(res.Fragments))
+			for field := range res.Fragments {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			for _, field := range fields {
+				for _, frag := range res.Fragments[field] {
+					fmt.Printf("      %s: %s\n", field, frag)
+				}
+			}
 		}
 	}
 }
 
+func (r *REPL) cmdExplain(input string) {
+	parts := strings.SplitN(input, " ", 3)
+	if len(parts) < 3 || strings.TrimSpace(parts[2]) == "" {
+		fmt.Println("Usage: explain <docID> <query>")
+		return
+	}
+	docID, queryString := parts[1], strings.TrimSpace(parts[2])
+
+	tokens, err := query.Tokenize(queryString)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	q, err := query.Parse(tokens)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	snap, err := r.idx.Snapshot()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	defer snap.Close()
+
+	searcher := search.New(snap)
+	defer searcher.Close()
+	expl, err := searcher.Explain(q, docID)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Print(expl)
+}
+
 func (r *REPL) cmdSegments() {
 	segs := r.idx.Segments()
 	if len(segs) == 0 {
@@ -9,6 +9,8 @@ require (
 	github.com/couchbase/vellum v1.0.2
 	github.com/edsrzf/mmap-go v1.2.0
 	github.com/golang/snappy v1.0.0
+	golang.org/x/text v0.21.0
+	golang.org/x/text v0.21.0
 )
 
 require (
@@ -5,9 +5,14 @@ import (
 	"unicode"
 )
 
+// TokenPosition is a token and where it occurred. Start and End are byte
+// offsets of the token's source text in the analyzed input, so a token that
+// filters have rewritten still points at the original text.
 type TokenPosition struct {
 	Token    string
 	Position uint64
+	Start    int
+	End      int
 }
 
 // Analyzer defines the interface for text analysis.
@@ -25,32 +30,77 @@ func NewSimple() *Simple {
 // Analyze tokenizes text into tokens with positions.
 func (a *Simple) Analyze(text string) []TokenPosition {
 	var tokens []TokenPosition
-	var currentToken strings.Builder
 	var position uint64
+	start := -1
 
-	text = strings.ToLower(text)
-
-	for _, r := range text {
+	for i, r := range text {
 		if unicode.IsLetter(r) || unicode.IsNumber(r) {
-			currentToken.WriteRune(r)
-		} else {
-			if currentToken.Len() > 0 {
-				tokens = append(tokens, TokenPosition{
-					Token:    currentToken.String(),
-					Position: position,
-				})
-				position++
-				currentToken.Reset()
+			if start < 0 {
+				start = i
 			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, TokenPosition{
+				Token:    strings.ToLower(text[start:i]),
+				Position: position,
+				Start:    start,
+				End:      i,
+			})
+			position++
+			start = -1
 		}
 	}
 
-	if currentToken.Len() > 0 {
+	if start >= 0 {
 		tokens = append(tokens, TokenPosition{
-			Token:    currentToken.String(),
+			Token:    strings.ToLower(text[start:]),
 			Position: position,
+			Start:    start,
+			End:      len(text),
 		})
 	}
 
 	return tokens
 }
+
+// Keyword emits the whole input as a single token.
+type Keyword struct {
+	Lowercase bool
+}
+
+func NewKeyword() *Keyword {
+	return &Keyword{}
+}
+
+// Analyze returns text as one token at position 0.
+func (a *Keyword) Analyze(text string) []TokenPosition {
+	if text == "" {
+		return nil
+	}
+	token := text
+	if a.Lowercase {
+		token = strings.ToLower(text)
+	}
+	return []TokenPosition{{Token: token, Position: 0, End: len(text)}}
+}
+
+// Whitespace splits on whitespace only, keeping punctuation inside tokens.
+type Whitespace struct {
+	Lowercase bool
+}
+
+func NewWhitespace() *Whitespace {
+	return &Whitespace{Lowercase: true}
+}
+
+// Analyze tokenizes text into whitespace-separated tokens with positions.
+func (a *Whitespace) Analyze(text string) []TokenPosition {
+	tokens := WhitespaceTokenizer{}.Tokenize(text)
+	if a.Lowercase {
+		for i := range tokens {
+			tokens[i].Token = strings.ToLower(tokens[i].Token)
+		}
+	}
+	return tokens
+}
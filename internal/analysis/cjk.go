@@ -0,0 +1,98 @@
+package analysis
+
+import "unicode"
+
+// CJKTokenizer splits Chinese, Japanese and Korean text, which is often
+// written without spaces, into overlapping bigrams: "東京都" becomes "東京"
+// and "京都" at consecutive positions, so a phrase query over bigrams
+// matches any substring of two or more characters. A lone CJK character is
+// emitted as a unigram. Other letters and numbers form tokens as in
+// UnicodeTokenizer.
+//
+// With OutputUnigrams each character is also emitted at the position of the
+// bigram it starts, so single-character queries match too.
+type CJKTokenizer struct {
+	OutputUnigrams bool
+}
+
+// isCJK reports whether r belongs to a script tokenized as bigrams. The
+// prolonged sound mark and half-width voicing marks are Common script but
+// appear inside katakana words.
+func isCJK(r rune) bool {
+	switch r {
+	case 'ー', 'ｰ', 'ﾞ', 'ﾟ':
+		return true
+	}
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// Tokenize implements Tokenizer.
+func (t CJKTokenizer) Tokenize(text string) []TokenPosition {
+	var tokens []TokenPosition
+	var pos uint64
+	var chars []string // current CJK run, one entry per character
+	var starts []int   // byte offset of each entry of chars
+	start := -1        // start of the current non-CJK word
+
+	flushWord := func(end int) {
+		if start >= 0 {
+			tokens = append(tokens, TokenPosition{Token: text[start:end], Position: pos, Start: start, End: end})
+			pos++
+			start = -1
+		}
+	}
+	unigram := func(i int) TokenPosition {
+		return TokenPosition{Token: chars[i], Position: pos, Start: starts[i], End: starts[i] + len(chars[i])}
+	}
+	flushCJK := func() {
+		if len(chars) == 1 {
+			tokens = append(tokens, unigram(0))
+			pos++
+		}
+		if len(chars) > 1 {
+			for i := 0; i+1 < len(chars); i++ {
+				if t.OutputUnigrams {
+					tokens = append(tokens, unigram(i))
+				}
+				tokens = append(tokens, TokenPosition{
+					Token:    chars[i] + chars[i+1],
+					Position: pos,
+					Start:    starts[i],
+					End:      starts[i+1] + len(chars[i+1]),
+				})
+				pos++
+			}
+			if t.OutputUnigrams {
+				tokens = append(tokens, unigram(len(chars)-1))
+				pos++
+			}
+		}
+		chars = chars[:0]
+		starts = starts[:0]
+	}
+
+	for i, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord(i)
+			chars = append(chars, string(r))
+			starts = append(starts, i)
+		case unicode.IsMark(r) && len(chars) > 0:
+			// Combining marks such as decomposed dakuten stay with their character.
+			chars[len(chars)-1] += string(r)
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			flushCJK()
+			if start < 0 {
+				start = i
+			}
+		case unicode.IsMark(r) && start >= 0:
+			// Marks continue the current word.
+		default:
+			flushWord(i)
+			flushCJK()
+		}
+	}
+	flushWord(len(text))
+	flushCJK()
+	return tokens
+}
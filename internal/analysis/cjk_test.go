@@ -0,0 +1,44 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCJKTokenizer(t *testing.T) {
+	tests := []struct {
+		text string
+		want []TokenPosition
+	}{
+		{"東京都庁", []TokenPosition{{Token: "東京", Position: 0}, {Token: "京都", Position: 1}, {Token: "都庁", Position: 2}}},
+		{"東", []TokenPosition{{Token: "東", Position: 0}}},
+		// Latin runs stay whole and CJK runs are split at script changes.
+		{"iPhone手机 v2", []TokenPosition{{Token: "iPhone", Position: 0}, {Token: "手机", Position: 1}, {Token: "v2", Position: 2}}},
+		// Katakana with the prolonged sound mark is one run.
+		{"コーヒー、お茶", []TokenPosition{{Token: "コー", Position: 0}, {Token: "ーヒ", Position: 1}, {Token: "ヒー", Position: 2}, {Token: "お茶", Position: 3}}},
+		{"서울 시청", []TokenPosition{{Token: "서울", Position: 0}, {Token: "시청", Position: 1}}},
+	}
+	for _, tt := range tests {
+		if got := (CJKTokenizer{}).Tokenize(tt.text); !reflect.DeepEqual(withoutOffsets(got), tt.want) {
+			t.Errorf("Tokenize(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCJKTokenizer_OutputUnigrams(t *testing.T) {
+	a, err := Build("cjk", map[string]string{"output_unigrams": "true"})
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+
+	got := a.Analyze("東京都 ＴＯＷＥＲ")
+	want := []TokenPosition{
+		{Token: "東", Position: 0}, {Token: "東京", Position: 0},
+		{Token: "京", Position: 1}, {Token: "京都", Position: 1},
+		{Token: "都", Position: 2},
+		{Token: "tower", Position: 3},
+	}
+	if !reflect.DeepEqual(withoutOffsets(got), want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
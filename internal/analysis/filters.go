@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// LowercaseFilter lowercases every token.
+type LowercaseFilter struct{}
+
+// Filter implements TokenFilter.
+func (LowercaseFilter) Filter(tokens []TokenPosition) []TokenPosition {
+	for i := range tokens {
+		tokens[i].Token = strings.ToLower(tokens[i].Token)
+	}
+	return tokens
+}
+
+// NormalizeToken implements TokenNormalizer.
+func (LowercaseFilter) NormalizeToken(token string) string {
+	return strings.ToLower(token)
+}
+
+// StopFilter drops stop words.
+type StopFilter struct {
+	Words map[string]bool
+}
+
+// NewStopFilter creates a stop filter for words.
+func NewStopFilter(words []string) *StopFilter {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return &StopFilter{Words: set}
+}
+
+// Filter implements TokenFilter.
+func (f *StopFilter) Filter(tokens []TokenPosition) []TokenPosition {
+	out := tokens[:0]
+	for _, t := range tokens {
+		if !f.Words[t.Token] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// LengthFilter drops tokens shorter than Min or longer than Max runes.
+// A zero Max means no upper limit.
+type LengthFilter struct {
+	Min int
+	Max int
+}
+
+// Filter implements TokenFilter.
+func (f *LengthFilter) Filter(tokens []TokenPosition) []TokenPosition {
+	out := tokens[:0]
+	for _, t := range tokens {
+		n := utf8.RuneCountInString(t.Token)
+		if n < f.Min || (f.Max > 0 && n > f.Max) {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// PorterStemFilter reduces English words to their Porter stem.
+type PorterStemFilter struct{}
+
+// Filter implements TokenFilter.
+func (PorterStemFilter) Filter(tokens []TokenPosition) []TokenPosition {
+	for i := range tokens {
+		tokens[i].Token = PorterStem(tokens[i].Token)
+	}
+	return tokens
+}
+
+// EnglishStopWords is the default English stop-word list.
+var EnglishStopWords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "but", "by",
+	"for", "if", "in", "into", "is", "it",
+	"no", "not", "of", "on", "or", "such",
+	"that", "the", "their", "then", "there", "these",
+	"they", "this", "to", "was", "will", "with",
+}
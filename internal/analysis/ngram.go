@@ -0,0 +1,44 @@
+package analysis
+
+// NGramFilter replaces each token with its character n-grams of Min to Max
+// runes, for infix matching. Every gram keeps the position and offsets of
+// its source token, so phrase matching on the field still sees one position
+// per word. Tokens shorter than Min are dropped.
+type NGramFilter struct {
+	Min int
+	Max int
+}
+
+// Filter implements TokenFilter.
+func (f *NGramFilter) Filter(tokens []TokenPosition) []TokenPosition {
+	var out []TokenPosition
+	for _, t := range tokens {
+		runes := []rune(t.Token)
+		for start := range runes {
+			for n := f.Min; n <= f.Max && start+n <= len(runes); n++ {
+				out = append(out, TokenPosition{Token: string(runes[start : start+n]), Position: t.Position, Start: t.Start, End: t.End})
+			}
+		}
+	}
+	return out
+}
+
+// EdgeNGramFilter replaces each token with its prefixes of Min to Max runes,
+// for search-as-you-type. Like NGramFilter, grams share their source
+// token's position.
+type EdgeNGramFilter struct {
+	Min int
+	Max int
+}
+
+// Filter implements TokenFilter.
+func (f *EdgeNGramFilter) Filter(tokens []TokenPosition) []TokenPosition {
+	var out []TokenPosition
+	for _, t := range tokens {
+		runes := []rune(t.Token)
+		for n := f.Min; n <= f.Max && n <= len(runes); n++ {
+			out = append(out, TokenPosition{Token: string(runes[:n]), Position: t.Position, Start: t.Start, End: t.End})
+		}
+	}
+	return out
+}
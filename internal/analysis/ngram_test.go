@@ -0,0 +1,53 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNGramFilter(t *testing.T) {
+	f := &NGramFilter{Min: 2, Max: 3}
+	got := f.Filter([]TokenPosition{{Token: "a", Position: 0}, {Token: "café", Position: 1}})
+	want := []TokenPosition{
+		{Token: "ca", Position: 1},
+		{Token: "caf", Position: 1},
+		{Token: "af", Position: 1},
+		{Token: "afé", Position: 1},
+		{Token: "fé", Position: 1},
+	}
+	if !reflect.DeepEqual(withoutOffsets(got), want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestEdgeNGramFilter(t *testing.T) {
+	a, err := Build("custom", map[string]string{
+		"token_filters": "lowercase,edge_ngram",
+		"min_gram":      "1",
+		"max_gram":      "3",
+	})
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+
+	got := a.Analyze("Quick Go")
+	want := []TokenPosition{
+		{Token: "q", Position: 0},
+		{Token: "qu", Position: 0},
+		{Token: "qui", Position: 0},
+		{Token: "g", Position: 1},
+		{Token: "go", Position: 1},
+	}
+	if !reflect.DeepEqual(withoutOffsets(got), want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, options := range []map[string]string{
+		{"token_filters": "ngram", "min_gram": "0"},
+		{"token_filters": "edge_ngram", "min_gram": "3", "max_gram": "2"},
+	} {
+		if _, err := Build("custom", options); err == nil {
+			t.Errorf("expected error for %v", options)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package analysis
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalizer is implemented by analyzers that can normalize a single query
+// term without splitting or stemming it. Prefix and fuzzy queries use it so
+// their input matches terms the way they were indexed.
+type Normalizer interface {
+	Normalize(term string) string
+}
+
+// TokenNormalizer is implemented by token filters that rewrite each token
+// independently, such as lowercasing or folding. A Pipeline applies only
+// these filters when normalizing a query term.
+type TokenNormalizer interface {
+	NormalizeToken(token string) string
+}
+
+// Normalize normalizes term with a, or returns it unchanged if a does not
+// implement Normalizer.
+func Normalize(a Analyzer, term string) string {
+	if n, ok := a.(Normalizer); ok {
+		return n.Normalize(term)
+	}
+	return term
+}
+
+// UnicodeNormFilter converts tokens to a Unicode normalization form, so
+// composed and decomposed (NFC) or compatibility variants such as
+// full-width letters and ligatures (NFKC) index as the same term.
+type UnicodeNormFilter struct {
+	Form norm.Form
+}
+
+// Filter implements TokenFilter.
+func (f UnicodeNormFilter) Filter(tokens []TokenPosition) []TokenPosition {
+	for i := range tokens {
+		tokens[i].Token = f.NormalizeToken(tokens[i].Token)
+	}
+	return tokens
+}
+
+// NormalizeToken implements TokenNormalizer.
+func (f UnicodeNormFilter) NormalizeToken(token string) string {
+	return f.Form.String(token)
+}
+
+// ASCIIFoldingFilter replaces letters, digits and punctuation that have an
+// ASCII equivalent with it, so "café" and "cafe" index as the same term.
+// Characters without an equivalent are kept.
+type ASCIIFoldingFilter struct{}
+
+// Filter implements TokenFilter.
+func (f ASCIIFoldingFilter) Filter(tokens []TokenPosition) []TokenPosition {
+	for i := range tokens {
+		tokens[i].Token = f.NormalizeToken(tokens[i].Token)
+	}
+	return tokens
+}
+
+// NormalizeToken implements TokenNormalizer.
+func (ASCIIFoldingFilter) NormalizeToken(token string) string {
+	return FoldASCII(token)
+}
+
+// asciiFoldings covers characters whose compatibility decomposition has no
+// ASCII base letter.
+var asciiFoldings = map[rune]string{
+	'ß': "ss", 'ẞ': "SS", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE",
+	'ø': "o", 'Ø': "O", 'đ': "d", 'Đ': "D", 'ð': "d", 'Ð': "D",
+	'ł': "l", 'Ł': "L", 'þ': "th", 'Þ': "TH", 'ı': "i", 'ħ': "h", 'Ħ': "H",
+	'‘': "'", '’': "'", '‚': "'", '“': "\"", '”': "\"", '„': "\"",
+	'–': "-", '—': "-", '‐': "-", '‑': "-",
+}
+
+// FoldASCII replaces characters in s with their ASCII equivalents. Accents
+// are removed by decomposing each character (NFKD) and dropping combining
+// marks; a character is kept as is if what remains is not ASCII.
+func FoldASCII(s string) string {
+	i := 0
+	for i < len(s) && s[i] < utf8.RuneSelf {
+		i++
+	}
+	if i == len(s) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	b.WriteString(s[:i])
+	for _, r := range s[i:] {
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+			continue
+		}
+		if folded, ok := asciiFoldings[r]; ok {
+			b.WriteString(folded)
+			continue
+		}
+		if folded, ok := foldRune(r); ok {
+			b.WriteString(folded)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// foldRune decomposes r and drops combining marks, reporting whether the
+// rest is ASCII. A lone combining mark folds to the empty string.
+func foldRune(r rune) (string, bool) {
+	var b strings.Builder
+	for _, d := range norm.NFKD.String(string(r)) {
+		if unicode.Is(unicode.Mn, d) {
+			continue
+		}
+		if d >= utf8.RuneSelf {
+			return "", false
+		}
+		b.WriteRune(d)
+	}
+	return b.String(), true
+}
+
+// Normalize implements Normalizer.
+func (a *Simple) Normalize(term string) string {
+	return strings.ToLower(term)
+}
+
+// Normalize implements Normalizer.
+func (a *Keyword) Normalize(term string) string {
+	if a.Lowercase {
+		return strings.ToLower(term)
+	}
+	return term
+}
+
+// Normalize implements Normalizer.
+func (a *Whitespace) Normalize(term string) string {
+	if a.Lowercase {
+		return strings.ToLower(term)
+	}
+	return term
+}
+
+// Normalize implements Normalizer by running the char filters and every
+// token filter that is a TokenNormalizer.
+func (p *Pipeline) Normalize(term string) string {
+	for _, cf := range p.CharFilters {
+		term = cf.Filter(term)
+	}
+	for _, tf := range p.TokenFilters {
+		if n, ok := tf.(TokenNormalizer); ok {
+			term = n.NormalizeToken(term)
+		}
+	}
+	return term
+}
@@ -0,0 +1,75 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFoldASCII(t *testing.T) {
+	tests := map[string]string{
+		"cafe":       "cafe",
+		"café":       "cafe",
+		"cafe\u0301": "cafe",
+		"Ångström":   "Angstrom",
+		"straße":     "strasse",
+		"Æsir":       "AEsir",
+		"ﬁle":        "file",
+		"ＡＢＣ１２":      "ABC12",
+		"Łódź":       "Lodz",
+		"naïve–ish":  "naive-ish",
+		"東京":         "東京",
+		"Привет":     "Привет",
+		"ﬁ東é":        "fi東e",
+	}
+	for in, want := range tests {
+		if got := FoldASCII(in); got != want {
+			t.Errorf("FoldASCII(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPipeline_Normalization(t *testing.T) {
+	a, err := Build("custom", map[string]string{"token_filters": "nfkc,lowercase,asciifolding"})
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+
+	// Composed, decomposed and full-width forms all become one term.
+	got := a.Analyze("Café cafe\u0301 ＣＡＦＥ")
+	want := []TokenPosition{
+		{Token: "cafe", Position: 0},
+		{Token: "cafe", Position: 1},
+		{Token: "cafe", Position: 2},
+	}
+	if !reflect.DeepEqual(withoutOffsets(got), want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Normalize applies the per-token filters without splitting.
+	if got := Normalize(a, "Crème Brû"); got != "creme bru" {
+		t.Errorf("Normalize = %q, want %q", got, "creme bru")
+	}
+
+	nfc, _ := Build("custom", map[string]string{"token_filters": "nfc"})
+	if got := nfc.Analyze("cafe\u0301"); len(got) != 1 || got[0].Token != "café" {
+		t.Errorf("nfc: got %v, want [café]", got)
+	}
+}
+
+func TestNormalize_BuiltinAnalyzers(t *testing.T) {
+	tests := []struct {
+		a    Analyzer
+		want string
+	}{
+		{NewSimple(), "hello world"},
+		{NewKeyword(), "Hello World"},
+		{&Keyword{Lowercase: true}, "hello world"},
+		{NewWhitespace(), "hello world"},
+		{NewEnglish(), "hello world"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.a, "Hello World"); got != tt.want {
+			t.Errorf("Normalize(%T) = %q, want %q", tt.a, got, tt.want)
+		}
+	}
+}
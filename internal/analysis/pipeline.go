@@ -0,0 +1,266 @@
+package analysis
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// CharFilter rewrites text before it is tokenized.
+type CharFilter interface {
+	Filter(text string) string
+}
+
+// OffsetCharFilter is a CharFilter that also reports how it moved text, so
+// token offsets can be mapped back to the unfiltered input. Offsets of
+// tokens produced after other char filters are left as they are.
+type OffsetCharFilter interface {
+	CharFilter
+	FilterOffsets(text string) (string, OffsetCorrections)
+}
+
+// OffsetCorrections maps byte offsets in filtered text back to the text
+// before filtering. Each entry applies from its At offset onwards, until
+// the next entry.
+type OffsetCorrections []OffsetCorrection
+
+// OffsetCorrection says that filtered offsets from At onwards are Diff bytes
+// behind the original text.
+type OffsetCorrection struct {
+	At   int
+	Diff int
+}
+
+// Correct maps an offset in filtered text to the original text.
+func (c OffsetCorrections) Correct(offset int) int {
+	i := sort.Search(len(c), func(i int) bool { return c[i].At > offset })
+	if i == 0 {
+		return offset
+	}
+	return offset + c[i-1].Diff
+}
+
+// replaceWithOffsets replaces the [start, end) ranges in matches, which must
+// be ordered and disjoint, with replace's result and records corrections.
+func replaceWithOffsets(text string, matches [][]int, replace func(string) string) (string, OffsetCorrections) {
+	if len(matches) == 0 {
+		return text, nil
+	}
+	var b strings.Builder
+	var corrections OffsetCorrections
+	prev := 0
+	for _, m := range matches {
+		b.WriteString(text[prev:m[0]])
+		b.WriteString(replace(text[m[0]:m[1]]))
+		if diff := m[1] - b.Len(); len(corrections) == 0 || corrections[len(corrections)-1].Diff != diff {
+			corrections = append(corrections, OffsetCorrection{At: b.Len(), Diff: diff})
+		}
+		prev = m[1]
+	}
+	b.WriteString(text[prev:])
+	return b.String(), corrections
+}
+
+// Tokenizer splits text into tokens with positions.
+type Tokenizer interface {
+	Tokenize(text string) []TokenPosition
+}
+
+// TokenFilter transforms a token stream. Filters that drop tokens keep the
+// positions of the remaining ones, so phrases still line up.
+type TokenFilter interface {
+	Filter(tokens []TokenPosition) []TokenPosition
+}
+
+// Pipeline is an Analyzer that runs char filters, a tokenizer and token
+// filters in order.
+type Pipeline struct {
+	CharFilters  []CharFilter
+	Tokenizer    Tokenizer
+	TokenFilters []TokenFilter
+}
+
+// Analyze runs text through the pipeline.
+func (p *Pipeline) Analyze(text string) []TokenPosition {
+	tokens := p.tokenize(text)
+	for _, tf := range p.TokenFilters {
+		if len(tokens) == 0 {
+			break
+		}
+		tokens = tf.Filter(tokens)
+	}
+	return tokens
+}
+
+// tokenize runs the char filters and tokenizer, mapping token offsets back
+// to text.
+func (p *Pipeline) tokenize(text string) []TokenPosition {
+	var corrections []OffsetCorrections
+	for _, cf := range p.CharFilters {
+		if oc, ok := cf.(OffsetCharFilter); ok {
+			var c OffsetCorrections
+			text, c = oc.FilterOffsets(text)
+			corrections = append(corrections, c)
+			continue
+		}
+		text = cf.Filter(text)
+	}
+
+	tokens := p.Tokenizer.Tokenize(text)
+	for i := len(corrections) - 1; i >= 0; i-- {
+		if len(corrections[i]) == 0 {
+			continue
+		}
+		for j := range tokens {
+			tokens[j].Start = corrections[i].Correct(tokens[j].Start)
+			tokens[j].End = corrections[i].Correct(tokens[j].End)
+		}
+	}
+	return tokens
+}
+
+// UnicodeTokenizer emits runs of letters and numbers. Combining marks stay
+// with the preceding letter, so decomposed text such as "cafe\u0301" is one
+// token.
+type UnicodeTokenizer struct{}
+
+// Tokenize implements Tokenizer.
+func (UnicodeTokenizer) Tokenize(text string) []TokenPosition {
+	var tokens []TokenPosition
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && unicode.IsMark(r) {
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, TokenPosition{Token: text[start:i], Position: uint64(len(tokens)), Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, TokenPosition{Token: text[start:], Position: uint64(len(tokens)), Start: start, End: len(text)})
+	}
+	return tokens
+}
+
+// WhitespaceTokenizer splits on whitespace only.
+type WhitespaceTokenizer struct{}
+
+// Tokenize implements Tokenizer.
+func (WhitespaceTokenizer) Tokenize(text string) []TokenPosition {
+	var tokens []TokenPosition
+	start := -1
+	for i, r := range text {
+		if !unicode.IsSpace(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, TokenPosition{Token: text[start:i], Position: uint64(len(tokens)), Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, TokenPosition{Token: text[start:], Position: uint64(len(tokens)), Start: start, End: len(text)})
+	}
+	return tokens
+}
+
+// KeywordTokenizer emits the whole text as one token.
+type KeywordTokenizer struct{}
+
+// Tokenize implements Tokenizer.
+func (KeywordTokenizer) Tokenize(text string) []TokenPosition {
+	if text == "" {
+		return nil
+	}
+	return []TokenPosition{{Token: text, End: len(text)}}
+}
+
+// htmlMarkup matches a tag or one of the entities HTMLStripCharFilter decodes.
+var htmlMarkup = regexp.MustCompile(`<[^>]*>|&(?:amp|lt|gt|quot|#39|nbsp);`)
+
+var htmlEntities = map[string]string{
+	"&amp;": "&", "&lt;": "<", "&gt;": ">", "&quot;": `"`, "&#39;": "'", "&nbsp;": " ",
+}
+
+// HTMLStripCharFilter replaces HTML tags with spaces and decodes a few
+// common entities.
+type HTMLStripCharFilter struct{}
+
+// Filter implements CharFilter.
+func (f HTMLStripCharFilter) Filter(text string) string {
+	text, _ = f.FilterOffsets(text)
+	return text
+}
+
+// FilterOffsets implements OffsetCharFilter.
+func (HTMLStripCharFilter) FilterOffsets(text string) (string, OffsetCorrections) {
+	return replaceWithOffsets(text, htmlMarkup.FindAllStringIndex(text, -1), func(markup string) string {
+		if decoded, ok := htmlEntities[markup]; ok {
+			return decoded
+		}
+		return " "
+	})
+}
+
+// MappingCharFilter replaces fixed strings, such as ligatures or
+// punctuation variants, before tokenizing. The longest match wins.
+type MappingCharFilter struct {
+	mappings map[string]string
+	keys     []string // longest first
+}
+
+// NewMappingCharFilter creates a char filter from a replacement table.
+func NewMappingCharFilter(mappings map[string]string) *MappingCharFilter {
+	f := &MappingCharFilter{mappings: make(map[string]string, len(mappings))}
+	for from, to := range mappings {
+		if from != "" {
+			f.mappings[from] = to
+			f.keys = append(f.keys, from)
+		}
+	}
+	sort.Slice(f.keys, func(i, j int) bool {
+		if len(f.keys[i]) != len(f.keys[j]) {
+			return len(f.keys[i]) > len(f.keys[j])
+		}
+		return f.keys[i] < f.keys[j]
+	})
+	return f
+}
+
+// Filter implements CharFilter.
+func (f *MappingCharFilter) Filter(text string) string {
+	text, _ = f.FilterOffsets(text)
+	return text
+}
+
+// FilterOffsets implements OffsetCharFilter.
+func (f *MappingCharFilter) FilterOffsets(text string) (string, OffsetCorrections) {
+	var matches [][]int
+	for i := 0; i < len(text); {
+		n := 0
+		for _, key := range f.keys {
+			if strings.HasPrefix(text[i:], key) {
+				n = len(key)
+				break
+			}
+		}
+		if n == 0 {
+			i++
+			continue
+		}
+		matches = append(matches, []int{i, i + n})
+		i += n
+	}
+	return replaceWithOffsets(text, matches, func(s string) string { return f.mappings[s] })
+}
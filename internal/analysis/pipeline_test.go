@@ -0,0 +1,103 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPipeline_English(t *testing.T) {
+	// Stop words are dropped but keep their positions so phrases still line up.
+	got := NewEnglish().Analyze("The Runners were running in the Parks")
+	want := []TokenPosition{
+		{Token: "runner", Position: 1},
+		{Token: "were", Position: 2},
+		{Token: "run", Position: 3},
+		{Token: "park", Position: 6},
+	}
+	if !reflect.DeepEqual(withoutOffsets(got), want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPipeline_Custom(t *testing.T) {
+	a, err := Build("custom", map[string]string{
+		"char_filters":  "html_strip",
+		"token_filters": "lowercase,stop,length",
+		"stopwords":     "foo, bar",
+		"min_length":    "2",
+	})
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+
+	got := a.Analyze("<p>Foo x the Bar&amp;Baz</p>")
+	want := []TokenPosition{
+		{Token: "the", Position: 2},
+		{Token: "baz", Position: 4},
+	}
+	if !reflect.DeepEqual(withoutOffsets(got), want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBuild_Errors(t *testing.T) {
+	for _, options := range []map[string]string{
+		{"tokenizer": "nope"},
+		{"char_filters": "nope"},
+		{"token_filters": "lowercase,nope"},
+		{"token_filters": "length", "max_length": "x"},
+	} {
+		if _, err := Build("custom", options); err == nil {
+			t.Errorf("expected error for %v", options)
+		}
+	}
+	if _, err := Build("nope", nil); err == nil {
+		t.Error("expected error for unknown analyzer")
+	}
+}
+
+// withoutOffsets clears token offsets, for tests that only check tokens and
+// positions.
+func withoutOffsets(tokens []TokenPosition) []TokenPosition {
+	out := make([]TokenPosition, len(tokens))
+	for i, t := range tokens {
+		out[i] = TokenPosition{Token: t.Token, Position: t.Position}
+	}
+	return out
+}
+
+func TestAnalyzers_Offsets(t *testing.T) {
+	html, _ := Build("custom", map[string]string{"char_filters": "html_strip", "token_filters": "lowercase"})
+	grams, _ := Build("custom", map[string]string{"token_filters": "edge_ngram", "min_gram": "2", "max_gram": "3"})
+	synonyms, _ := Build("custom", map[string]string{"token_filters": "lowercase,synonym", "synonyms": "nyc => new york"})
+	mapped := &Pipeline{
+		CharFilters: []CharFilter{NewMappingCharFilter(map[string]string{"ﬁ": "fi", "--": ""})},
+		Tokenizer:   UnicodeTokenizer{},
+	}
+
+	// The source text of each token, as "token=source".
+	tests := []struct {
+		a    Analyzer
+		text string
+		want []string
+	}{
+		{NewSimple(), "Hello, Wörld!", []string{"hello=Hello", "wörld=Wörld"}},
+		{NewWhitespace(), "  Go  is-fun ", []string{"go=Go", "is-fun=is-fun"}},
+		{NewKeyword(), "AB-12", []string{"AB-12=AB-12"}},
+		{html, "<p>AT&amp;T <b>Café</b></p>", []string{"at=AT", "t=T", "café=Café"}},
+		{mapped, "ﬁne--tuned", []string{"finetuned=ﬁne--tuned"}},
+		{NewEnglish(), "The runners", []string{"runner=runners"}},
+		{grams, "Go fast", []string{"Go=Go", "fa=fast", "fas=fast"}},
+		{synonyms, "I love NYC!", []string{"i=I", "love=love", "new=NYC", "york=NYC"}},
+		{&Pipeline{Tokenizer: CJKTokenizer{}}, "在东京都", []string{"在东=在东", "东京=东京", "京都=京都"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, tp := range tt.a.Analyze(tt.text) {
+			got = append(got, tp.Token+"="+tt.text[tp.Start:tp.End])
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Analyze(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package analysis
+
+import "testing"
+
+func TestPorterStem(t *testing.T) {
+	tests := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"ties":           "ti",
+		"caress":         "caress",
+		"cats":           "cat",
+		"feed":           "feed",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"conflated":      "conflat",
+		"troubled":       "troubl",
+		"sized":          "size",
+		"hopping":        "hop",
+		"tanned":         "tan",
+		"falling":        "fall",
+		"hissing":        "hiss",
+		"fizzed":         "fizz",
+		"failing":        "fail",
+		"filing":         "file",
+		"happy":          "happi",
+		"sky":            "sky",
+		"relational":     "relat",
+		"conditional":    "condit",
+		"rational":       "ration",
+		"digitizer":      "digit",
+		"generalization": "gener",
+		"electricity":    "electr",
+		"adjustment":     "adjust",
+		"adoption":       "adopt",
+		"controlling":    "control",
+		"running":        "run",
+		"runs":           "run",
+		"connections":    "connect",
+		"hopeful":        "hope",
+		"goodness":       "good",
+		"is":             "is",
+		"naïve":          "naïve",
+		"HTTP":           "HTTP",
+	}
+	for word, want := range tests {
+		if got := PorterStem(word); got != want {
+			t.Errorf("PorterStem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
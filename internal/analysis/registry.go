@@ -0,0 +1,276 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Factory builds an analyzer from string options.
+type Factory func(options map[string]string) (Analyzer, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		"simple": func(map[string]string) (Analyzer, error) {
+			return NewSimple(), nil
+		},
+		"keyword": func(options map[string]string) (Analyzer, error) {
+			lowercase, err := BoolOption(options, "lowercase", false)
+			if err != nil {
+				return nil, err
+			}
+			return &Keyword{Lowercase: lowercase}, nil
+		},
+		"whitespace": func(options map[string]string) (Analyzer, error) {
+			lowercase, err := BoolOption(options, "lowercase", true)
+			if err != nil {
+				return nil, err
+			}
+			return &Whitespace{Lowercase: lowercase}, nil
+		},
+		"standard": func(options map[string]string) (Analyzer, error) {
+			return buildPipeline(options, "unicode", "", "lowercase,length")
+		},
+		"english": func(options map[string]string) (Analyzer, error) {
+			return buildPipeline(options, "unicode", "", "lowercase,length,stop,porter_stem")
+		},
+		"cjk": func(options map[string]string) (Analyzer, error) {
+			return buildPipeline(options, "cjk", "", "nfkc,lowercase")
+		},
+		"custom": func(options map[string]string) (Analyzer, error) {
+			return buildPipeline(options, "unicode", "", "")
+		},
+	}
+)
+
+// NewStandard returns the "standard" analyzer: Unicode word tokens,
+// lowercased, with tokens over 255 characters dropped.
+func NewStandard() Analyzer {
+	a, _ := Build("standard", nil)
+	return a
+}
+
+// NewEnglish returns the "english" analyzer: the standard analyzer plus
+// English stop words and Porter stemming.
+func NewEnglish() Analyzer {
+	a, _ := Build("english", nil)
+	return a
+}
+
+// buildPipeline assembles a Pipeline. The "tokenizer", "char_filters" and
+// "token_filters" options (comma-separated names) override the defaults.
+//
+// Tokenizers: unicode, whitespace, keyword, cjk ("output_unigrams").
+// Char filters: html_strip.
+// Token filters: lowercase, stop ("stopwords" option, "_english_" or a
+// comma-separated list), length ("min_length", "max_length"), porter_stem,
+// ngram and edge_ngram ("min_gram", "max_gram"), nfc, nfkc, asciifolding,
+// synonym ("synonyms" or "synonyms_path", "synonyms_format", "expand").
+func buildPipeline(options map[string]string, tokenizer, charFilters, tokenFilters string) (Analyzer, error) {
+	if v, ok := options["tokenizer"]; ok {
+		tokenizer = v
+	}
+	if v, ok := options["char_filters"]; ok {
+		charFilters = v
+	}
+	if v, ok := options["token_filters"]; ok {
+		tokenFilters = v
+	}
+
+	p := &Pipeline{}
+	switch tokenizer {
+	case "unicode":
+		p.Tokenizer = UnicodeTokenizer{}
+	case "whitespace":
+		p.Tokenizer = WhitespaceTokenizer{}
+	case "keyword":
+		p.Tokenizer = KeywordTokenizer{}
+	case "cjk":
+		unigrams, err := BoolOption(options, "output_unigrams", false)
+		if err != nil {
+			return nil, err
+		}
+		p.Tokenizer = CJKTokenizer{OutputUnigrams: unigrams}
+	default:
+		return nil, fmt.Errorf("unknown tokenizer: %s", tokenizer)
+	}
+
+	for _, name := range splitList(charFilters) {
+		switch name {
+		case "html_strip":
+			p.CharFilters = append(p.CharFilters, HTMLStripCharFilter{})
+		default:
+			return nil, fmt.Errorf("unknown char filter: %s", name)
+		}
+	}
+
+	for _, name := range splitList(tokenFilters) {
+		f, err := buildTokenFilter(name, options, p)
+		if err != nil {
+			return nil, err
+		}
+		p.TokenFilters = append(p.TokenFilters, f)
+	}
+	return p, nil
+}
+
+// buildTokenFilter builds the named filter. p is the pipeline so far, used
+// to analyze synonym rules the way the filter's input was analyzed.
+func buildTokenFilter(name string, options map[string]string, p *Pipeline) (TokenFilter, error) {
+	switch name {
+	case "lowercase":
+		return LowercaseFilter{}, nil
+	case "stop":
+		words := EnglishStopWords
+		if v, ok := options["stopwords"]; ok && v != "_english_" {
+			words = splitList(v)
+		}
+		return NewStopFilter(words), nil
+	case "length":
+		minLen, err := IntOption(options, "min_length", 1)
+		if err != nil {
+			return nil, err
+		}
+		maxLen, err := IntOption(options, "max_length", 255)
+		if err != nil {
+			return nil, err
+		}
+		return &LengthFilter{Min: minLen, Max: maxLen}, nil
+	case "porter_stem":
+		return PorterStemFilter{}, nil
+	case "nfc":
+		return UnicodeNormFilter{Form: norm.NFC}, nil
+	case "nfkc":
+		return UnicodeNormFilter{Form: norm.NFKC}, nil
+	case "asciifolding":
+		return ASCIIFoldingFilter{}, nil
+	case "synonym":
+		return buildSynonymFilter(options, p)
+	case "ngram", "edge_ngram":
+		minGram, err := IntOption(options, "min_gram", 1)
+		if err != nil {
+			return nil, err
+		}
+		maxGram, err := IntOption(options, "max_gram", 2)
+		if err != nil {
+			return nil, err
+		}
+		if minGram < 1 || maxGram < minGram {
+			return nil, fmt.Errorf("invalid gram sizes: min_gram %d, max_gram %d", minGram, maxGram)
+		}
+		if name == "ngram" {
+			return &NGramFilter{Min: minGram, Max: maxGram}, nil
+		}
+		return &EdgeNGramFilter{Min: minGram, Max: maxGram}, nil
+	default:
+		return nil, fmt.Errorf("unknown token filter: %s", name)
+	}
+}
+
+// buildSynonymFilter loads rules from the "synonyms" option (rules separated
+// by ";") or the file named by "synonyms_path".
+func buildSynonymFilter(options map[string]string, p *Pipeline) (TokenFilter, error) {
+	expand, err := BoolOption(options, "expand", true)
+	if err != nil {
+		return nil, err
+	}
+	format := options["synonyms_format"]
+	analyze := func(phrase string) []string {
+		var tokens []string
+		for _, t := range p.Analyze(phrase) {
+			tokens = append(tokens, t.Token)
+		}
+		return tokens
+	}
+
+	var m *SynonymMap
+	if rules, ok := options["synonyms"]; ok {
+		m, err = ParseSynonyms(strings.NewReader(strings.ReplaceAll(rules, ";", "\n")), format, expand, analyze)
+	} else if path, ok := options["synonyms_path"]; ok {
+		m, err = LoadSynonyms(path, format, expand, analyze)
+	} else {
+		return nil, fmt.Errorf("synonym filter needs a synonyms or synonyms_path option")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &SynonymFilter{Map: m}, nil
+}
+
+// splitList splits a comma-separated option, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// Register makes an analyzer available by name, replacing any existing one.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// Build creates the named analyzer with the given options.
+func Build(name string, options map[string]string) (Analyzer, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown analyzer: %s", name)
+	}
+
+	analyzer, err := factory(options)
+	if err != nil {
+		return nil, fmt.Errorf("analyzer %s: %w", name, err)
+	}
+	return analyzer, nil
+}
+
+// Names returns the registered analyzer names in sorted order.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BoolOption parses a boolean option, returning def if it is not set.
+func BoolOption(options map[string]string, key string, def bool) (bool, error) {
+	value, ok := options[key]
+	if !ok {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s option %q", key, value)
+	}
+	return b, nil
+}
+
+// IntOption parses an integer option, returning def if it is not set.
+func IntOption(options map[string]string, key string, def int) (int, error) {
+	value, ok := options[key]
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s option %q", key, value)
+	}
+	return n, nil
+}
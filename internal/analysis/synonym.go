@@ -0,0 +1,393 @@
+package analysis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// GraphTokenFilter is a TokenFilter whose output stacks alternative token
+// sequences on the same positions. FilterGraph returns each alternative as
+// its own sequence, so a query can match any one of them.
+type GraphTokenFilter interface {
+	TokenFilter
+	FilterGraph(tokens []TokenPosition) [][]TokenPosition
+}
+
+// GraphAnalyzer is implemented by analyzers that can return the alternative
+// token sequences of a text rather than one stacked stream.
+type GraphAnalyzer interface {
+	AnalyzeGraph(text string) [][]TokenPosition
+}
+
+// AnalyzeGraph returns the alternative token sequences of text under a. For
+// analyzers without alternatives it is the single sequence from Analyze.
+func AnalyzeGraph(a Analyzer, text string) [][]TokenPosition {
+	if g, ok := a.(GraphAnalyzer); ok {
+		return g.AnalyzeGraph(text)
+	}
+	tokens := a.Analyze(text)
+	if len(tokens) == 0 {
+		return nil
+	}
+	return [][]TokenPosition{tokens}
+}
+
+// maxGraphPaths bounds the number of alternatives a query can expand to.
+// Alternatives past the limit are dropped, so such a query matches only
+// some of its synonym combinations.
+const maxGraphPaths = 64
+
+// AnalyzeGraph implements GraphAnalyzer. Graph filters fork the stream into
+// alternatives; later filters run on each alternative. At most
+// maxGraphPaths alternatives are returned: each filter keeps the first ones
+// it produces and drops the rest.
+func (p *Pipeline) AnalyzeGraph(text string) [][]TokenPosition {
+	paths := [][]TokenPosition{p.tokenize(text)}
+	for _, tf := range p.TokenFilters {
+		var next [][]TokenPosition
+		for _, tokens := range paths {
+			if len(tokens) == 0 {
+				continue
+			}
+			if g, ok := tf.(GraphTokenFilter); ok {
+				next = append(next, g.FilterGraph(tokens)...)
+			} else {
+				next = append(next, tf.Filter(tokens))
+			}
+		}
+		if len(next) > maxGraphPaths {
+			next = next[:maxGraphPaths]
+		}
+		paths = next
+	}
+
+	out := paths[:0]
+	for _, tokens := range paths {
+		if len(tokens) > 0 {
+			out = append(out, tokens)
+		}
+	}
+	return out
+}
+
+// SynonymMap holds synonym rules keyed by their input token sequence.
+type SynonymMap struct {
+	rules  map[string][][]string // joined input tokens -> output sequences
+	maxLen int                   // longest input, in tokens
+}
+
+// synonymKey joins tokens into a map key.
+func synonymKey(tokens []string) string {
+	return strings.Join(tokens, "\x00")
+}
+
+// add maps input to output, ignoring duplicates.
+func (m *SynonymMap) add(input, output []string) {
+	key := synonymKey(input)
+	for _, existing := range m.rules[key] {
+		if slices.Equal(existing, output) {
+			return
+		}
+	}
+	m.rules[key] = append(m.rules[key], output)
+	m.maxLen = max(m.maxLen, len(input))
+}
+
+// ParseSynonyms reads synonym rules. format is "solr" or "wordnet".
+//
+// Solr rules are one per line; "#" starts a comment. "a, b, c" makes the
+// terms equivalent: with expand each maps to all of them, otherwise each
+// maps to the first. "a, b => c, d" replaces a or b with c and d.
+//
+// WordNet rules are Prolog facts such as s(100001,1,'laptop',n,1,0). Words
+// sharing a synset id are equivalent, as in a Solr "a, b, c" rule.
+//
+// Each phrase in a rule is split into tokens with analyze, normally the
+// tokenizer and filters that run before the synonym filter, so multi-word
+// phrases match the token stream they will be applied to.
+func ParseSynonyms(r io.Reader, format string, expand bool, analyze func(string) []string) (*SynonymMap, error) {
+	m := &SynonymMap{rules: make(map[string][][]string)}
+
+	var groups [][]string
+	var explicit [][2][]string
+	var err error
+	switch format {
+	case "", "solr":
+		groups, explicit, err = parseSolrSynonyms(r)
+	case "wordnet":
+		groups, err = parseWordNetSynonyms(r)
+	default:
+		return nil, fmt.Errorf("unknown synonym format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	phrases := func(list []string) [][]string {
+		var out [][]string
+		for _, phrase := range list {
+			if tokens := analyze(phrase); len(tokens) > 0 {
+				out = append(out, tokens)
+			}
+		}
+		return out
+	}
+
+	for _, group := range groups {
+		terms := phrases(group)
+		for _, input := range terms {
+			if !expand {
+				m.add(input, terms[0])
+				continue
+			}
+			for _, output := range terms {
+				m.add(input, output)
+			}
+		}
+	}
+	for _, rule := range explicit {
+		outputs := phrases(rule[1])
+		for _, input := range phrases(rule[0]) {
+			for _, output := range outputs {
+				m.add(input, output)
+			}
+		}
+	}
+	return m, nil
+}
+
+// parseSolrSynonyms splits Solr rules into equivalence groups and explicit
+// "=>" mappings.
+func parseSolrSynonyms(r io.Reader) ([][]string, [][2][]string, error) {
+	var groups [][]string
+	var explicit [][2][]string
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if lhs, rhs, ok := strings.Cut(text, "=>"); ok {
+			from, to := splitList(lhs), splitList(rhs)
+			if len(from) == 0 || len(to) == 0 {
+				return nil, nil, fmt.Errorf("synonyms line %d: invalid rule %q", line, text)
+			}
+			explicit = append(explicit, [2][]string{from, to})
+			continue
+		}
+		groups = append(groups, splitList(text))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read synonyms: %w", err)
+	}
+	return groups, explicit, nil
+}
+
+// parseWordNetSynonyms groups the words of WordNet s(...) facts by synset.
+func parseWordNetSynonyms(r io.Reader) ([][]string, error) {
+	var order []string
+	synsets := make(map[string][]string)
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if !strings.HasPrefix(text, "s(") {
+			return nil, fmt.Errorf("synonyms line %d: expected s(...) fact", line)
+		}
+		id, rest, ok := strings.Cut(text[2:], ",")
+		if !ok {
+			return nil, fmt.Errorf("synonyms line %d: invalid fact", line)
+		}
+		start := strings.IndexByte(rest, '\'')
+		if start < 0 {
+			return nil, fmt.Errorf("synonyms line %d: missing quoted word", line)
+		}
+		word, ok := readQuoted(rest[start+1:])
+		if !ok {
+			return nil, fmt.Errorf("synonyms line %d: unterminated word", line)
+		}
+		if _, ok := synsets[id]; !ok {
+			order = append(order, id)
+		}
+		synsets[id] = append(synsets[id], word)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read synonyms: %w", err)
+	}
+
+	groups := make([][]string, len(order))
+	for i, id := range order {
+		groups[i] = synsets[id]
+	}
+	return groups, nil
+}
+
+// readQuoted reads a Prolog quoted atom up to its closing quote, where a
+// doubled quote stands for one quote character.
+func readQuoted(s string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\'' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '\'' {
+			b.WriteByte('\'')
+			i++
+			continue
+		}
+		return b.String(), true
+	}
+	return "", false
+}
+
+// LoadSynonyms reads synonym rules from a file. See ParseSynonyms.
+func LoadSynonyms(path, format string, expand bool, analyze func(string) []string) (*SynonymMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open synonyms: %w", err)
+	}
+	defer f.Close()
+	return ParseSynonyms(f, format, expand, analyze)
+}
+
+// SynonymFilter rewrites token sequences that match a rule in Map with the
+// rule's outputs. At each position the longest matching input wins.
+//
+// Filter stacks all outputs of a match on the positions starting at the
+// match: the first token of every output shares the match's first position,
+// the second tokens the next one, and so on. Tokens after the match are moved
+// to follow the longest output, so that output reads as a contiguous phrase.
+// Shorter alternatives are only contiguous in the sequences returned by
+// FilterGraph, which is what queries use. Every output token spans the
+// offsets of the whole match.
+type SynonymFilter struct {
+	Map *SynonymMap
+}
+
+// match returns the length and outputs of the longest rule matching tokens
+// at i. Matched tokens must be at consecutive positions.
+func (f *SynonymFilter) match(tokens []TokenPosition, i int) (int, [][]string) {
+	for n := min(f.Map.maxLen, len(tokens)-i); n > 0; n-- {
+		if tokens[i+n-1].Position != tokens[i].Position+uint64(n-1) {
+			continue
+		}
+		input := make([]string, n)
+		for j := range input {
+			input[j] = tokens[i+j].Token
+		}
+		if outputs, ok := f.Map.rules[synonymKey(input)]; ok {
+			return n, outputs
+		}
+	}
+	return 0, nil
+}
+
+// Filter implements TokenFilter.
+func (f *SynonymFilter) Filter(tokens []TokenPosition) []TokenPosition {
+	var out []TokenPosition
+	var shift int64
+	for i := 0; i < len(tokens); {
+		n, outputs := f.match(tokens, i)
+		if n == 0 {
+			t := tokens[i]
+			t.Position = shifted(t.Position, shift)
+			out = append(out, t)
+			i++
+			continue
+		}
+
+		start := shifted(tokens[i].Position, shift)
+		longest := 0
+		for _, output := range outputs {
+			longest = max(longest, len(output))
+		}
+		for j := 0; j < longest; j++ {
+			for _, output := range outputs {
+				if j < len(output) {
+					out = append(out, TokenPosition{
+						Token:    output[j],
+						Position: start + uint64(j),
+						Start:    tokens[i].Start,
+						End:      tokens[i+n-1].End,
+					})
+				}
+			}
+		}
+		shift += int64(longest - n)
+		i += n
+	}
+	return out
+}
+
+// FilterGraph implements GraphTokenFilter. Alternatives are ordered by the
+// choice made at each match, earliest match first, with outputs in rule
+// order; only the first maxGraphPaths are returned.
+func (f *SynonymFilter) FilterGraph(tokens []TokenPosition) [][]TokenPosition {
+	type path struct {
+		tokens []TokenPosition
+		shift  int64
+	}
+	paths := []path{{}}
+	for i := 0; i < len(tokens); {
+		n, outputs := f.match(tokens, i)
+		if n == 0 {
+			for k := range paths {
+				p := &paths[k]
+				t := tokens[i]
+				t.Position = shifted(t.Position, p.shift)
+				p.tokens = append(p.tokens, t)
+			}
+			i++
+			continue
+		}
+
+		var next []path
+		for _, p := range paths {
+			start := shifted(tokens[i].Position, p.shift)
+			for _, output := range outputs {
+				if len(next) == maxGraphPaths {
+					break
+				}
+				q := path{tokens: slices.Clone(p.tokens), shift: p.shift + int64(len(output)-n)}
+				for j, token := range output {
+					q.tokens = append(q.tokens, TokenPosition{
+						Token:    token,
+						Position: start + uint64(j),
+						Start:    tokens[i].Start,
+						End:      tokens[i+n-1].End,
+					})
+				}
+				next = append(next, q)
+			}
+		}
+		paths = next
+		i += n
+	}
+
+	out := make([][]TokenPosition, len(paths))
+	for k, p := range paths {
+		out[k] = p.tokens
+	}
+	return out
+}
+
+// shifted offsets a position by a signed amount.
+func shifted(pos uint64, shift int64) uint64 {
+	return uint64(int64(pos) + shift)
+}
@@ -0,0 +1,155 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func synonymAnalyzer(t *testing.T, options map[string]string) Analyzer {
+	t.Helper()
+	options["token_filters"] = "lowercase,synonym"
+	a, err := Build("custom", options)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	return a
+}
+
+func TestSynonymFilter_StacksMultiWordSynonyms(t *testing.T) {
+	a := synonymAnalyzer(t, map[string]string{"synonyms": "NYC, New York; laptop => notebook"})
+
+	tests := []struct {
+		text string
+		want []TokenPosition
+	}{
+		// The longer alternative sets the positions of the following tokens.
+		{"nyc pizza", []TokenPosition{
+			{Token: "nyc", Position: 0}, {Token: "new", Position: 0},
+			{Token: "york", Position: 1},
+			{Token: "pizza", Position: 2},
+		}},
+		{"new york pizza", []TokenPosition{
+			{Token: "nyc", Position: 0}, {Token: "new", Position: 0},
+			{Token: "york", Position: 1},
+			{Token: "pizza", Position: 2},
+		}},
+		// Explicit rules replace the input.
+		{"my laptop bag", []TokenPosition{
+			{Token: "my", Position: 0},
+			{Token: "notebook", Position: 1},
+			{Token: "bag", Position: 2},
+		}},
+		// Inputs must be at consecutive positions.
+		{"new and york", []TokenPosition{
+			{Token: "new", Position: 0}, {Token: "and", Position: 1}, {Token: "york", Position: 2},
+		}},
+	}
+	for _, tt := range tests {
+		if got := a.Analyze(tt.text); !reflect.DeepEqual(withoutOffsets(got), tt.want) {
+			t.Errorf("Analyze(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSynonymFilter_GraphAlternatives(t *testing.T) {
+	a := synonymAnalyzer(t, map[string]string{"synonyms": "nyc, new york; big apple => nyc"})
+
+	var got []string
+	for _, path := range AnalyzeGraph(a, "Big Apple pizza") {
+		var parts []string
+		for _, tp := range path {
+			parts = append(parts, tp.Token)
+		}
+		got = append(got, strings.Join(parts, " "))
+	}
+	want := []string{"nyc pizza"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	paths := AnalyzeGraph(a, "nyc pizza")
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 alternatives, got %v", paths)
+	}
+	// Each alternative has contiguous positions.
+	want2 := [][]TokenPosition{
+		{{Token: "nyc", Position: 0}, {Token: "pizza", Position: 1}},
+		{{Token: "new", Position: 0}, {Token: "york", Position: 1}, {Token: "pizza", Position: 2}},
+	}
+	for i := range paths {
+		paths[i] = withoutOffsets(paths[i])
+	}
+	if !reflect.DeepEqual(paths, want2) {
+		t.Errorf("got %v, want %v", paths, want2)
+	}
+}
+
+func TestSynonymFilter_GraphAlternativesLimit(t *testing.T) {
+	// Seven two-way groups give 128 combinations.
+	a := synonymAnalyzer(t, map[string]string{"synonyms": "a, a2; b, b2; c, c2; d, d2; e, e2; f, f2; g, g2"})
+
+	paths := AnalyzeGraph(a, "a b c d e f g")
+	if len(paths) != maxGraphPaths {
+		t.Fatalf("expected %d alternatives, got %d", maxGraphPaths, len(paths))
+	}
+	var first []string
+	for _, tp := range paths[0] {
+		first = append(first, tp.Token)
+	}
+	if got := strings.Join(first, " "); got != "a b c d e f g" {
+		t.Errorf("first alternative = %q, want the unexpanded text", got)
+	}
+	// The first choices are kept: the later half, which replaces a, is dropped.
+	for _, path := range paths {
+		if path[0].Token != "a" {
+			t.Fatalf("alternative %v should have been dropped", path)
+		}
+	}
+}
+
+func TestSynonymFilter_NoExpand(t *testing.T) {
+	a := synonymAnalyzer(t, map[string]string{"synonyms": "notebook, laptop", "expand": "false"})
+
+	got := a.Analyze("Laptop")
+	if len(got) != 1 || got[0].Token != "notebook" {
+		t.Errorf("got %v, want [notebook]", got)
+	}
+}
+
+func TestParseSynonyms_WordNetFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wn_s.pl")
+	data := `s(100001,1,'laptop',n,1,0).
+s(100001,2,'notebook computer',n,1,0).
+s(100002,1,'o''clock',n,1,0).
+s(100002,2,'hour',n,1,0).
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := synonymAnalyzer(t, map[string]string{"synonyms_path": path, "synonyms_format": "wordnet"})
+	var got []string
+	for _, tp := range a.Analyze("Laptop") {
+		got = append(got, tp.Token)
+	}
+	if want := []string{"laptop", "notebook", "computer"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseSynonyms_Errors(t *testing.T) {
+	for _, options := range []map[string]string{
+		{"token_filters": "synonym"},
+		{"token_filters": "synonym", "synonyms": "a => "},
+		{"token_filters": "synonym", "synonyms": "a, b", "synonyms_format": "nope"},
+		{"token_filters": "synonym", "synonyms": "s(1,1,laptop,n,1,0).", "synonyms_format": "wordnet"},
+		{"token_filters": "synonym", "synonyms_path": "/nonexistent/synonyms.txt"},
+	} {
+		if _, err := Build("custom", options); err == nil {
+			t.Errorf("expected error for %v", options)
+		}
+	}
+}
@@ -4,15 +4,20 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/RoaringBitmap/roaring"
 
 	"harshagw/postings/internal/analysis"
+	"harshagw/postings/internal/mapping"
 	"harshagw/postings/internal/segment"
+	"harshagw/postings/internal/similarity"
 	"harshagw/postings/internal/store"
 )
 
+// ScoringMode picks the index's similarity when Config.Similarity is nil.
 type ScoringMode int
 
 const (
@@ -29,10 +34,12 @@ type Index struct {
 	builder          *segment.Builder
 	epoch            uint64
 	pendingDeletions map[string]*roaring.Bitmap
+	translog         *translog
+	merges           *mergeScheduler
 
-	analyzer       analysis.Analyzer
+	mapping        *mapping.IndexMapping
 	flushThreshold int
-	scoringMode    ScoringMode
+	similarity     similarity.Similarity
 
 	closed bool
 }
@@ -40,8 +47,16 @@ type Index struct {
 type Config struct {
 	Dir            string
 	FlushThreshold int
-	Analyzer       analysis.Analyzer
-	ScoringMode    ScoringMode
+	Analyzer       analysis.Analyzer     // Default analyzer for unmapped fields
+	AnalyzerName   string                // Registered analyzer used instead of Analyzer, e.g. "english"
+	Mapping        *mapping.IndexMapping // Per-field analyzers; persisted on first open
+	Similarity     similarity.Similarity // Scores fields the mapping assigns no similarity
+	ScoringMode    ScoringMode           // Used when Similarity is nil
+	SyncPolicy     SyncPolicy            // When translog writes are fsynced
+	SyncInterval   time.Duration         // Fsync interval for SyncPeriodic
+
+	MergePolicy         MergePolicy // Background merge policy (nil disables)
+	MaxConcurrentMerges int         // Background merges run at once
 }
 
 func DefaultConfig(dir string) Config {
@@ -50,6 +65,11 @@ func DefaultConfig(dir string) Config {
 		FlushThreshold: 1000,
 		Analyzer:       analysis.NewSimple(),
 		ScoringMode:    ScoringBM25,
+		SyncPolicy:     SyncPeriodic,
+		SyncInterval:   time.Second,
+
+		MergePolicy:         DefaultTieredMergePolicy(),
+		MaxConcurrentMerges: 1,
 	}
 }
 
@@ -69,12 +89,24 @@ func New(config Config) (*Index, error) {
 		meta:             meta,
 		segments:         make([]*segment.Segment, 0),
 		pendingDeletions: make(map[string]*roaring.Bitmap),
-		analyzer:         config.Analyzer,
 		flushThreshold:   config.FlushThreshold,
-		scoringMode:      config.ScoringMode,
+		similarity:       config.Similarity,
+	}
+
+	idx.mapping, err = loadMapping(meta, config)
+	if err != nil {
+		meta.Close()
+		return nil, fmt.Errorf("failed to load mapping: %w", err)
 	}
 
-	idx.builder = segment.NewBuilder(idx.analyzer)
+	idx.builder = segment.NewBuilderWithMapping(idx.mapping)
+
+	if idx.similarity == nil {
+		idx.similarity = similarity.NewBM25()
+		if config.ScoringMode == ScoringTFIDF {
+			idx.similarity = similarity.TFIDF{}
+		}
+	}
 
 	if err := idx.loadSegments(); err != nil {
 		meta.Close()
@@ -83,9 +115,100 @@ func New(config Config) (*Index, error) {
 
 	idx.epoch, _ = meta.GetEpoch()
 
+	idx.translog, err = openTranslog(config.Dir, config.SyncPolicy, config.SyncInterval)
+	if err != nil {
+		idx.closeSegments()
+		meta.Close()
+		return nil, fmt.Errorf("failed to open translog: %w", err)
+	}
+
+	if err := idx.replayTranslog(); err != nil {
+		idx.translog.Close()
+		idx.closeSegments()
+		meta.Close()
+		return nil, fmt.Errorf("failed to replay translog: %w", err)
+	}
+
+	idx.merges = newMergeScheduler(idx, config.MergePolicy, config.MaxConcurrentMerges)
+	idx.merges.Trigger()
+
 	return idx, nil
 }
 
+// loadMapping returns the index's field mapping. A mapping stored by an
+// earlier open takes precedence; a configured mapping must match it so that
+// existing segments stay consistent with how queries are analyzed.
+func loadMapping(meta *store.Metadata, config Config) (*mapping.IndexMapping, error) {
+	// A named analyzer is persisted as the mapping's default analyzer.
+	if config.AnalyzerName != "" {
+		named := &mapping.IndexMapping{DefaultAnalyzer: config.AnalyzerName}
+		if config.Mapping != nil {
+			if def := config.Mapping.DefaultAnalyzer; def != "" && def != config.AnalyzerName {
+				return nil, fmt.Errorf("analyzer %q conflicts with mapping default analyzer %q",
+					config.AnalyzerName, def)
+			}
+			named.Fields = config.Mapping.Fields
+			named.DefaultSimilarity = config.Mapping.DefaultSimilarity
+			named.DefaultSimilarityOptions = config.Mapping.DefaultSimilarityOptions
+		}
+		config.Mapping = named
+	}
+
+	stored, err := meta.GetMapping()
+	if err != nil {
+		return nil, err
+	}
+
+	if stored != nil {
+		m, err := mapping.Parse(stored, config.Analyzer)
+		if err != nil {
+			return nil, err
+		}
+		if config.Mapping != nil && !config.Mapping.Equal(m) {
+			return nil, fmt.Errorf("configured mapping differs from the mapping stored in the index")
+		}
+		return m, nil
+	}
+
+	if config.Mapping == nil {
+		return mapping.New(config.Analyzer), nil
+	}
+
+	if err := config.Mapping.Compile(config.Analyzer); err != nil {
+		return nil, err
+	}
+	data, err := config.Mapping.JSON()
+	if err != nil {
+		return nil, err
+	}
+	err = meta.Update(func(tx *store.Tx) error {
+		return tx.SetMapping(data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return config.Mapping, nil
+}
+
+// Mapping returns the index's field mapping.
+func (idx *Index) Mapping() *mapping.IndexMapping {
+	return idx.mapping
+}
+
+// replayTranslog re-applies operations that were logged but not yet flushed
+// into a segment before the index was last closed or crashed.
+func (idx *Index) replayTranslog() error {
+	err := idx.translog.Replay(idx.applyEntry)
+	if err != nil {
+		return err
+	}
+
+	if idx.builder.NumDocs() >= uint64(idx.flushThreshold) {
+		return idx.flushInternal()
+	}
+	return nil
+}
+
 // loadSegments loads all segments from the metadata store.
 func (idx *Index) loadSegments() error {
 	segmentIDs, err := idx.meta.GetSegments()
@@ -93,6 +216,10 @@ func (idx *Index) loadSegments() error {
 		return err
 	}
 
+	if err := idx.removeOrphanSegments(segmentIDs); err != nil {
+		return err
+	}
+
 	for _, segID := range segmentIDs {
 		segPath := filepath.Join(idx.dir, segID+".seg")
 		seg, err := segment.Open(segPath, segID)
@@ -105,8 +232,80 @@ func (idx *Index) loadSegments() error {
 	return nil
 }
 
+// removeOrphanSegments deletes segment files that are not in the committed
+// segment list, such as merge inputs whose removal was still deferred by an
+// open snapshot when the process exited, or partially written segments.
+func (idx *Index) removeOrphanSegments(segmentIDs []string) error {
+	live := make(map[string]bool, len(segmentIDs))
+	for _, segID := range segmentIDs {
+		live[segID+".seg"] = true
+	}
+
+	entries, err := os.ReadDir(idx.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".seg") && !strings.HasSuffix(name, ".seg.tmp") {
+			continue
+		}
+		if live[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(idx.dir, name)); err != nil {
+			return fmt.Errorf("failed to remove orphan segment %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
 // Index indexes a document.
 func (idx *Index) Index(docID string, doc map[string]any) error {
+	return idx.applyEntries([]translogEntry{{Op: translogOpIndex, ID: docID, Doc: doc}})
+}
+
+// Delete deletes a document.
+func (idx *Index) Delete(docID string) error {
+	return idx.applyEntries([]translogEntry{{Op: translogOpDelete, ID: docID}})
+}
+
+// Batch groups index and delete operations that are logged together.
+type Batch struct {
+	entries []translogEntry
+}
+
+// NewBatch creates an empty batch.
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Index adds an index operation to the batch.
+func (b *Batch) Index(docID string, doc map[string]any) {
+	b.entries = append(b.entries, translogEntry{Op: translogOpIndex, ID: docID, Doc: doc})
+}
+
+// Delete adds a delete operation to the batch.
+func (b *Batch) Delete(docID string) {
+	b.entries = append(b.entries, translogEntry{Op: translogOpDelete, ID: docID})
+}
+
+// Size returns the number of operations in the batch.
+func (b *Batch) Size() int {
+	return len(b.entries)
+}
+
+// Apply applies all operations in the batch in order.
+// With SyncPerBatch the translog is fsynced once for the whole batch.
+func (idx *Index) Apply(b *Batch) error {
+	if b == nil || len(b.entries) == 0 {
+		return nil
+	}
+	return idx.applyEntries(b.entries)
+}
+
+// applyEntries logs entries to the translog and then applies them to the builder.
+func (idx *Index) applyEntries(entries []translogEntry) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
@@ -114,9 +313,13 @@ func (idx *Index) Index(docID string, doc map[string]any) error {
 		return fmt.Errorf("index is closed")
 	}
 
-	idx.builder.Delete(docID)
-	idx.markObsoletes([]string{docID})
-	idx.builder.Add(docID, doc)
+	if err := idx.translog.Append(entries); err != nil {
+		return fmt.Errorf("failed to write translog: %w", err)
+	}
+
+	for _, entry := range entries {
+		idx.applyEntry(entry)
+	}
 
 	if idx.builder.NumDocs() >= uint64(idx.flushThreshold) {
 		return idx.flushInternal()
@@ -125,17 +328,13 @@ func (idx *Index) Index(docID string, doc map[string]any) error {
 	return nil
 }
 
-func (idx *Index) Delete(docID string) error {
-	idx.mu.Lock()
-	defer idx.mu.Unlock()
-
-	if idx.closed {
-		return fmt.Errorf("index is closed")
+// applyEntry applies a single operation to the in-memory state.
+func (idx *Index) applyEntry(entry translogEntry) {
+	idx.builder.Delete(entry.ID)
+	idx.markObsoletes([]string{entry.ID})
+	if entry.Op == translogOpIndex {
+		idx.builder.Add(entry.ID, entry.Doc)
 	}
-
-	idx.builder.Delete(docID)
-	idx.markObsoletes([]string{docID})
-	return nil
 }
 
 // markObsoletes updates deletion bitmaps for docs in persisted segments.
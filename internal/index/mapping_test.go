@@ -0,0 +1,74 @@
+package index
+
+import (
+	"testing"
+
+	"harshagw/postings/internal/mapping"
+)
+
+func TestMapping_PersistedAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := mapping.Parse([]byte(`{"fields": {"sku": {"analyzer": "keyword"}}}`), nil)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	config := DefaultConfig(dir)
+	config.Mapping = m
+	idx, err := New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	idx.Index("doc1", map[string]any{"sku": "AB-12"})
+	idx.Flush()
+	idx.Close()
+
+	// Reopening without a mapping uses the stored one.
+	idx = openTestIndex(t, dir, SyncPeriodic)
+	snap, _ := idx.Snapshot()
+	if got := snap.AnalyzerFor("sku").Analyze("AB-12"); len(got) != 1 || got[0].Token != "AB-12" {
+		t.Errorf("expected stored keyword mapping, got %v", got)
+	}
+	snap.Close()
+	idx.Close()
+
+	other, _ := mapping.Parse([]byte(`{"fields": {"sku": {"analyzer": "whitespace"}}}`), nil)
+	config.Mapping = other
+	if idx, err := New(config); err == nil {
+		idx.Close()
+		t.Fatal("expected error opening index with a different mapping")
+	}
+}
+
+func TestMapping_AnalyzerNamePersisted(t *testing.T) {
+	dir := t.TempDir()
+
+	config := DefaultConfig(dir)
+	config.AnalyzerName = "english"
+	idx, err := New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	idx.Close()
+
+	// Reopening without a name keeps the stored analyzer.
+	idx = openTestIndex(t, dir, SyncPeriodic)
+	if got := idx.Mapping().DefaultAnalyzer; got != "english" {
+		t.Errorf("DefaultAnalyzer = %q, want english", got)
+	}
+	idx.Close()
+
+	config.AnalyzerName = "standard"
+	if idx, err := New(config); err == nil {
+		idx.Close()
+		t.Fatal("expected error opening index with a different analyzer")
+	}
+
+	config.AnalyzerName = "nope"
+	config.Dir = t.TempDir()
+	if idx, err := New(config); err == nil {
+		idx.Close()
+		t.Fatal("expected error for unknown analyzer")
+	}
+}
@@ -4,21 +4,75 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/RoaringBitmap/roaring"
+
 	"harshagw/postings/internal/segment"
 	"harshagw/postings/internal/store"
 )
 
-// Merge merges multiple segments into one.
+// mergeInput is a segment being merged with the deletions seen at merge start.
+type mergeInput struct {
+	seg     *segment.Segment
+	deleted *roaring.Bitmap
+}
+
+// Merge merges segments into one. Merging a single segment rewrites it
+// without its deleted documents.
+//
+// The new segment is built without holding the index lock; indexing and
+// snapshots continue meanwhile. Deletions that land on the input segments
+// during the merge are carried over to the new segment on commit.
 func (idx *Index) Merge(segmentIDs []string) error {
+	if len(segmentIDs) == 0 {
+		return fmt.Errorf("no segments to merge")
+	}
+
+	inputs, newSegmentID, err := idx.prepareMerge(segmentIDs)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, in := range inputs {
+			in.seg.DecRef()
+		}
+	}()
+
+	segs := make([]*segment.Segment, len(inputs))
+	deleted := make([]*roaring.Bitmap, len(inputs))
+	for i, in := range inputs {
+		segs[i] = in.seg
+		deleted[i] = in.deleted
+	}
+
+	segPath, docMaps, err := segment.Merge(segs, deleted, idx.dir, newSegmentID)
+	if err != nil {
+		return err
+	}
+
+	newSeg, err := segment.Open(segPath, newSegmentID)
+	if err != nil {
+		os.Remove(segPath)
+		return err
+	}
+
+	if err := idx.commitMerge(inputs, newSeg, docMaps); err != nil {
+		newSeg.MarkObsolete()
+		newSeg.DecRef()
+		return err
+	}
+
+	return nil
+}
+
+// prepareMerge resolves the input segments, captures their deletions and
+// reserves an ID for the merged segment. It takes a reference on each input
+// so they stay mapped until the merge finishes.
+func (idx *Index) prepareMerge(segmentIDs []string) ([]mergeInput, string, error) {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
 	if idx.closed {
-		return fmt.Errorf("index is closed")
-	}
-
-	if len(segmentIDs) < 2 {
-		return fmt.Errorf("need at least 2 segments to merge")
+		return nil, "", fmt.Errorf("index is closed")
 	}
 
 	idSet := make(map[string]bool)
@@ -26,89 +80,112 @@ func (idx *Index) Merge(segmentIDs []string) error {
 		idSet[id] = true
 	}
 
-	var segsToMerge []*SegmentSnapshot
+	var inputs []mergeInput
 	for _, seg := range idx.segments {
 		if idSet[seg.ID()] {
 			deleted, err := idx.getDeletions(seg.ID())
 			if err != nil {
-				return err
+				return nil, "", err
 			}
-			segsToMerge = append(segsToMerge, &SegmentSnapshot{seg: seg, deleted: deleted})
+			inputs = append(inputs, mergeInput{seg: seg, deleted: deleted})
 		}
 	}
 
-	if len(segsToMerge) != len(segmentIDs) {
-		return fmt.Errorf("some segments not found")
+	if len(inputs) != len(idSet) {
+		return nil, "", fmt.Errorf("some segments not found")
 	}
 
-	builder := segment.NewBuilder(idx.analyzer)
-
-	for _, ss := range segsToMerge {
-		seg := ss.Segment()
-		deleted := ss.Deleted()
-		for docNum := uint64(0); docNum < seg.NumDocs(); docNum++ {
-			if deleted != nil && deleted.Contains(uint32(docNum)) {
-				continue
-			}
-
-			doc, err := seg.LoadDoc(docNum)
-			if err != nil {
-				continue
-			}
-
-			extID, ok := seg.ExternalID(docNum)
-			if !ok {
-				continue
-			}
-
-			builder.Add(extID, doc)
-		}
-	}
-
-	currentEpoch, err := idx.meta.GetEpoch()
-	if err != nil {
+	// Reserve the ID now so a flush during the merge can't claim it.
+	var epoch uint64
+	err := idx.meta.Update(func(tx *store.Tx) error {
+		var err error
+		epoch, err = tx.IncrementEpoch()
 		return err
+	})
+	if err != nil {
+		return nil, "", err
 	}
-	newSegmentID := fmt.Sprintf("%012d", currentEpoch+1)
+	idx.epoch = epoch
 
-	segPath, err := builder.Build(idx.dir, newSegmentID)
-	if err != nil {
-		return err
+	for _, in := range inputs {
+		in.seg.AddRef()
 	}
 
-	newSeg, err := segment.Open(segPath, newSegmentID)
-	if err != nil {
-		return err
+	return inputs, fmt.Sprintf("%012d", epoch), nil
+}
+
+// commitMerge swaps the inputs for the merged segment. Documents deleted from
+// the inputs since prepareMerge are marked deleted in the merged segment.
+// docMaps[i] maps docNums of inputs[i] to docNums in newSeg.
+func (idx *Index) commitMerge(inputs []mergeInput, newSeg *segment.Segment, docMaps [][]uint64) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.closed {
+		return fmt.Errorf("index is closed")
 	}
 
-	newSegments := make([]*segment.Segment, 0, len(idx.segments)-len(segmentIDs)+1)
-	removedPaths := make([]string, 0, len(segmentIDs))
+	inputSet := make(map[*segment.Segment]bool, len(inputs))
+	for _, in := range inputs {
+		inputSet[in.seg] = true
+	}
 
+	newSegments := make([]*segment.Segment, 0, len(idx.segments)-len(inputs)+1)
 	for _, seg := range idx.segments {
-		if idSet[seg.ID()] {
-			removedPaths = append(removedPaths, seg.Path())
-			seg.Close()
-		} else {
+		if !inputSet[seg] {
 			newSegments = append(newSegments, seg)
 		}
 	}
+	if len(newSegments) != len(idx.segments)-len(inputs) {
+		return fmt.Errorf("merge inputs changed during merge")
+	}
 	newSegments = append(newSegments, newSeg)
 
+	// Carry over deletions that happened while the merge was running.
+	newDeleted := roaring.New()
+	for i, in := range inputs {
+		current, err := idx.getDeletions(in.seg.ID())
+		if err != nil {
+			return err
+		}
+		iter := roaring.AndNot(current, in.deleted).Iterator()
+		for iter.HasNext() {
+			docNum := iter.Next()
+			if int(docNum) >= len(docMaps[i]) {
+				continue
+			}
+			if newDocNum := docMaps[i][docNum]; newDocNum != segment.DocDropped {
+				newDeleted.Add(uint32(newDocNum))
+			}
+		}
+	}
+
 	var epoch uint64
-	err = idx.meta.Update(func(tx *store.Tx) error {
+	err := idx.meta.Update(func(tx *store.Tx) error {
+		var err error
 		epoch, err = tx.IncrementEpoch()
 		if err != nil {
 			return err
 		}
 
-		for docNum, externalID := range builder.DocIDs {
-			if err := tx.SetDocMapping(externalID, newSegmentID, uint64(docNum)); err != nil {
+		for docNum := uint64(0); docNum < newSeg.NumDocs(); docNum++ {
+			if newDeleted.Contains(uint32(docNum)) {
+				continue
+			}
+			externalID, _ := newSeg.ExternalID(docNum)
+			if err := tx.SetDocMapping(externalID, newSeg.ID(), docNum); err != nil {
+				return err
+			}
+		}
+
+		for _, in := range inputs {
+			if err := tx.DeleteDeletions(in.seg.ID()); err != nil {
 				return err
 			}
 		}
 
-		for _, segID := range segmentIDs {
-			if err := tx.DeleteDeletions(segID); err != nil {
+		if !newDeleted.IsEmpty() {
+			if err := tx.SetDeletions(newSeg.ID(), newDeleted); err != nil {
 				return err
 			}
 		}
@@ -120,15 +197,22 @@ func (idx *Index) Merge(segmentIDs []string) error {
 		return tx.SetSegments(segmentIDList)
 	})
 	if err != nil {
-		newSeg.Close()
 		return err
 	}
 
 	idx.segments = newSegments
 	idx.epoch = epoch
 
-	for _, path := range removedPaths {
-		os.Remove(path)
+	// Pending deletions on the inputs are now persisted on the new segment.
+	for _, in := range inputs {
+		delete(idx.pendingDeletions, in.seg.ID())
+	}
+
+	// Merged segments are unmapped and deleted once the last snapshot
+	// referencing them is closed.
+	for _, in := range inputs {
+		in.seg.MarkObsolete()
+		in.seg.DecRef()
 	}
 
 	return nil
@@ -0,0 +1,139 @@
+package index
+
+import (
+	"math"
+	"sort"
+)
+
+// MergeCandidate describes a segment considered by a MergePolicy.
+type MergeCandidate struct {
+	ID         string
+	SizeBytes  int64
+	NumDocs    uint64
+	NumDeleted uint64
+}
+
+// DeletedRatio returns the fraction of documents in the segment that are deleted.
+func (c MergeCandidate) DeletedRatio() float64 {
+	if c.NumDocs == 0 {
+		return 0
+	}
+	return float64(c.NumDeleted) / float64(c.NumDocs)
+}
+
+// LiveSize estimates the size in bytes of the segment's non-deleted documents.
+func (c MergeCandidate) LiveSize() int64 {
+	if c.NumDocs == 0 {
+		return 0
+	}
+	return int64(float64(c.SizeBytes) * (1 - c.DeletedRatio()))
+}
+
+// MergePolicy selects groups of segments to merge.
+// Each returned group becomes one merge; groups must not overlap.
+type MergePolicy interface {
+	FindMerges(segments []MergeCandidate) [][]string
+}
+
+// NoMergePolicy never selects merges. Segments are only merged via ForceMerge/Merge.
+type NoMergePolicy struct{}
+
+// FindMerges implements MergePolicy.
+func (NoMergePolicy) FindMerges([]MergeCandidate) [][]string { return nil }
+
+// TieredMergePolicy groups segments into tiers of roughly equal size and
+// merges the smallest segments of a tier once it holds too many.
+type TieredMergePolicy struct {
+	SegmentsPerTier       int     // Segments allowed per tier before merging
+	MaxMergeAtOnce        int     // Max segments merged into one
+	FloorSegmentBytes     int64   // Smaller segments are treated as this size
+	MaxMergedSegmentBytes int64   // Max size of a merged segment
+	MaxDeletedRatio       float64 // Segments with more deletions are rewritten
+}
+
+// DefaultTieredMergePolicy returns a TieredMergePolicy with sensible defaults.
+func DefaultTieredMergePolicy() *TieredMergePolicy {
+	return &TieredMergePolicy{
+		SegmentsPerTier:       10,
+		MaxMergeAtOnce:        10,
+		FloorSegmentBytes:     2 << 20,
+		MaxMergedSegmentBytes: 5 << 30,
+		MaxDeletedRatio:       0.33,
+	}
+}
+
+// FindMerges implements MergePolicy.
+func (p *TieredMergePolicy) FindMerges(segments []MergeCandidate) [][]string {
+	perTier := max(p.SegmentsPerTier, 2)
+	atOnce := max(p.MaxMergeAtOnce, 2)
+	floor := max(p.FloorSegmentBytes, 1)
+
+	var merges [][]string
+	tiers := make(map[int][]MergeCandidate)
+
+	for _, seg := range segments {
+		// Rewrite segments with too many deletions on their own to reclaim space.
+		if p.MaxDeletedRatio > 0 && seg.DeletedRatio() > p.MaxDeletedRatio {
+			merges = append(merges, []string{seg.ID})
+			continue
+		}
+		// Segments at half the max size can't be merged into anything useful.
+		if p.MaxMergedSegmentBytes > 0 && seg.LiveSize() >= p.MaxMergedSegmentBytes/2 {
+			continue
+		}
+		tier := tierOf(max(seg.LiveSize(), floor), floor, int64(perTier))
+		tiers[tier] = append(tiers[tier], seg)
+	}
+
+	tierNums := make([]int, 0, len(tiers))
+	for tier := range tiers {
+		tierNums = append(tierNums, tier)
+	}
+	sort.Ints(tierNums)
+
+	for _, tier := range tierNums {
+		segs := tiers[tier]
+		if len(segs) < perTier {
+			continue
+		}
+
+		sort.SliceStable(segs, func(i, j int) bool {
+			return segs[i].LiveSize() < segs[j].LiveSize()
+		})
+
+		for len(segs) >= perTier {
+			var group []string
+			var size int64
+			for _, seg := range segs {
+				if len(group) == atOnce {
+					break
+				}
+				if p.MaxMergedSegmentBytes > 0 && len(group) > 0 && size+seg.LiveSize() > p.MaxMergedSegmentBytes {
+					break
+				}
+				group = append(group, seg.ID)
+				size += seg.LiveSize()
+			}
+			if len(group) < 2 {
+				break
+			}
+			merges = append(merges, group)
+			segs = segs[len(group):]
+		}
+	}
+
+	return merges
+}
+
+// tierOf returns the tier for a segment of the given size, where tier n
+// holds sizes in [floor*perTier^n, floor*perTier^(n+1)).
+func tierOf(size, floor, perTier int64) int {
+	tier := 0
+	for bound := floor * perTier; size >= bound; bound *= perTier {
+		tier++
+		if bound > math.MaxInt64/perTier {
+			break
+		}
+	}
+	return tier
+}
@@ -0,0 +1,229 @@
+package index
+
+import (
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func smallSegments(n int, size int64) []MergeCandidate {
+	segs := make([]MergeCandidate, n)
+	for i := range segs {
+		segs[i] = MergeCandidate{ID: fmt.Sprintf("seg%d", i), SizeBytes: size, NumDocs: 10}
+	}
+	return segs
+}
+
+func TestTieredMergePolicy_BelowTierLimit(t *testing.T) {
+	p := DefaultTieredMergePolicy()
+	merges := p.FindMerges(smallSegments(9, 1024))
+	if len(merges) != 0 {
+		t.Errorf("expected no merges below SegmentsPerTier, got %v", merges)
+	}
+}
+
+func TestTieredMergePolicy_MergesFullTier(t *testing.T) {
+	p := DefaultTieredMergePolicy()
+	merges := p.FindMerges(smallSegments(10, 1024))
+	if len(merges) != 1 || len(merges[0]) != 10 {
+		t.Fatalf("expected one merge of 10 segments, got %v", merges)
+	}
+}
+
+func TestTieredMergePolicy_RespectsMaxMergeAtOnce(t *testing.T) {
+	p := DefaultTieredMergePolicy()
+	p.SegmentsPerTier = 3
+	p.MaxMergeAtOnce = 3
+	merges := p.FindMerges(smallSegments(7, 1024))
+	if len(merges) != 2 {
+		t.Fatalf("expected 2 merges, got %v", merges)
+	}
+	for _, m := range merges {
+		if len(m) != 3 {
+			t.Errorf("expected 3 segments per merge, got %v", m)
+		}
+	}
+}
+
+func TestTieredMergePolicy_SeparatesTiers(t *testing.T) {
+	p := DefaultTieredMergePolicy()
+	p.SegmentsPerTier = 3
+	p.FloorSegmentBytes = 100
+
+	segs := []MergeCandidate{
+		{ID: "a", SizeBytes: 100, NumDocs: 1},
+		{ID: "b", SizeBytes: 100, NumDocs: 1},
+		{ID: "big1", SizeBytes: 10000, NumDocs: 1},
+		{ID: "big2", SizeBytes: 10000, NumDocs: 1},
+	}
+	if merges := p.FindMerges(segs); len(merges) != 0 {
+		t.Errorf("expected no merges across tiers, got %v", merges)
+	}
+}
+
+func TestTieredMergePolicy_MaxMergedSegmentSize(t *testing.T) {
+	p := DefaultTieredMergePolicy()
+	p.SegmentsPerTier = 2
+	p.MaxMergedSegmentBytes = 1000
+
+	segs := []MergeCandidate{
+		{ID: "a", SizeBytes: 600, NumDocs: 1},
+		{ID: "b", SizeBytes: 600, NumDocs: 1},
+	}
+	if merges := p.FindMerges(segs); len(merges) != 0 {
+		t.Errorf("expected large segments to be skipped, got %v", merges)
+	}
+}
+
+func TestTieredMergePolicy_DeletionRatio(t *testing.T) {
+	p := DefaultTieredMergePolicy()
+	segs := []MergeCandidate{
+		{ID: "clean", SizeBytes: 1024, NumDocs: 10, NumDeleted: 1},
+		{ID: "dirty", SizeBytes: 1024, NumDocs: 10, NumDeleted: 5},
+	}
+	merges := p.FindMerges(segs)
+	if len(merges) != 1 || !slices.Equal(merges[0], []string{"dirty"}) {
+		t.Errorf("expected only dirty segment to be rewritten, got %v", merges)
+	}
+}
+
+func waitForMerges(t *testing.T, idx *Index) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		stats := idx.MergeStats()
+		if len(stats.Pending) == 0 && len(stats.Running) == 0 && stats.Completed > 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("merges did not finish: %+v", idx.MergeStats())
+}
+
+// waitForIdle waits until the merge scheduler has handled every trigger and
+// has nothing pending or running.
+func waitForIdle(t *testing.T, idx *Index) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		idx.merges.waitIdle()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("merge scheduler did not become idle: %+v", idx.MergeStats())
+	}
+}
+
+func TestMergeScheduler_MergesAfterFlush(t *testing.T) {
+	config := DefaultConfig(t.TempDir())
+	config.FlushThreshold = 2
+	config.MergePolicy = &TieredMergePolicy{SegmentsPerTier: 4, MaxMergeAtOnce: 4, FloorSegmentBytes: 1 << 20}
+	idx, err := New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	for i := 0; i < 8; i++ {
+		idx.Index(fmt.Sprintf("doc%d", i), map[string]any{"title": "hello"})
+	}
+
+	waitForMerges(t, idx)
+
+	if stats := idx.MergeStats(); stats.Failed != 0 {
+		t.Fatalf("unexpected failed merges: %v", stats.LastError)
+	}
+	if n := idx.NumSegments(); n >= 4 {
+		t.Errorf("expected background merges to reduce segments, got %d", n)
+	}
+
+	snap, _ := idx.Snapshot()
+	defer snap.Close()
+	if snap.TotalDocs() != 8 {
+		t.Errorf("expected 8 docs after merge, got %d", snap.TotalDocs())
+	}
+}
+
+func TestMergeScheduler_NilPolicyDisablesMerges(t *testing.T) {
+	config := DefaultConfig(t.TempDir())
+	config.FlushThreshold = 1
+	config.MergePolicy = nil
+	idx, err := New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	for i := 0; i < 12; i++ {
+		idx.Index(fmt.Sprintf("doc%d", i), map[string]any{"title": "hello"})
+	}
+	waitForIdle(t, idx)
+
+	if n := idx.NumSegments(); n != 12 {
+		t.Errorf("expected 12 segments without merge policy, got %d", n)
+	}
+	if stats := idx.MergeStats(); stats.Completed != 0 {
+		t.Errorf("expected no merges, got %d", stats.Completed)
+	}
+}
+
+// failingMergePolicy merges every segment together with one that does not
+// exist, so each merge it selects fails.
+type failingMergePolicy struct {
+	calls atomic.Int64
+}
+
+func (p *failingMergePolicy) FindMerges(segments []MergeCandidate) [][]string {
+	p.calls.Add(1)
+	if len(segments) == 0 {
+		return nil
+	}
+	ids := []string{"missing"}
+	for _, s := range segments {
+		ids = append(ids, s.ID)
+	}
+	return [][]string{ids}
+}
+
+func TestMergeScheduler_DoesNotRetryFailedMerge(t *testing.T) {
+	config := DefaultConfig(t.TempDir())
+	config.FlushThreshold = 1000
+	policy := &failingMergePolicy{}
+	config.MergePolicy = policy
+	idx, err := New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"title": "hello"})
+	idx.Flush()
+	// The scheduler only becomes idle if it stops retrying the failed merge.
+	waitForIdle(t, idx)
+
+	stats := idx.MergeStats()
+	if stats.Failed != 1 || stats.LastError == nil {
+		t.Fatalf("expected one failed merge, got %d (%v)", stats.Failed, stats.LastError)
+	}
+	calls := policy.calls.Load()
+	idx.merges.Trigger()
+	waitForIdle(t, idx)
+	if n := policy.calls.Load(); n != calls+1 {
+		t.Errorf("expected one policy call per trigger after a failure: %d calls, then %d", calls, n)
+	}
+	if stats := idx.MergeStats(); stats.Failed != 1 {
+		t.Errorf("expected the failed merge not to be retried, got %d failures", stats.Failed)
+	}
+
+	// A new segment changes the set, so the merge is tried again.
+	idx.Index("doc2", map[string]any{"title": "hello"})
+	idx.Flush()
+	waitForIdle(t, idx)
+	if stats := idx.MergeStats(); stats.Failed != 2 {
+		t.Errorf("expected the merge to be retried once after a flush, got %d failures", stats.Failed)
+	}
+}
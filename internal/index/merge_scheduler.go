@@ -0,0 +1,301 @@
+package index
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MergeState is the state of a scheduled merge.
+type MergeState int
+
+const (
+	MergePending MergeState = iota
+	MergeRunning
+)
+
+func (s MergeState) String() string {
+	switch s {
+	case MergePending:
+		return "pending"
+	case MergeRunning:
+		return "running"
+	default:
+		return "unknown"
+	}
+}
+
+// MergeInfo describes a merge selected by the merge policy.
+type MergeInfo struct {
+	ID        uint64
+	Segments  []string
+	SizeBytes int64
+	State     MergeState
+	Started   time.Time // zero while pending
+}
+
+// MergeStats reports the state of the background merge scheduler.
+type MergeStats struct {
+	Pending   []MergeInfo
+	Running   []MergeInfo
+	Completed uint64
+	Failed    uint64
+	LastError error
+}
+
+// mergeScheduler runs merges selected by a MergePolicy in the background.
+// It is woken after each flush and runs up to maxConcurrent merges at once.
+type mergeScheduler struct {
+	idx           *Index
+	policy        MergePolicy
+	maxConcurrent int
+
+	mu        sync.Mutex
+	nextID    uint64
+	pending   []*MergeInfo
+	running   map[uint64]*MergeInfo
+	claimed   map[string]bool // segment IDs in pending or running merges
+	completed uint64
+	failed    uint64
+	lastErr   error
+
+	// Segments of failed merges are left out of new merges until the set
+	// of segments changes, so a merge that keeps failing is not retried in
+	// a loop.
+	failedSegs map[string]bool
+	segmentSet string // the segment IDs seen by the last findMerges
+
+	// triggered counts calls to Trigger and scanned is the count seen by
+	// the last finished scan; idle is broadcast after each scan.
+	triggered uint64
+	scanned   uint64
+	idle      *sync.Cond
+
+	// exclusive is held shared by background merges and exclusively by
+	// ForceMerge so the two never pick overlapping segments.
+	exclusive sync.RWMutex
+
+	trigger   chan struct{}
+	stop      chan struct{}
+	closeOnce sync.Once
+	loop      sync.WaitGroup
+	merges    sync.WaitGroup
+}
+
+func newMergeScheduler(idx *Index, policy MergePolicy, maxConcurrent int) *mergeScheduler {
+	if policy == nil {
+		policy = NoMergePolicy{}
+	}
+	ms := &mergeScheduler{
+		idx:           idx,
+		policy:        policy,
+		maxConcurrent: max(maxConcurrent, 1),
+		running:       make(map[uint64]*MergeInfo),
+		claimed:       make(map[string]bool),
+		failedSegs:    make(map[string]bool),
+		trigger:       make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+	}
+	ms.idle = sync.NewCond(&ms.mu)
+	ms.loop.Add(1)
+	go ms.run()
+	return ms
+}
+
+// Trigger asks the scheduler to look for new merges. It never blocks.
+func (ms *mergeScheduler) Trigger() {
+	ms.mu.Lock()
+	ms.triggered++
+	ms.mu.Unlock()
+	ms.wake()
+}
+
+func (ms *mergeScheduler) wake() {
+	select {
+	case ms.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (ms *mergeScheduler) run() {
+	defer ms.loop.Done()
+	for {
+		select {
+		case <-ms.stop:
+			return
+		case <-ms.trigger:
+			ms.mu.Lock()
+			gen := ms.triggered
+			ms.mu.Unlock()
+
+			ms.findMerges()
+			ms.startMerges()
+
+			ms.mu.Lock()
+			ms.scanned = gen
+			ms.idle.Broadcast()
+			ms.mu.Unlock()
+		}
+	}
+}
+
+// findMerges asks the policy for merges among segments not already claimed.
+func (ms *mergeScheduler) findMerges() {
+	candidates, err := ms.idx.mergeCandidates()
+	if err != nil {
+		ms.mu.Lock()
+		ms.lastErr = err
+		ms.mu.Unlock()
+		return
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ids := make([]string, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.ID
+	}
+	slices.Sort(ids)
+	if set := strings.Join(ids, ","); set != ms.segmentSet {
+		ms.segmentSet = set
+		clear(ms.failedSegs)
+	}
+
+	sizes := make(map[string]int64, len(candidates))
+	free := candidates[:0]
+	for _, c := range candidates {
+		sizes[c.ID] = c.SizeBytes
+		if !ms.claimed[c.ID] && !ms.failedSegs[c.ID] {
+			free = append(free, c)
+		}
+	}
+
+	for _, segIDs := range ms.policy.FindMerges(free) {
+		if len(segIDs) == 0 || slices.ContainsFunc(segIDs, func(id string) bool { return ms.failedSegs[id] }) {
+			continue
+		}
+		info := &MergeInfo{ID: ms.nextID, Segments: segIDs, State: MergePending}
+		ms.nextID++
+		for _, id := range segIDs {
+			ms.claimed[id] = true
+			info.SizeBytes += sizes[id]
+		}
+		ms.pending = append(ms.pending, info)
+	}
+}
+
+// startMerges starts pending merges up to the concurrency limit.
+func (ms *mergeScheduler) startMerges() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for len(ms.pending) > 0 && len(ms.running) < ms.maxConcurrent {
+		info := ms.pending[0]
+		ms.pending = ms.pending[1:]
+		info.State = MergeRunning
+		info.Started = time.Now()
+		ms.running[info.ID] = info
+
+		ms.merges.Add(1)
+		go ms.runMerge(info)
+	}
+}
+
+func (ms *mergeScheduler) runMerge(info *MergeInfo) {
+	defer ms.merges.Done()
+
+	ms.exclusive.RLock()
+	err := ms.idx.Merge(info.Segments)
+	ms.exclusive.RUnlock()
+
+	ms.mu.Lock()
+	delete(ms.running, info.ID)
+	for _, id := range info.Segments {
+		delete(ms.claimed, id)
+	}
+	if err != nil {
+		ms.failed++
+		ms.lastErr = err
+		for _, id := range info.Segments {
+			ms.failedSegs[id] = true
+		}
+	} else {
+		ms.completed++
+	}
+	// Start queued merges and re-evaluate: the merged segment may now
+	// complete a larger tier. The trigger is counted before the merge
+	// leaves running so waitIdle never sees an idle gap in between.
+	ms.triggered++
+	ms.mu.Unlock()
+	ms.wake()
+}
+
+// waitIdle blocks until every Trigger so far has been handled and no merge
+// is pending or running. It must not be called after Close.
+func (ms *mergeScheduler) waitIdle() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for ms.scanned != ms.triggered || len(ms.pending) > 0 || len(ms.running) > 0 {
+		ms.idle.Wait()
+	}
+}
+
+// lockExclusive waits for running merges, drops pending ones and blocks
+// new ones until unlockExclusive.
+func (ms *mergeScheduler) lockExclusive() {
+	ms.exclusive.Lock()
+
+	ms.mu.Lock()
+	for _, info := range ms.pending {
+		for _, id := range info.Segments {
+			delete(ms.claimed, id)
+		}
+	}
+	ms.pending = nil
+	ms.idle.Broadcast()
+	ms.mu.Unlock()
+}
+
+func (ms *mergeScheduler) unlockExclusive() {
+	ms.exclusive.Unlock()
+}
+
+// Stats returns a copy of the scheduler state.
+func (ms *mergeScheduler) Stats() MergeStats {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	stats := MergeStats{
+		Pending:   make([]MergeInfo, 0, len(ms.pending)),
+		Running:   make([]MergeInfo, 0, len(ms.running)),
+		Completed: ms.completed,
+		Failed:    ms.failed,
+		LastError: ms.lastErr,
+	}
+	for _, info := range ms.pending {
+		stats.Pending = append(stats.Pending, *info)
+	}
+	for _, info := range ms.running {
+		stats.Running = append(stats.Running, *info)
+	}
+	sort.Slice(stats.Running, func(i, j int) bool {
+		return stats.Running[i].ID < stats.Running[j].ID
+	})
+	return stats
+}
+
+// Close stops the scheduler, drops pending merges and waits for running ones.
+func (ms *mergeScheduler) Close() {
+	ms.closeOnce.Do(func() {
+		close(ms.stop)
+		ms.loop.Wait()
+		ms.merges.Wait()
+
+		ms.mu.Lock()
+		ms.pending = nil
+		ms.mu.Unlock()
+	})
+}
@@ -0,0 +1,110 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/RoaringBitmap/roaring"
+
+	"harshagw/postings/internal/segment"
+)
+
+func TestMerge_ReconcilesDeletionsDuringMerge(t *testing.T) {
+	idx := openTestIndex(t, t.TempDir(), SyncPerBatch)
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"title": "hello"})
+	idx.Index("doc2", map[string]any{"title": "hello"})
+	idx.Flush()
+	idx.Index("doc3", map[string]any{"title": "hello"})
+	idx.Flush()
+
+	ids := make([]string, 0)
+	for _, info := range idx.Segments() {
+		ids = append(ids, info.ID)
+	}
+
+	inputs, newID, err := idx.prepareMerge(ids)
+	if err != nil {
+		t.Fatalf("prepareMerge error: %v", err)
+	}
+	segs := []*segment.Segment{inputs[0].seg, inputs[1].seg}
+	deleted := []*roaring.Bitmap{inputs[0].deleted, inputs[1].deleted}
+	path, docMaps, err := segment.Merge(segs, deleted, idx.dir, newID)
+	if err != nil {
+		t.Fatalf("Merge error: %v", err)
+	}
+
+	// Concurrent writes while the merged segment is being built.
+	if err := idx.Delete("doc1"); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	idx.Index("doc3", map[string]any{"title": "updated"})
+
+	newSeg, err := segment.Open(path, newID)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	if err := idx.commitMerge(inputs, newSeg, docMaps); err != nil {
+		t.Fatalf("commitMerge error: %v", err)
+	}
+	for _, in := range inputs {
+		in.seg.DecRef()
+	}
+
+	if idx.NumSegments() != 1 {
+		t.Fatalf("expected 1 segment, got %d", idx.NumSegments())
+	}
+
+	snap, _ := idx.Snapshot()
+	defer snap.Close()
+
+	// doc2 in the segment, doc3 (updated) in the builder.
+	if snap.TotalDocs() != 2 {
+		t.Errorf("expected 2 live docs, got %d", snap.TotalDocs())
+	}
+	postings, _ := snap.Segments()[0].Search("hello", "title")
+	if len(postings) != 1 {
+		t.Errorf("expected 1 live 'hello' posting in merged segment, got %d", len(postings))
+	}
+
+	// Deletions survive a flush and reopen.
+	idx.Flush()
+	dumped, err := idx.DumpDeletions(newID)
+	if err != nil {
+		t.Fatalf("DumpDeletions error: %v", err)
+	}
+	if len(dumped) != 2 {
+		t.Errorf("expected 2 deletions on merged segment, got %v", dumped)
+	}
+}
+
+func TestMerge_DoesNotBlockIndexing(t *testing.T) {
+	idx := openTestIndex(t, t.TempDir(), SyncPerBatch)
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"title": "hello"})
+	idx.Flush()
+	idx.Index("doc2", map[string]any{"title": "world"})
+	idx.Flush()
+
+	inputs, _, err := idx.prepareMerge([]string{idx.Segments()[0].ID, idx.Segments()[1].ID})
+	if err != nil {
+		t.Fatalf("prepareMerge error: %v", err)
+	}
+	defer func() {
+		for _, in := range inputs {
+			in.seg.DecRef()
+		}
+	}()
+
+	// The index lock is free between prepare and commit.
+	if err := idx.Index("doc3", map[string]any{"title": "again"}); err != nil {
+		t.Fatalf("Index during merge error: %v", err)
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatalf("Flush during merge error: %v", err)
+	}
+	if idx.NumSegments() != 3 {
+		t.Errorf("expected 3 segments, got %d", idx.NumSegments())
+	}
+}
@@ -97,7 +97,16 @@ func (idx *Index) flushInternal() error {
 	idx.segments = append(idx.segments, seg)
 	idx.epoch = epoch
 	idx.pendingDeletions = make(map[string]*roaring.Bitmap)
-	idx.builder = segment.NewBuilder(idx.analyzer)
+	idx.builder = segment.NewBuilderWithMapping(idx.mapping)
+
+	// Everything logged so far is now committed to a segment.
+	if err := idx.translog.Truncate(); err != nil {
+		return fmt.Errorf("failed to truncate translog: %w", err)
+	}
+
+	if idx.merges != nil {
+		idx.merges.Trigger()
+	}
 
 	return nil
 }
@@ -120,17 +129,29 @@ func (idx *Index) Snapshot() (*IndexSnapshot, error) {
 		snapshots[i] = &SegmentSnapshot{seg: seg, deleted: deleted}
 	}
 
-	return &IndexSnapshot{
-		segments:    snapshots,
-		builder:     idx.builder,
-		epoch:       idx.epoch,
-		analyzer:    idx.analyzer,
-		scoringMode: idx.scoringMode,
-	}, nil
+	// Taken last so an error above does not leak references.
+	for _, seg := range idx.segments {
+		seg.AddRef()
+	}
+
+	snap := &IndexSnapshot{
+		segments:   snapshots,
+		builder:    idx.builder.Freeze(),
+		epoch:      idx.epoch,
+		mapping:    idx.mapping,
+		similarity: idx.similarity,
+	}
+	snap.refs.Store(1)
+	return snap, nil
 }
 
 // Close closes the index and releases resources.
 func (idx *Index) Close() error {
+	// Stop background merges first; they need the index lock to finish.
+	if idx.merges != nil {
+		idx.merges.Close()
+	}
+
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
@@ -142,16 +163,27 @@ func (idx *Index) Close() error {
 	idx.pendingDeletions = nil
 	idx.builder = nil
 
-	for _, seg := range idx.segments {
-		seg.Close()
+	var err error
+	if idx.translog != nil {
+		err = idx.translog.Close()
 	}
-	idx.segments = nil
+
+	idx.closeSegments()
 
 	if idx.meta != nil {
 		idx.meta.Close()
 	}
 
-	return nil
+	return err
+}
+
+// closeSegments releases the index's reference on all open segments.
+// Segments still used by open snapshots stay mapped until those are closed.
+func (idx *Index) closeSegments() {
+	for _, seg := range idx.segments {
+		seg.DecRef()
+	}
+	idx.segments = nil
 }
 
 // NumSegments returns the number of segments.
@@ -270,7 +302,11 @@ func (idx *Index) DumpDeletions(segID string) ([]uint32, error) {
 }
 
 // ForceMerge merges all segments into one.
+// It waits for running background merges and drops pending ones.
 func (idx *Index) ForceMerge() error {
+	idx.merges.lockExclusive()
+	defer idx.merges.unlockExclusive()
+
 	idx.mu.RLock()
 	segmentIDs := make([]string, len(idx.segments))
 	for i, seg := range idx.segments {
@@ -284,3 +320,33 @@ func (idx *Index) ForceMerge() error {
 
 	return idx.Merge(segmentIDs)
 }
+
+// MergeStats returns pending and running background merges.
+func (idx *Index) MergeStats() MergeStats {
+	return idx.merges.Stats()
+}
+
+// mergeCandidates returns size and deletion info for every segment.
+func (idx *Index) mergeCandidates() ([]MergeCandidate, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if idx.closed {
+		return nil, fmt.Errorf("index is closed")
+	}
+
+	candidates := make([]MergeCandidate, len(idx.segments))
+	for i, seg := range idx.segments {
+		deleted, err := idx.getDeletions(seg.ID())
+		if err != nil {
+			return nil, err
+		}
+		candidates[i] = MergeCandidate{
+			ID:         seg.ID(),
+			SizeBytes:  seg.Size(),
+			NumDocs:    seg.NumDocs(),
+			NumDeleted: deleted.GetCardinality(),
+		}
+	}
+	return candidates, nil
+}
@@ -1,10 +1,15 @@
 package index
 
 import (
+	"sync"
+	"sync/atomic"
+
 	"github.com/RoaringBitmap/roaring"
 
 	"harshagw/postings/internal/analysis"
+	"harshagw/postings/internal/mapping"
 	"harshagw/postings/internal/segment"
+	"harshagw/postings/internal/similarity"
 )
 
 // SegmentSnapshot represents a segment with its deletion bitmap.
@@ -28,25 +33,53 @@ func (s *SegmentSnapshot) Search(term, field string) ([]segment.Posting, error)
 }
 
 // IndexSnapshot represents a point-in-time view of the index for searching.
+// It holds a reference on each of its segments so they stay mapped until
+// the snapshot and every searcher using it are closed.
 type IndexSnapshot struct {
-	segments    []*SegmentSnapshot
-	builder     *segment.Builder
-	epoch       uint64
-	analyzer    analysis.Analyzer
-	scoringMode ScoringMode
+	segments   []*SegmentSnapshot
+	builder    *segment.Builder
+	epoch      uint64
+	mapping    *mapping.IndexMapping
+	similarity similarity.Similarity
+
+	statsMu    sync.Mutex
+	fieldStats map[string]segment.FieldStats // cached per field
+
+	refs   atomic.Int64
+	closed atomic.Bool
 }
 
 // Segments returns the segment snapshots.
 func (s *IndexSnapshot) Segments() []*SegmentSnapshot { return s.segments }
 
-// Builder returns the in-memory segment builder (may be nil).
+// Builder returns a read-only view of the in-memory builder as of the
+// snapshot (may be nil). It must not be modified.
 func (s *IndexSnapshot) Builder() *segment.Builder { return s.builder }
 
-// Analyzer returns the index's analyzer.
-func (s *IndexSnapshot) Analyzer() analysis.Analyzer { return s.analyzer }
+// Analyzer returns the index's default analyzer.
+func (s *IndexSnapshot) Analyzer() analysis.Analyzer { return s.mapping.Default() }
 
-// ScoringMode returns the scoring mode for this snapshot.
-func (s *IndexSnapshot) ScoringMode() ScoringMode { return s.scoringMode }
+// AnalyzerFor returns the analyzer used to index a field.
+func (s *IndexSnapshot) AnalyzerFor(field string) analysis.Analyzer {
+	return s.mapping.AnalyzerFor(field)
+}
+
+// SearchAnalyzerFor returns the analyzer used for query text on a field.
+func (s *IndexSnapshot) SearchAnalyzerFor(field string) analysis.Analyzer {
+	return s.mapping.SearchAnalyzerFor(field)
+}
+
+// Mapping returns the index's field mapping.
+func (s *IndexSnapshot) Mapping() *mapping.IndexMapping { return s.mapping }
+
+// SimilarityFor returns the similarity that scores matches in a field: the
+// one the mapping assigns, or the index's.
+func (s *IndexSnapshot) SimilarityFor(field string) similarity.Similarity {
+	if sim := s.mapping.SimilarityFor(field); sim != nil {
+		return sim
+	}
+	return s.similarity
+}
 
 // TotalDocs returns the total number of documents across all segments.
 func (s *IndexSnapshot) TotalDocs() uint64 {
@@ -63,38 +96,84 @@ func (s *IndexSnapshot) TotalDocs() uint64 {
 	return total
 }
 
-// AvgFieldLength returns the average length of a field across all segments.
-func (s *IndexSnapshot) AvgFieldLength(field string) float64 {
-	var totalTokens uint64
-	var docCount uint64
-
+// TermStats returns the statistics of term in field over the live documents
+// of the snapshot.
+func (s *IndexSnapshot) TermStats(field, term string) segment.TermStats {
+	var stats segment.TermStats
 	for _, seg := range s.segments {
-		avg := seg.seg.AvgFieldLength(field)
-		if avg > 0 {
-			numDocs := seg.seg.NumDocs()
-			if seg.deleted != nil {
-				numDocs -= seg.deleted.GetCardinality()
-			}
-			totalTokens += uint64(avg * float64(numDocs))
-			docCount += numDocs
+		segStats, err := seg.seg.TermStats(term, field, seg.deleted)
+		if err != nil {
+			continue
 		}
+		stats.Add(segStats)
+	}
+	if s.builder != nil {
+		stats.Add(s.builder.TermStats(term, field))
+	}
+	return stats
+}
+
+// FieldStats returns the statistics of field over the live documents of the
+// snapshot. Every live document has an _id.
+func (s *IndexSnapshot) FieldStats(field string) segment.FieldStats {
+	if field == segment.IDField {
+		total := s.TotalDocs()
+		return segment.FieldStats{DocCount: total, SumTotalTermFreq: total}
 	}
 
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	if stats, ok := s.fieldStats[field]; ok {
+		return stats
+	}
+
+	var stats segment.FieldStats
+	for _, seg := range s.segments {
+		stats.Add(seg.seg.FieldStats(field, seg.deleted))
+	}
 	if s.builder != nil {
-		avg := s.builder.AvgFieldLength(field)
-		if avg > 0 {
-			totalTokens += uint64(avg * float64(s.builder.NumDocs()))
-			docCount += s.builder.NumDocs()
-		}
+		stats.Add(s.builder.FieldStats(field))
+	}
+	if s.fieldStats == nil {
+		s.fieldStats = make(map[string]segment.FieldStats)
+	}
+	s.fieldStats[field] = stats
+	return stats
+}
+
+// AvgFieldLength returns the average length of a field over the live
+// documents that have it.
+func (s *IndexSnapshot) AvgFieldLength(field string) float64 {
+	return s.FieldStats(field).AvgLength()
+}
+
+// AddRef takes an additional reference on the snapshot, e.g. for a searcher.
+// Every AddRef must be paired with a DecRef.
+func (s *IndexSnapshot) AddRef() {
+	s.refs.Add(1)
+}
+
+// DecRef releases a reference. When the last reference is released the
+// snapshot releases its segments.
+func (s *IndexSnapshot) DecRef() error {
+	if s.refs.Add(-1) != 0 {
+		return nil
 	}
 
-	if docCount == 0 {
-		return 0
+	var firstErr error
+	for _, ss := range s.segments {
+		if err := ss.seg.DecRef(); err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
-	return float64(totalTokens) / float64(docCount)
+	return firstErr
 }
 
-// Close releases snapshot resources.
+// Close releases the reference returned by Index.Snapshot.
+// It is safe to call more than once.
 func (s *IndexSnapshot) Close() error {
-	return nil
+	if !s.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	return s.DecRef()
 }
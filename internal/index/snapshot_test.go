@@ -0,0 +1,189 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"harshagw/postings/internal/segment"
+)
+
+func TestSnapshot_KeepsMergedSegmentsAlive(t *testing.T) {
+	dir := t.TempDir()
+	idx := openTestIndex(t, dir, SyncPerBatch)
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"title": "hello"})
+	idx.Flush()
+	idx.Index("doc2", map[string]any{"title": "hello world"})
+	idx.Flush()
+
+	snap, err := idx.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot error: %v", err)
+	}
+	old := snap.Segments()
+
+	if err := idx.ForceMerge(); err != nil {
+		t.Fatalf("ForceMerge error: %v", err)
+	}
+	if idx.NumSegments() != 1 {
+		t.Fatalf("expected 1 segment after merge, got %d", idx.NumSegments())
+	}
+
+	// The snapshot still reads from the merged-away segments.
+	for _, ss := range old {
+		if _, err := os.Stat(ss.Segment().Path()); err != nil {
+			t.Errorf("segment %s removed while snapshot open: %v", ss.ID(), err)
+		}
+		postings, err := ss.Search("hello", "title")
+		if err != nil || len(postings) != 1 {
+			t.Errorf("segment %s: expected 1 posting, got %d (err=%v)", ss.ID(), len(postings), err)
+		}
+	}
+
+	if err := snap.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	for _, ss := range old {
+		if _, err := os.Stat(ss.Segment().Path()); !os.IsNotExist(err) {
+			t.Errorf("segment %s should be removed after last snapshot closed", ss.ID())
+		}
+	}
+}
+
+func TestSnapshot_AddRefDefersRelease(t *testing.T) {
+	dir := t.TempDir()
+	idx := openTestIndex(t, dir, SyncPerBatch)
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"title": "hello"})
+	idx.Flush()
+
+	snap, _ := idx.Snapshot()
+	seg := snap.Segments()[0].Segment()
+	if seg.Refs() != 2 {
+		t.Fatalf("expected 2 refs (index + snapshot), got %d", seg.Refs())
+	}
+
+	snap.AddRef()
+	snap.Close()
+	snap.Close() // second Close is a no-op
+	if seg.Refs() != 2 {
+		t.Errorf("expected snapshot to keep its ref while referenced, got %d", seg.Refs())
+	}
+
+	snap.DecRef()
+	if seg.Refs() != 1 {
+		t.Errorf("expected 1 ref after release, got %d", seg.Refs())
+	}
+}
+
+func TestNew_RemovesOrphanSegments(t *testing.T) {
+	dir := t.TempDir()
+	idx := openTestIndex(t, dir, SyncPerBatch)
+	idx.Index("doc1", map[string]any{"title": "hello"})
+	idx.Flush()
+	idx.Close()
+
+	orphan := filepath.Join(dir, "999999999999.seg")
+	if err := os.WriteFile(orphan, []byte("junk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx = openTestIndex(t, dir, SyncPerBatch)
+	defer idx.Close()
+
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("expected orphan segment to be removed")
+	}
+	if idx.NumSegments() != 1 {
+		t.Errorf("expected 1 segment, got %d", idx.NumSegments())
+	}
+}
+
+func TestSnapshot_PointInTimeBuilder(t *testing.T) {
+	idx := openTestIndex(t, t.TempDir(), SyncPeriodic)
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"title": "hello"})
+
+	snap, _ := idx.Snapshot()
+	defer snap.Close()
+
+	idx.Index("doc2", map[string]any{"title": "hello"})
+	idx.Delete("doc1")
+
+	if snap.TotalDocs() != 1 {
+		t.Errorf("expected snapshot to see 1 doc, got %d", snap.TotalDocs())
+	}
+	if got := len(snap.Builder().Fields["title"]["hello"]); got != 1 {
+		t.Errorf("expected 1 posting in snapshot, got %d", got)
+	}
+	if snap.Builder().IsDeleted(0) {
+		t.Error("snapshot should not see deletion made after it was taken")
+	}
+}
+
+func TestSnapshot_ConcurrentIndexing(t *testing.T) {
+	idx := openTestIndex(t, t.TempDir(), SyncPeriodic)
+	defer idx.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			idx.Index(fmt.Sprintf("doc%d", i), map[string]any{"title": "hello world"})
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		snap, err := idx.Snapshot()
+		if err != nil {
+			t.Fatalf("Snapshot error: %v", err)
+		}
+		b := snap.Builder()
+		for _, postings := range b.Fields["title"] {
+			for _, p := range postings {
+				if p.DocNum >= b.TotalDocs() {
+					t.Errorf("posting docNum %d beyond snapshot docs %d", p.DocNum, b.TotalDocs())
+				}
+			}
+		}
+		snap.Close()
+	}
+	<-done
+}
+
+func TestSnapshot_TermAndFieldStats(t *testing.T) {
+	idx := openTestIndex(t, t.TempDir(), SyncPeriodic)
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"title": "go go gophers"})
+	idx.Index("doc2", map[string]any{"title": "go", "body": "rust"})
+	idx.Flush()
+	idx.Index("doc3", map[string]any{"title": "go fast"})
+	idx.Index("doc4", map[string]any{"body": "go"})
+	idx.Delete("doc2")
+
+	snap, _ := idx.Snapshot()
+	defer snap.Close()
+
+	if got, want := snap.TermStats("title", "go"), (segment.TermStats{DocFreq: 2, TotalTermFreq: 3}); got != want {
+		t.Errorf("TermStats(title, go) = %+v, want %+v", got, want)
+	}
+	if got, want := snap.TermStats("body", "go"), (segment.TermStats{DocFreq: 1, TotalTermFreq: 1}); got != want {
+		t.Errorf("TermStats(body, go) = %+v, want %+v", got, want)
+	}
+	if got, want := snap.FieldStats("title"), (segment.FieldStats{DocCount: 2, SumTotalTermFreq: 5}); got != want {
+		t.Errorf("FieldStats(title) = %+v, want %+v", got, want)
+	}
+	if got, want := snap.FieldStats("body"), (segment.FieldStats{DocCount: 1, SumTotalTermFreq: 1}); got != want {
+		t.Errorf("FieldStats(body) = %+v, want %+v", got, want)
+	}
+	if got := snap.FieldStats(segment.IDField).DocCount; got != 3 {
+		t.Errorf("FieldStats(_id).DocCount = %d, want 3", got)
+	}
+}
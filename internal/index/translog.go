@@ -0,0 +1,235 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyncPolicy controls when translog writes are fsynced to disk.
+type SyncPolicy int
+
+const (
+	// SyncPerOp fsyncs after every index or delete operation.
+	SyncPerOp SyncPolicy = iota
+	// SyncPerBatch fsyncs once per Apply call. Single Index/Delete calls
+	// count as a batch of one.
+	SyncPerBatch
+	// SyncPeriodic fsyncs from a background goroutine every SyncInterval.
+	SyncPeriodic
+)
+
+const translogFileName = "translog.log"
+
+// Record header: payload length + CRC32 of the payload.
+const translogHeaderSize = 8
+
+type translogOp uint8
+
+const (
+	translogOpIndex translogOp = iota + 1
+	translogOpDelete
+)
+
+// translogEntry is a single logged operation.
+type translogEntry struct {
+	Op  translogOp     `json:"op"`
+	ID  string         `json:"id"`
+	Doc map[string]any `json:"doc,omitempty"`
+}
+
+// translog is an append-only log of operations that have not yet been
+// committed to a segment. It is replayed on open and truncated after flush.
+type translog struct {
+	mu     sync.Mutex
+	file   *os.File
+	policy SyncPolicy
+	dirty  bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// openTranslog opens or creates the translog in dir.
+func openTranslog(dir string, policy SyncPolicy, interval time.Duration) (*translog, error) {
+	path := filepath.Join(dir, translogFileName)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	tl := &translog{file: file, policy: policy}
+
+	if policy == SyncPeriodic {
+		if interval <= 0 {
+			interval = time.Second
+		}
+		tl.stop = make(chan struct{})
+		tl.done = make(chan struct{})
+		go tl.syncLoop(interval)
+	}
+
+	return tl, nil
+}
+
+// Replay calls fn for every intact entry in the log. A torn or corrupt
+// record at the tail (e.g. from a crash mid-write) ends the replay and is
+// truncated away so later appends start from a clean offset.
+func (tl *translog) Replay(fn func(translogEntry)) error {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	if _, err := tl.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(tl.file)
+	if err != nil {
+		return err
+	}
+
+	var offset int
+	for offset+translogHeaderSize <= len(data) {
+		size := int(binary.BigEndian.Uint32(data[offset:]))
+		checksum := binary.BigEndian.Uint32(data[offset+4:])
+		start := offset + translogHeaderSize
+		if start+size > len(data) {
+			break
+		}
+		payload := data[start : start+size]
+		if crc32.ChecksumIEEE(payload) != checksum {
+			break
+		}
+
+		var entry translogEntry
+		if err := json.Unmarshal(payload, &entry); err != nil {
+			break
+		}
+		fn(entry)
+		offset = start + size
+	}
+
+	if offset < len(data) {
+		if err := tl.file.Truncate(int64(offset)); err != nil {
+			return err
+		}
+	}
+	_, err = tl.file.Seek(int64(offset), io.SeekStart)
+	return err
+}
+
+// Append writes entries to the log as a single batch and syncs according
+// to the configured policy.
+func (tl *translog) Append(entries []translogEntry) error {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	var buf bytes.Buffer
+	header := make([]byte, translogHeaderSize)
+	for _, entry := range entries {
+		payload, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode translog entry %s: %w", entry.ID, err)
+		}
+		binary.BigEndian.PutUint32(header, uint32(len(payload)))
+		binary.BigEndian.PutUint32(header[4:], crc32.ChecksumIEEE(payload))
+		buf.Write(header)
+		buf.Write(payload)
+
+		if tl.policy == SyncPerOp {
+			if _, err := tl.file.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			if err := tl.file.Sync(); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+	}
+
+	if buf.Len() == 0 {
+		return nil
+	}
+	if _, err := tl.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	if tl.policy == SyncPerBatch {
+		return tl.file.Sync()
+	}
+	tl.dirty = true
+	return nil
+}
+
+// Truncate discards all entries. Called once their effects are committed.
+func (tl *translog) Truncate() error {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	if err := tl.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := tl.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	tl.dirty = false
+	return tl.file.Sync()
+}
+
+// Sync fsyncs any unsynced writes.
+func (tl *translog) Sync() error {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	return tl.syncLocked()
+}
+
+func (tl *translog) syncLocked() error {
+	if !tl.dirty {
+		return nil
+	}
+	if err := tl.file.Sync(); err != nil {
+		return err
+	}
+	tl.dirty = false
+	return nil
+}
+
+// syncLoop periodically fsyncs the log until Close is called.
+func (tl *translog) syncLoop(interval time.Duration) {
+	defer close(tl.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			tl.Sync()
+		case <-tl.stop:
+			return
+		}
+	}
+}
+
+// Close stops background syncing, syncs pending writes and closes the file.
+func (tl *translog) Close() error {
+	if tl.stop != nil {
+		close(tl.stop)
+		<-tl.done
+	}
+
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	if err := tl.syncLocked(); err != nil {
+		tl.file.Close()
+		return err
+	}
+	return tl.file.Close()
+}
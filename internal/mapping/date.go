@@ -0,0 +1,152 @@
+package mapping
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDateFormats are the layouts tried for date fields without Formats.
+// Layouts without a zone are read as UTC.
+var DefaultDateFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// ParseDate parses a date in a query of field using its mapped formats.
+// It also accepts date math relative to the current time: "now", "now-7d",
+// "now+1h" or "now-1M/d", with units y, M, w, d, h, m and s; a trailing
+// "/unit" rounds down to the start of that unit.
+func (m *IndexMapping) ParseDate(field, value string) (time.Time, error) {
+	return m.parseDate(field, value, time.Now())
+}
+
+// ParseDateValue parses the date value of a document's field using its
+// mapped formats only. It rejects date math, so that a document indexes, and
+// replays from the translog, the same whenever it is processed.
+func (m *IndexMapping) ParseDateValue(field, value string) (time.Time, error) {
+	return m.parseFormats(field, strings.TrimSpace(value))
+}
+
+func (m *IndexMapping) parseDate(field, value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "now") {
+		return dateMath(now.UTC(), value[len("now"):])
+	}
+	return m.parseFormats(field, value)
+}
+
+func (m *IndexMapping) parseFormats(field, value string) (time.Time, error) {
+	formats := DefaultDateFormats
+	if fm, ok := m.FieldMappingFor(field); ok && len(fm.Formats) > 0 {
+		formats = fm.Formats
+	}
+	for _, layout := range formats {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q for field %s", value, field)
+}
+
+// dateMath applies expressions such as "-7d", "+1h/d" or "/M" to t.
+func dateMath(t time.Time, expr string) (time.Time, error) {
+	for expr != "" {
+		op := expr[0]
+		expr = expr[1:]
+
+		switch op {
+		case '+', '-':
+			i := 0
+			for i < len(expr) && expr[i] >= '0' && expr[i] <= '9' {
+				i++
+			}
+			n := 1
+			if i > 0 {
+				var err error
+				if n, err = strconv.Atoi(expr[:i]); err != nil {
+					return time.Time{}, fmt.Errorf("invalid date math offset %q: %w", expr[:i], err)
+				}
+			}
+			if i >= len(expr) {
+				return time.Time{}, fmt.Errorf("missing unit in date math")
+			}
+			if op == '-' {
+				n = -n
+			}
+			var err error
+			if t, err = addUnit(t, n, expr[i]); err != nil {
+				return time.Time{}, err
+			}
+			expr = expr[i+1:]
+		case '/':
+			if expr == "" {
+				return time.Time{}, fmt.Errorf("missing unit in date math")
+			}
+			var err error
+			if t, err = roundDown(t, expr[0]); err != nil {
+				return time.Time{}, err
+			}
+			expr = expr[1:]
+		default:
+			return time.Time{}, fmt.Errorf("invalid date math operator %q", op)
+		}
+	}
+	return t, nil
+}
+
+func addUnit(t time.Time, n int, unit byte) (time.Time, error) {
+	switch unit {
+	case 'y':
+		return t.AddDate(n, 0, 0), nil
+	case 'M':
+		return t.AddDate(0, n, 0), nil
+	case 'w':
+		return t.AddDate(0, 0, 7*n), nil
+	case 'd':
+		return t.AddDate(0, 0, n), nil
+	case 'h':
+		return t.Add(time.Duration(n) * time.Hour), nil
+	case 'm':
+		return t.Add(time.Duration(n) * time.Minute), nil
+	case 's':
+		return t.Add(time.Duration(n) * time.Second), nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid date math unit %q", unit)
+	}
+}
+
+func roundDown(t time.Time, unit byte) (time.Time, error) {
+	y, mo, d := t.Date()
+	switch unit {
+	case 'y':
+		return time.Date(y, 1, 1, 0, 0, 0, 0, t.Location()), nil
+	case 'M':
+		return time.Date(y, mo, 1, 0, 0, 0, 0, t.Location()), nil
+	case 'w':
+		offset := (int(t.Weekday()) + 6) % 7 // weeks start on Monday
+		return time.Date(y, mo, d-offset, 0, 0, 0, 0, t.Location()), nil
+	case 'd':
+		return time.Date(y, mo, d, 0, 0, 0, 0, t.Location()), nil
+	case 'h':
+		return t.Truncate(time.Hour), nil
+	case 'm':
+		return t.Truncate(time.Minute), nil
+	case 's':
+		return t.Truncate(time.Second), nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid date math unit %q", unit)
+	}
+}
+
+// DateValue returns the indexed value of a date: Unix milliseconds, which
+// float64 represents exactly for any realistic date.
+func DateValue(t time.Time) float64 {
+	return float64(t.UnixMilli())
+}
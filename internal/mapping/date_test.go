@@ -0,0 +1,86 @@
+package mapping
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate_Formats(t *testing.T) {
+	m := New(nil)
+	want := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	for _, value := range []string{"2024-03-05", "2024-03-05T00:00:00Z", "2024-03-05 00:00:00", "2024-03-05T01:00:00+01:00"} {
+		got, err := m.ParseDate("created", value)
+		if err != nil {
+			t.Fatalf("ParseDate(%q) error: %v", value, err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("ParseDate(%q) = %v, want %v", value, got, want)
+		}
+	}
+
+	if _, err := m.ParseDate("created", "March 5"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestParseDateValue_RejectsDateMath(t *testing.T) {
+	m := New(nil)
+	if _, err := m.ParseDateValue("created", "2024-03-05"); err != nil {
+		t.Fatalf("ParseDateValue error: %v", err)
+	}
+	for _, value := range []string{"now", "now-1d", " now/d"} {
+		if _, err := m.ParseDateValue("created", value); err == nil {
+			t.Errorf("ParseDateValue(%q): expected error", value)
+		}
+	}
+}
+
+func TestParseDate_CustomFormats(t *testing.T) {
+	m, err := Parse([]byte(`{"fields": {"day": {"type": "date", "formats": ["02/01/2006"]}}}`), nil)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	got, err := m.ParseDate("day", "05/03/2024")
+	if err != nil {
+		t.Fatalf("ParseDate error: %v", err)
+	}
+	if want := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := m.ParseDate("day", "2024-03-05"); err == nil {
+		t.Error("expected custom formats to replace the defaults")
+	}
+}
+
+func TestParseDate_DateMath(t *testing.T) {
+	m := New(nil)
+	now := time.Date(2024, 3, 13, 15, 30, 45, 0, time.UTC) // a Wednesday
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"now", now},
+		{"now-7d", time.Date(2024, 3, 6, 15, 30, 45, 0, time.UTC)},
+		{"now+1h", time.Date(2024, 3, 13, 16, 30, 45, 0, time.UTC)},
+		{"now-1M/d", time.Date(2024, 2, 13, 0, 0, 0, 0, time.UTC)},
+		{"now/w", time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{"now/y+2m", time.Date(2024, 1, 1, 0, 2, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := m.parseDate("created", tt.expr, now)
+		if err != nil {
+			t.Fatalf("parseDate(%q) error: %v", tt.expr, err)
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseDate(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"now-7", "now-7x", "now/", "now*2d", "now-99999999999999999999d"} {
+		if _, err := m.parseDate("created", expr, now); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package mapping
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync"
+
+	"harshagw/postings/internal/analysis"
+	"harshagw/postings/internal/similarity"
+)
+
+// Field types.
+const (
+	TypeText    = "text"    // analyzed string values (default)
+	TypeNumeric = "numeric" // numbers, including numeric strings
+	TypeDate    = "date"    // date strings, stored as Unix milliseconds
+)
+
+// FieldMapping selects the type and analyzer for a field. Numbers are indexed
+// as numeric whatever the field's type; a numeric field also parses strings.
+// Date fields parse strings with Formats (Go time layouts), or
+// DefaultDateFormats if empty, and read numbers as Unix milliseconds.
+//
+// SearchAnalyzer, with SearchOptions, analyzes query text for the field in
+// place of Analyzer, e.g. to expand synonyms only at query time. Offsets
+// stores the byte offsets of each term occurrence in the postings of a text
+// field, so matches can be located without re-analyzing the document.
+// Similarity, with SimilarityOptions, names the similarity that scores
+// matches in the field.
+type FieldMapping struct {
+	Type           string            `json:"type,omitempty"`
+	Analyzer       string            `json:"analyzer,omitempty"`
+	Options        map[string]string `json:"options,omitempty"`
+	SearchAnalyzer string            `json:"search_analyzer,omitempty"`
+	SearchOptions  map[string]string `json:"search_options,omitempty"`
+	Offsets        bool              `json:"offsets,omitempty"`
+	Formats        []string          `json:"formats,omitempty"`
+
+	Similarity        string            `json:"similarity,omitempty"`
+	SimilarityOptions map[string]string `json:"similarity_options,omitempty"`
+}
+
+// IndexMapping assigns analyzers to fields. Keys of Fields are field names
+// or path.Match patterns such as "meta_*"; an exact name wins over a
+// pattern, and longer patterns win over shorter ones. Fields without a
+// mapping use DefaultAnalyzer, or the fallback analyzer if that is empty.
+// Fields without a similarity use DefaultSimilarity, or the index's if that
+// is empty.
+//
+// A mapping must be compiled before use; it is read-only afterwards.
+type IndexMapping struct {
+	DefaultAnalyzer          string                  `json:"default_analyzer,omitempty"`
+	DefaultSimilarity        string                  `json:"default_similarity,omitempty"`
+	DefaultSimilarityOptions map[string]string       `json:"default_similarity_options,omitempty"`
+	Fields                   map[string]FieldMapping `json:"fields,omitempty"`
+
+	defaultAnalyzer   analysis.Analyzer
+	analyzers         map[string]analysis.Analyzer // mapping key -> analyzer
+	searchAnalyzers   map[string]analysis.Analyzer // mapping key -> query analyzer
+	defaultSimilarity similarity.Similarity
+	similarities      map[string]similarity.Similarity // mapping key -> similarity
+
+	mu       sync.RWMutex
+	resolved map[string]analysis.Analyzer // field name -> analyzer
+}
+
+// New returns a compiled mapping that applies def to every field.
+func New(def analysis.Analyzer) *IndexMapping {
+	m := &IndexMapping{}
+	m.Compile(def)
+	return m
+}
+
+// Parse decodes and compiles a JSON mapping.
+func Parse(data []byte, fallback analysis.Analyzer) (*IndexMapping, error) {
+	m := &IndexMapping{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping: %w", err)
+	}
+	if err := m.Compile(fallback); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Compile builds the analyzers and similarities named by the mapping.
+// fallback is used when DefaultAnalyzer is empty; if both are unset the
+// simple analyzer is used.
+func (m *IndexMapping) Compile(fallback analysis.Analyzer) error {
+	def := fallback
+	if m.DefaultAnalyzer != "" {
+		a, err := analysis.Build(m.DefaultAnalyzer, nil)
+		if err != nil {
+			return fmt.Errorf("default analyzer: %w", err)
+		}
+		def = a
+	}
+	if def == nil {
+		def = analysis.NewSimple()
+	}
+
+	var defSim similarity.Similarity
+	if m.DefaultSimilarity != "" {
+		s, err := similarity.Build(m.DefaultSimilarity, m.DefaultSimilarityOptions)
+		if err != nil {
+			return fmt.Errorf("default similarity: %w", err)
+		}
+		defSim = s
+	}
+
+	analyzers := make(map[string]analysis.Analyzer, len(m.Fields))
+	searchAnalyzers := make(map[string]analysis.Analyzer)
+	similarities := make(map[string]similarity.Similarity)
+	for key, fm := range m.Fields {
+		if _, err := path.Match(key, ""); err != nil {
+			return fmt.Errorf("field %q: invalid pattern: %w", key, err)
+		}
+		if fm.Similarity != "" {
+			s, err := similarity.Build(fm.Similarity, fm.SimilarityOptions)
+			if err != nil {
+				return fmt.Errorf("field %q: %w", key, err)
+			}
+			similarities[key] = s
+		}
+		switch fm.Type {
+		case "", TypeText:
+		case TypeNumeric, TypeDate:
+			continue
+		default:
+			return fmt.Errorf("field %q: unknown type: %s", key, fm.Type)
+		}
+		if fm.SearchAnalyzer != "" {
+			a, err := analysis.Build(fm.SearchAnalyzer, fm.SearchOptions)
+			if err != nil {
+				return fmt.Errorf("field %q: search analyzer: %w", key, err)
+			}
+			searchAnalyzers[key] = a
+		}
+		if fm.Analyzer == "" {
+			analyzers[key] = def
+			continue
+		}
+		a, err := analysis.Build(fm.Analyzer, fm.Options)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", key, err)
+		}
+		analyzers[key] = a
+	}
+
+	m.mu.Lock()
+	m.defaultAnalyzer = def
+	m.analyzers = analyzers
+	m.searchAnalyzers = searchAnalyzers
+	m.defaultSimilarity = defSim
+	m.similarities = similarities
+	m.resolved = make(map[string]analysis.Analyzer)
+	m.mu.Unlock()
+	return nil
+}
+
+// Default returns the analyzer used for fields without a mapping.
+func (m *IndexMapping) Default() analysis.Analyzer {
+	return m.defaultAnalyzer
+}
+
+// AnalyzerFor returns the analyzer for a field.
+func (m *IndexMapping) AnalyzerFor(field string) analysis.Analyzer {
+	m.mu.RLock()
+	a, ok := m.resolved[field]
+	m.mu.RUnlock()
+	if ok {
+		return a
+	}
+
+	a = m.defaultAnalyzer
+	if key, ok := m.match(field); ok && m.analyzers[key] != nil {
+		a = m.analyzers[key]
+	}
+
+	m.mu.Lock()
+	m.resolved[field] = a
+	m.mu.Unlock()
+	return a
+}
+
+// SearchAnalyzerFor returns the analyzer for query text on a field: its
+// search analyzer if one is mapped, otherwise AnalyzerFor(field).
+func (m *IndexMapping) SearchAnalyzerFor(field string) analysis.Analyzer {
+	if key, ok := m.match(field); ok && m.searchAnalyzers[key] != nil {
+		return m.searchAnalyzers[key]
+	}
+	return m.AnalyzerFor(field)
+}
+
+// SimilarityFor returns the similarity mapped for a field, or the mapping's
+// default similarity. It returns nil if neither is set.
+func (m *IndexMapping) SimilarityFor(field string) similarity.Similarity {
+	if key, ok := m.match(field); ok && m.similarities[key] != nil {
+		return m.similarities[key]
+	}
+	return m.defaultSimilarity
+}
+
+// OffsetsFor reports whether a field's postings store term offsets.
+func (m *IndexMapping) OffsetsFor(field string) bool {
+	fm, ok := m.FieldMappingFor(field)
+	return ok && fm.Offsets && (fm.Type == "" || fm.Type == TypeText)
+}
+
+// TypeFor returns the type of a field; unmapped fields are text.
+func (m *IndexMapping) TypeFor(field string) string {
+	if fm, ok := m.FieldMappingFor(field); ok && fm.Type != "" {
+		return fm.Type
+	}
+	return TypeText
+}
+
+// FieldMappingFor returns the mapping that applies to a field, if any.
+func (m *IndexMapping) FieldMappingFor(field string) (FieldMapping, bool) {
+	key, ok := m.match(field)
+	if !ok {
+		return FieldMapping{}, false
+	}
+	return m.Fields[key], true
+}
+
+// match returns the Fields key that applies to field.
+func (m *IndexMapping) match(field string) (string, bool) {
+	if _, ok := m.Fields[field]; ok {
+		return field, true
+	}
+
+	best := ""
+	found := false
+	for key := range m.Fields {
+		if ok, _ := path.Match(key, field); !ok {
+			continue
+		}
+		if !found || len(key) > len(best) || (len(key) == len(best) && key < best) {
+			best = key
+			found = true
+		}
+	}
+	return best, found
+}
+
+// JSON returns the mapping's canonical JSON encoding.
+func (m *IndexMapping) JSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// Equal reports whether two mappings have the same definition.
+func (m *IndexMapping) Equal(other *IndexMapping) bool {
+	a, err := m.JSON()
+	if err != nil {
+		return false
+	}
+	b, err := other.JSON()
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(a, b)
+}
@@ -0,0 +1,126 @@
+package mapping
+
+import (
+	"testing"
+
+	"harshagw/postings/internal/analysis"
+)
+
+func tokens(a analysis.Analyzer, text string) []string {
+	var out []string
+	for _, tp := range a.Analyze(text) {
+		out = append(out, tp.Token)
+	}
+	return out
+}
+
+func TestMapping_ExactBeatsPattern(t *testing.T) {
+	m, err := Parse([]byte(`{
+		"fields": {
+			"sku": {"analyzer": "keyword"},
+			"s*": {"analyzer": "whitespace"},
+			"sk*": {"analyzer": "keyword", "options": {"lowercase": "true"}}
+		}
+	}`), nil)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	if got := tokens(m.AnalyzerFor("sku"), "AB-12"); len(got) != 1 || got[0] != "AB-12" {
+		t.Errorf("sku: expected [AB-12], got %v", got)
+	}
+	if got := tokens(m.AnalyzerFor("skus"), "AB-12"); len(got) != 1 || got[0] != "ab-12" {
+		t.Errorf("skus: expected longest pattern to win, got %v", got)
+	}
+	if got := tokens(m.AnalyzerFor("size"), "Big Box"); len(got) != 2 || got[0] != "big" {
+		t.Errorf("size: expected whitespace tokens, got %v", got)
+	}
+	if got := tokens(m.AnalyzerFor("title"), "Big-Box"); len(got) != 2 {
+		t.Errorf("title: expected default simple analyzer, got %v", got)
+	}
+}
+
+func TestMapping_SearchAnalyzer(t *testing.T) {
+	m, err := Parse([]byte(`{
+		"fields": {
+			"sku": {"analyzer": "keyword", "search_analyzer": "keyword", "search_options": {"lowercase": "true"}}
+		}
+	}`), nil)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	if got := tokens(m.SearchAnalyzerFor("sku"), "AB-12"); len(got) != 1 || got[0] != "ab-12" {
+		t.Errorf("sku: expected search analyzer, got %v", got)
+	}
+	if got := tokens(m.AnalyzerFor("sku"), "AB-12"); len(got) != 1 || got[0] != "AB-12" {
+		t.Errorf("sku: expected index analyzer, got %v", got)
+	}
+	// Without a search analyzer, queries use the index analyzer.
+	if got := tokens(m.SearchAnalyzerFor("title"), "Big-Box"); len(got) != 2 {
+		t.Errorf("title: expected default simple analyzer, got %v", got)
+	}
+
+	if _, err := Parse([]byte(`{"fields": {"sku": {"search_analyzer": "nope"}}}`), nil); err == nil {
+		t.Error("expected error for unknown search analyzer")
+	}
+}
+
+func TestMapping_RejectsUnknownAnalyzer(t *testing.T) {
+	if _, err := Parse([]byte(`{"fields": {"a": {"analyzer": "nope"}}}`), nil); err == nil {
+		t.Error("expected error for unknown analyzer")
+	}
+	if _, err := Parse([]byte(`{"fields": {"a": {"analyzer": "keyword", "options": {"lowercase": "maybe"}}}}`), nil); err == nil {
+		t.Error("expected error for invalid option")
+	}
+	if _, err := Parse([]byte(`{"fields": {"[": {"analyzer": "keyword"}}}`), nil); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestMapping_Similarity(t *testing.T) {
+	m, err := Parse([]byte(`{
+		"default_similarity": "lm_dirichlet",
+		"default_similarity_options": {"mu": "500"},
+		"fields": {
+			"title": {"similarity": "bm25", "similarity_options": {"k1": "2", "b": "0.5"}},
+			"tags": {"type": "numeric", "similarity": "constant"}
+		}
+	}`), nil)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	if got := m.SimilarityFor("title").String(); got != "BM25(k1=2, b=0.5)" {
+		t.Errorf("title: got %s", got)
+	}
+	if got := m.SimilarityFor("tags").String(); got != "Constant(1)" {
+		t.Errorf("tags: got %s", got)
+	}
+	if got := m.SimilarityFor("body").String(); got != "LMDirichlet(mu=500)" {
+		t.Errorf("body: expected the default similarity, got %s", got)
+	}
+	if New(nil).SimilarityFor("body") != nil {
+		t.Error("expected no similarity without a mapping")
+	}
+
+	if _, err := Parse([]byte(`{"fields": {"a": {"similarity": "nope"}}}`), nil); err == nil {
+		t.Error("expected error for unknown similarity")
+	}
+	if _, err := Parse([]byte(`{"default_similarity": "bm25", "default_similarity_options": {"k": "1"}}`), nil); err == nil {
+		t.Error("expected error for unknown similarity option")
+	}
+}
+
+func TestMapping_Equal(t *testing.T) {
+	a, _ := Parse([]byte(`{"fields": {"sku": {"analyzer": "keyword"}}}`), nil)
+	b, _ := Parse([]byte(`{ "fields": { "sku": { "analyzer": "keyword" } } }`), nil)
+	c, _ := Parse([]byte(`{"fields": {"sku": {"analyzer": "whitespace"}}}`), nil)
+
+	if !a.Equal(b) {
+		t.Error("expected equal mappings")
+	}
+	if a.Equal(c) {
+		t.Error("expected different mappings")
+	}
+}
@@ -2,7 +2,10 @@ package query
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 // Query is the interface for all query types.
@@ -26,19 +29,45 @@ func (q *TermQuery) String() string {
 	return fmt.Sprintf("term(%s)", q.Term)
 }
 
-// PhraseQuery searches for an exact phrase.
+// PhraseQuery searches for a phrase. With a Slop, its terms may be up to
+// Slop position moves out of place, and tighter matches score higher.
 type PhraseQuery struct {
 	Field  string
 	Phrase string
+	Slop   int
 }
 
 func (q *PhraseQuery) queryNode() {}
 
 func (q *PhraseQuery) String() string {
+	var slop string
+	if q.Slop > 0 {
+		slop = fmt.Sprintf("~%d", q.Slop)
+	}
 	if q.Field != "" {
-		return fmt.Sprintf("phrase(%s:\"%s\")", q.Field, q.Phrase)
+		return fmt.Sprintf("phrase(%s:\"%s\"%s)", q.Field, q.Phrase, slop)
 	}
-	return fmt.Sprintf("phrase(\"%s\")", q.Phrase)
+	return fmt.Sprintf("phrase(\"%s\"%s)", q.Phrase, slop)
+}
+
+// NearQuery searches for terms that occur in any order with at most
+// Distance other positions between them; tighter matches score higher.
+// When the terms have synonyms, each combination of them is searched, and a
+// query with more than 64 combinations fails.
+type NearQuery struct {
+	Field    string
+	Terms    []string
+	Distance int
+}
+
+func (q *NearQuery) queryNode() {}
+
+func (q *NearQuery) String() string {
+	terms := strings.Join(q.Terms, fmt.Sprintf(" NEAR/%d ", q.Distance))
+	if q.Field != "" {
+		return fmt.Sprintf("near(%s:%s)", q.Field, terms)
+	}
+	return fmt.Sprintf("near(%s)", terms)
 }
 
 // PrefixQuery searches for terms starting with a prefix.
@@ -87,6 +116,287 @@ func (q *FuzzyQuery) String() string {
 	return fmt.Sprintf("fuzzy(%s~%d)", q.Term, q.Fuzziness)
 }
 
+// NumericRangeQuery matches numeric values between Min and Max.
+// A nil bound leaves that side of the range open.
+type NumericRangeQuery struct {
+	Field        string
+	Min          *float64
+	Max          *float64
+	MinInclusive bool
+	MaxInclusive bool
+}
+
+func (q *NumericRangeQuery) queryNode() {}
+
+func (q *NumericRangeQuery) String() string {
+	lower, upper := "[", "]"
+	if !q.MinInclusive {
+		lower = "{"
+	}
+	if !q.MaxInclusive {
+		upper = "}"
+	}
+	return fmt.Sprintf("range(%s:%s%s TO %s%s)", q.Field, lower, formatBound(q.Min), formatBound(q.Max), upper)
+}
+
+func formatBound(v *float64) string {
+	if v == nil {
+		return "*"
+	}
+	return strconv.FormatFloat(*v, 'g', -1, 64)
+}
+
+// DateRangeQuery matches date values between Start and End. Bounds are
+// dates in the field's formats or date math such as "now-7d"; they are
+// resolved when the query runs. An empty bound leaves that side open.
+type DateRangeQuery struct {
+	Field          string
+	Start          string
+	End            string
+	StartInclusive bool
+	EndInclusive   bool
+}
+
+func (q *DateRangeQuery) queryNode() {}
+
+func (q *DateRangeQuery) String() string {
+	lower, upper := "[", "]"
+	if !q.StartInclusive {
+		lower = "{"
+	}
+	if !q.EndInclusive {
+		upper = "}"
+	}
+	start, end := q.Start, q.End
+	if start == "" {
+		start = "*"
+	}
+	if end == "" {
+		end = "*"
+	}
+	return fmt.Sprintf("daterange(%s:%s%s TO %s%s)", q.Field, lower, start, end, upper)
+}
+
+// MultiMatchType selects how a MultiMatchQuery combines its fields.
+type MultiMatchType int
+
+const (
+	// BestFields scores a document by its best field; the other fields add
+	// TieBreaker times their score.
+	BestFields MultiMatchType = iota
+	// MostFields adds up the scores of every matching field.
+	MostFields
+	// CrossFields searches the fields as if they were one: each term takes
+	// the document frequency of its most common field and scores its best
+	// field, so a term in a short field is not favoured only for being rare
+	// there.
+	CrossFields
+)
+
+func (t MultiMatchType) String() string {
+	switch t {
+	case BestFields:
+		return "best_fields"
+	case MostFields:
+		return "most_fields"
+	case CrossFields:
+		return "cross_fields"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseMultiMatchType parses "best_fields", "most_fields" or "cross_fields".
+func ParseMultiMatchType(s string) (MultiMatchType, error) {
+	for _, t := range []MultiMatchType{BestFields, MostFields, CrossFields} {
+		if s == t.String() {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown multi-match type: %s", s)
+}
+
+// FieldBoost is a field searched by a MultiMatchQuery and the factor its
+// scores are multiplied by.
+type FieldBoost struct {
+	Field string
+	Boost float64
+}
+
+func (f FieldBoost) String() string {
+	if f.Boost == 1 {
+		return f.Field
+	}
+	return fmt.Sprintf("%s^%g", f.Field, f.Boost)
+}
+
+// ParseFieldBoosts parses fields separated by spaces or commas, each
+// optionally boosted as in "title^3 body".
+func ParseFieldBoosts(s string) ([]FieldBoost, error) {
+	var fields []FieldBoost
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		field, boost := part, 1.0
+		if i := strings.LastIndex(part, "^"); i >= 0 {
+			b, err := parseBoost(part[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			field, boost = part[:i], b
+		}
+		if field == "" {
+			return nil, fmt.Errorf("missing field name in %q", part)
+		}
+		fields = append(fields, FieldBoost{Field: field, Boost: boost})
+	}
+	return fields, nil
+}
+
+// parseBoost parses the factor of a "^N" boost.
+func parseBoost(s string) (float64, error) {
+	boost, err := strconv.ParseFloat(s, 64)
+	if err != nil || boost < 0 || math.IsInf(boost, 0) || math.IsNaN(boost) {
+		return 0, fmt.Errorf("invalid boost: %q", s)
+	}
+	return boost, nil
+}
+
+// MultiMatchQuery searches text in several fields, term by term: a document
+// matches if any term of the text is in any of the fields. Without Fields it
+// searches every field.
+type MultiMatchQuery struct {
+	Text       string
+	Fields     []FieldBoost
+	Type       MultiMatchType
+	TieBreaker float64 // weight of the fields other than the best one
+}
+
+func (q *MultiMatchQuery) queryNode() {}
+
+func (q *MultiMatchQuery) String() string {
+	fields := make([]string, len(q.Fields))
+	for i, f := range q.Fields {
+		fields[i] = f.String()
+	}
+	return fmt.Sprintf("multi_match(%s, [%s]:%q)", q.Type, strings.Join(fields, " "), q.Text)
+}
+
+// BoostQuery multiplies the scores of a query by Boost.
+type BoostQuery struct {
+	Query Query
+	Boost float64
+}
+
+func (q *BoostQuery) queryNode() {}
+
+func (q *BoostQuery) String() string {
+	return fmt.Sprintf("%s^%g", q.Query, q.Boost)
+}
+
+// SpanQuery matches spans: runs of positions in a field, which span queries
+// combine by proximity. The leaves of a span query must all search the same
+// field, or all name none to search every field.
+type SpanQuery interface {
+	Query
+	spanNode()
+}
+
+// SpanTermQuery matches each position of a term, normalized like the field.
+type SpanTermQuery struct {
+	Field string
+	Term  string
+}
+
+func (q *SpanTermQuery) queryNode() {}
+func (q *SpanTermQuery) spanNode()  {}
+
+func (q *SpanTermQuery) String() string {
+	if q.Field != "" {
+		return fmt.Sprintf("span_term(%s:%s)", q.Field, q.Term)
+	}
+	return fmt.Sprintf("span_term(%s)", q.Term)
+}
+
+// SpanMultiQuery matches each position of the terms a PrefixQuery,
+// RegexQuery or FuzzyQuery expands to.
+type SpanMultiQuery struct {
+	Query Query
+}
+
+func (q *SpanMultiQuery) queryNode() {}
+func (q *SpanMultiQuery) spanNode()  {}
+
+func (q *SpanMultiQuery) String() string {
+	return fmt.Sprintf("span_multi(%s)", q.Query)
+}
+
+// SpanNearQuery matches a span of each clause with at most Slop positions
+// between them in total. With InOrder the spans must follow each other in
+// the order of the clauses without overlapping; otherwise they may come in
+// any order.
+type SpanNearQuery struct {
+	Clauses []SpanQuery
+	Slop    int
+	InOrder bool
+}
+
+func (q *SpanNearQuery) queryNode() {}
+func (q *SpanNearQuery) spanNode()  {}
+
+func (q *SpanNearQuery) String() string {
+	return fmt.Sprintf("span_near([%s], slop=%d, in_order=%t)", joinSpans(q.Clauses), q.Slop, q.InOrder)
+}
+
+// SpanOrQuery matches the spans of any of its clauses.
+type SpanOrQuery struct {
+	Clauses []SpanQuery
+}
+
+func (q *SpanOrQuery) queryNode() {}
+func (q *SpanOrQuery) spanNode()  {}
+
+func (q *SpanOrQuery) String() string {
+	return fmt.Sprintf("span_or([%s])", joinSpans(q.Clauses))
+}
+
+// SpanNotQuery matches the spans of Include that no span of Exclude
+// overlaps, counting Pre positions before and Post positions after each
+// span of Include as part of it.
+type SpanNotQuery struct {
+	Include SpanQuery
+	Exclude SpanQuery
+	Pre     int
+	Post    int
+}
+
+func (q *SpanNotQuery) queryNode() {}
+func (q *SpanNotQuery) spanNode()  {}
+
+func (q *SpanNotQuery) String() string {
+	return fmt.Sprintf("span_not(%s, %s, pre=%d, post=%d)", q.Include, q.Exclude, q.Pre, q.Post)
+}
+
+// SpanFirstQuery matches the spans of Match that end within the first End
+// positions of the field.
+type SpanFirstQuery struct {
+	Match SpanQuery
+	End   int
+}
+
+func (q *SpanFirstQuery) queryNode() {}
+func (q *SpanFirstQuery) spanNode()  {}
+
+func (q *SpanFirstQuery) String() string {
+	return fmt.Sprintf("span_first(%s, end=%d)", q.Match, q.End)
+}
+
+func joinSpans(clauses []SpanQuery) string {
+	strs := make([]string, len(clauses))
+	for i, c := range clauses {
+		strs[i] = c.String()
+	}
+	return strings.Join(strs, ", ")
+}
+
 // BoolQuery combines multiple queries with boolean logic.
 type BoolQuery struct {
 	Must    []Query
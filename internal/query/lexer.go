@@ -20,6 +20,10 @@ const (
 	TokenPrefix
 	TokenRegex
 	TokenFuzzy
+	TokenRange
+	TokenBoost
+	TokenSlop
+	TokenNear
 	TokenEOF
 )
 
@@ -47,6 +51,14 @@ func (t TokenType) String() string {
 		return "REGEX"
 	case TokenFuzzy:
 		return "FUZZY"
+	case TokenRange:
+		return "RANGE"
+	case TokenBoost:
+		return "BOOST"
+	case TokenSlop:
+		return "SLOP"
+	case TokenNear:
+		return "NEAR"
 	case TokenEOF:
 		return "EOF"
 	default:
@@ -127,6 +139,14 @@ func (l *Lexer) NextToken() (Token, error) {
 		return l.readPhrase()
 	case '/':
 		return l.readRegex()
+	case '[', '{':
+		return l.readRange()
+	case '>', '<':
+		return l.readComparison()
+	case '^':
+		return l.readBoost()
+	case '~':
+		return l.readSlop()
 	}
 
 	return l.readWord()
@@ -172,7 +192,7 @@ func (l *Lexer) readWord() (Token, error) {
 		l.pos++
 	}
 
-	word := l.input[start:l.pos]
+	word := l.cutBoost(start, l.input[start:l.pos])
 	if word == "" {
 		return Token{}, fmt.Errorf("unexpected character at position %d", l.pos)
 	}
@@ -186,6 +206,13 @@ func (l *Lexer) readWord() (Token, error) {
 		return Token{Type: TokenNot, Value: word}, nil
 	}
 
+	if distance, ok := strings.CutPrefix(word, "NEAR/"); ok {
+		if !isDigits(distance) {
+			return Token{}, fmt.Errorf("invalid NEAR distance at position %d: %q", start, distance)
+		}
+		return Token{Type: TokenNear, Value: distance}, nil
+	}
+
 	if colonIdx := strings.Index(word, ":"); colonIdx > 0 {
 		field := word[:colonIdx]
 		if colonIdx < len(word)-1 {
@@ -235,6 +262,104 @@ func (l *Lexer) readRegex() (Token, error) {
 	return Token{Type: TokenRegex, Value: value}, nil
 }
 
+// readRange reads a bracketed range such as [10 TO 100] or {10 TO *].
+// The token value keeps the brackets, which select inclusive bounds.
+func (l *Lexer) readRange() (Token, error) {
+	start := l.pos
+	l.pos++
+
+	for l.pos < len(l.input) && l.input[l.pos] != ']' && l.input[l.pos] != '}' {
+		l.pos++
+	}
+
+	if l.pos >= len(l.input) {
+		return Token{}, fmt.Errorf("unterminated range at position %d", start)
+	}
+	l.pos++
+
+	return Token{Type: TokenRange, Value: l.input[start:l.pos]}, nil
+}
+
+// readComparison reads a one-sided range such as >2000 or <=10.
+func (l *Lexer) readComparison() (Token, error) {
+	start := l.pos
+	l.pos++
+	if l.pos < len(l.input) && l.input[l.pos] == '=' {
+		l.pos++
+	}
+
+	for l.pos < len(l.input) {
+		ch := l.input[l.pos]
+		if unicode.IsSpace(rune(ch)) || ch == '(' || ch == ')' || ch == '"' {
+			break
+		}
+		l.pos++
+	}
+
+	return Token{Type: TokenRange, Value: l.cutBoost(start, l.input[start:l.pos])}, nil
+}
+
+// readSlop reads the slop of a phrase, such as ~2 in "quick fox"~2.
+func (l *Lexer) readSlop() (Token, error) {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.input) && l.input[l.pos] >= '0' && l.input[l.pos] <= '9' {
+		l.pos++
+	}
+
+	value := l.input[start+1 : l.pos]
+	if value == "" {
+		return Token{}, fmt.Errorf("invalid slop at position %d: expected a number after ~", start)
+	}
+	return Token{Type: TokenSlop, Value: value}, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, ch := range s {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// readBoost reads a boost such as ^2 or ^0.5 following a clause.
+func (l *Lexer) readBoost() (Token, error) {
+	start := l.pos
+	l.pos++
+
+	for l.pos < len(l.input) {
+		ch := l.input[l.pos]
+		if unicode.IsSpace(rune(ch)) || ch == '(' || ch == ')' || ch == '"' {
+			break
+		}
+		l.pos++
+	}
+
+	value := l.input[start+1 : l.pos]
+	if _, err := parseBoost(value); err != nil {
+		return Token{}, fmt.Errorf("invalid boost at position %d: %w", start, err)
+	}
+	return Token{Type: TokenBoost, Value: value}, nil
+}
+
+// cutBoost removes a trailing ^N boost from the word read from start, and
+// moves back so the boost is read as its own token.
+func (l *Lexer) cutBoost(start int, word string) string {
+	i := strings.LastIndex(word, "^")
+	if i <= 0 {
+		return word
+	}
+	if _, err := parseBoost(word[i+1:]); err != nil {
+		return word
+	}
+	l.pos = start + i
+	return word[:i]
+}
+
 func (l *Lexer) readTerm() (Token, error) {
 	start := l.pos
 
@@ -246,7 +371,7 @@ func (l *Lexer) readTerm() (Token, error) {
 		l.pos++
 	}
 
-	word := l.input[start:l.pos]
+	word := l.cutBoost(start, l.input[start:l.pos])
 
 	if strings.HasSuffix(word, "*") {
 		prefix := strings.TrimSuffix(word, "*")
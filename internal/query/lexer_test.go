@@ -137,6 +137,52 @@ func TestTokenize(t *testing.T) {
 				{Type: TokenEOF},
 			},
 		},
+		{
+			name:  "range",
+			input: "price:[10 TO 100] year:>2000",
+			expected: []Token{
+				{Type: TokenField, Value: "price"},
+				{Type: TokenRange, Value: "[10 TO 100]"},
+				{Type: TokenField, Value: "year"},
+				{Type: TokenRange, Value: ">2000"},
+				{Type: TokenEOF},
+			},
+		},
+		{
+			name:  "boosts",
+			input: `title:go^3 "hello world"^2 (a OR b)^0.5 year:>2000^2 x^y`,
+			expected: []Token{
+				{Type: TokenField, Value: "title"},
+				{Type: TokenTerm, Value: "go"},
+				{Type: TokenBoost, Value: "3"},
+				{Type: TokenPhrase, Value: "hello world"},
+				{Type: TokenBoost, Value: "2"},
+				{Type: TokenLParen, Value: "("},
+				{Type: TokenTerm, Value: "a"},
+				{Type: TokenOr, Value: "OR"},
+				{Type: TokenTerm, Value: "b"},
+				{Type: TokenRParen, Value: ")"},
+				{Type: TokenBoost, Value: "0.5"},
+				{Type: TokenField, Value: "year"},
+				{Type: TokenRange, Value: ">2000"},
+				{Type: TokenBoost, Value: "2"},
+				{Type: TokenTerm, Value: "x^y"},
+				{Type: TokenEOF},
+			},
+		},
+		{
+			name:  "proximity",
+			input: `"quick fox"~2^3 quick NEAR/5 fox~1`,
+			expected: []Token{
+				{Type: TokenPhrase, Value: "quick fox"},
+				{Type: TokenSlop, Value: "2"},
+				{Type: TokenBoost, Value: "3"},
+				{Type: TokenTerm, Value: "quick"},
+				{Type: TokenNear, Value: "5"},
+				{Type: TokenFuzzy, Value: "fox~1"},
+				{Type: TokenEOF},
+			},
+		},
 		}
 
 	for _, tt := range tests {
@@ -167,6 +213,13 @@ func TestTokenize_UnterminatedPhrase(t *testing.T) {
 	}
 }
 
+func TestTokenize_UnterminatedRange(t *testing.T) {
+	_, err := Tokenize(`price:[10 TO 100`)
+	if err == nil {
+		t.Error("expected error for unterminated range")
+	}
+}
+
 func TestTokenize_UnterminatedRegex(t *testing.T) {
 	_, err := Tokenize(`/hello.*`)
 	if err == nil {
@@ -174,3 +227,17 @@ func TestTokenize_UnterminatedRegex(t *testing.T) {
 	}
 }
 
+func TestTokenize_InvalidProximity(t *testing.T) {
+	for _, input := range []string{`"quick fox"~`, `"quick fox"~x`, `quick NEAR/x fox`} {
+		if _, err := Tokenize(input); err == nil {
+			t.Errorf("Tokenize(%q): expected error", input)
+		}
+	}
+}
+
+func TestTokenize_InvalidBoost(t *testing.T) {
+	_, err := Tokenize(`"hello"^x`)
+	if err == nil {
+		t.Error("expected error for invalid boost")
+	}
+}
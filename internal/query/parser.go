@@ -2,6 +2,7 @@ package query
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -106,7 +107,7 @@ func (p *Parser) parseAndExpr() (Query, error) {
 		next := p.peek()
 		if next.Type == TokenTerm || next.Type == TokenPhrase || next.Type == TokenField ||
 			next.Type == TokenPrefix || next.Type == TokenRegex || next.Type == TokenFuzzy ||
-			next.Type == TokenLParen || next.Type == TokenNot {
+			next.Type == TokenRange || next.Type == TokenLParen || next.Type == TokenNot {
 			right, err := p.parseUnaryExpr()
 			if err != nil {
 				return nil, err
@@ -128,14 +129,82 @@ func (p *Parser) parseAndExpr() (Query, error) {
 func (p *Parser) parseUnaryExpr() (Query, error) {
 	if p.peek().Type == TokenNot {
 		p.advance()
-		expr, err := p.parsePrimary()
+		expr, err := p.parseBoosted()
 		if err != nil {
 			return nil, err
 		}
 		return &BoolQuery{MustNot: []Query{expr}}, nil
 	}
 
-	return p.parsePrimary()
+	return p.parseBoosted()
+}
+
+// parseBoosted parses a primary expression followed by an optional ^N boost.
+func (p *Parser) parseBoosted() (Query, error) {
+	expr, err := p.parseNear()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().Type != TokenBoost {
+		return expr, nil
+	}
+	boost, err := parseBoost(p.advance().Value)
+	if err != nil {
+		return nil, err
+	}
+	return &BoostQuery{Query: expr, Boost: boost}, nil
+}
+
+// parseNear parses a primary expression, or terms joined by NEAR/n, all
+// with the same field and distance.
+func (p *Parser) parseNear() (Query, error) {
+	expr, err := p.parsePrimary()
+	if err != nil || p.peek().Type != TokenNear {
+		return expr, err
+	}
+
+	first, ok := expr.(*TermQuery)
+	if !ok {
+		return nil, fmt.Errorf("NEAR operands must be terms, got %s", expr)
+	}
+	near := &NearQuery{Field: first.Field, Terms: []string{first.Term}, Distance: -1}
+	for p.peek().Type == TokenNear {
+		distance, err := strconv.Atoi(p.advance().Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NEAR distance: %w", err)
+		}
+		if near.Distance >= 0 && distance != near.Distance {
+			return nil, fmt.Errorf("NEAR distances must be the same, got %d and %d", near.Distance, distance)
+		}
+		near.Distance = distance
+
+		expr, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		term, ok := expr.(*TermQuery)
+		if !ok {
+			return nil, fmt.Errorf("NEAR operands must be terms, got %s", expr)
+		}
+		if term.Field != near.Field {
+			return nil, fmt.Errorf("NEAR operands must search the same field, got %q and %q", near.Field, term.Field)
+		}
+		near.Terms = append(near.Terms, term.Term)
+	}
+	return near, nil
+}
+
+// parseSlop parses the optional ~N slop after a phrase.
+func (p *Parser) parseSlop() (int, error) {
+	if p.peek().Type != TokenSlop {
+		return 0, nil
+	}
+	slop, err := strconv.Atoi(p.advance().Value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid slop: %w", err)
+	}
+	return slop, nil
 }
 
 func (p *Parser) parsePrimary() (Query, error) {
@@ -148,7 +217,11 @@ func (p *Parser) parsePrimary() (Query, error) {
 		return p.parseFieldExpr()
 	case TokenPhrase:
 		p.advance()
-		return &PhraseQuery{Phrase: token.Value}, nil
+		slop, err := p.parseSlop()
+		if err != nil {
+			return nil, err
+		}
+		return &PhraseQuery{Phrase: token.Value, Slop: slop}, nil
 	case TokenPrefix:
 		p.advance()
 		return &PrefixQuery{Prefix: token.Value}, nil
@@ -161,6 +234,8 @@ func (p *Parser) parsePrimary() (Query, error) {
 	case TokenTerm:
 		p.advance()
 		return &TermQuery{Term: token.Value}, nil
+	case TokenRange:
+		return nil, fmt.Errorf("range %s requires a field", token.Value)
 	case TokenEOF:
 		return nil, fmt.Errorf("unexpected end of query")
 	default:
@@ -208,7 +283,11 @@ func (p *Parser) parseFieldExpr() (Query, error) {
 	switch valueToken.Type {
 	case TokenPhrase:
 		p.advance()
-		return &PhraseQuery{Field: field, Phrase: valueToken.Value}, nil
+		slop, err := p.parseSlop()
+		if err != nil {
+			return nil, err
+		}
+		return &PhraseQuery{Field: field, Phrase: valueToken.Value, Slop: slop}, nil
 	case TokenPrefix:
 		p.advance()
 		return &PrefixQuery{Field: field, Prefix: valueToken.Value}, nil
@@ -221,9 +300,101 @@ func (p *Parser) parseFieldExpr() (Query, error) {
 	case TokenTerm:
 		p.advance()
 		return &TermQuery{Field: field, Term: valueToken.Value}, nil
+	case TokenRange:
+		p.advance()
+		return parseRange(valueToken.Value, field)
 	case TokenEOF, TokenRParen, TokenAnd, TokenOr:
 		return nil, fmt.Errorf("expected term after field '%s:'", field)
 	default:
 		return nil, fmt.Errorf("expected term after field '%s:', got %s", field, valueToken)
 	}
 }
+
+// parseRange parses "[min TO max]" (braces exclude a bound, * leaves it open)
+// or a comparison such as ">2000" or "<=10". Numeric bounds give a
+// NumericRangeQuery; anything else is taken as a DateRangeQuery.
+func parseRange(value, field string) (Query, error) {
+	lower, upper := "*", "*"
+	lowerInclusive, upperInclusive := true, true
+
+	if op, bound, ok := cutComparison(value); ok {
+		if bound == "" || bound == "*" {
+			return nil, fmt.Errorf("invalid range bound in %s", value)
+		}
+		switch op {
+		case ">":
+			lower, lowerInclusive = bound, false
+		case ">=":
+			lower = bound
+		case "<":
+			upper, upperInclusive = bound, false
+		case "<=":
+			upper = bound
+		}
+	} else {
+		if len(value) < 2 {
+			return nil, fmt.Errorf("invalid range: %s", value)
+		}
+		lowerInclusive = value[0] == '['
+		upperInclusive = value[len(value)-1] == ']'
+
+		parts := strings.Fields(value[1 : len(value)-1])
+		if len(parts) != 3 || parts[1] != "TO" {
+			return nil, fmt.Errorf("invalid range: %s (expected [min TO max])", value)
+		}
+		lower, upper = parts[0], parts[2]
+	}
+
+	min, minOK, err := parseBound(lower)
+	if err != nil {
+		return nil, err
+	}
+	max, maxOK, err := parseBound(upper)
+	if err != nil {
+		return nil, err
+	}
+	if minOK && maxOK {
+		return &NumericRangeQuery{
+			Field:        field,
+			Min:          min,
+			Max:          max,
+			MinInclusive: lowerInclusive,
+			MaxInclusive: upperInclusive,
+		}, nil
+	}
+
+	q := &DateRangeQuery{Field: field, StartInclusive: lowerInclusive, EndInclusive: upperInclusive}
+	if lower != "*" {
+		q.Start = lower
+	}
+	if upper != "*" {
+		q.End = upper
+	}
+	return q, nil
+}
+
+func cutComparison(value string) (op, bound string, ok bool) {
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if strings.HasPrefix(value, op) {
+			return op, value[len(op):], true
+		}
+	}
+	return "", "", false
+}
+
+// parseBound parses a numeric range bound; "*" is an open bound and
+// returns nil. ok is false if the bound is not a number. NaN and infinite
+// bounds are rejected: they never match an indexed numeric term.
+func parseBound(s string) (v *float64, ok bool, err error) {
+	if s == "*" {
+		return nil, true, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, false, nil
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, false, fmt.Errorf("invalid range bound %q: not a finite number", s)
+	}
+	return &f, true, nil
+}
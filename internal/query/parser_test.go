@@ -26,6 +26,17 @@ func TestParse_FieldTermQuery(t *testing.T) {
 	}
 }
 
+func TestParse_DottedFieldName(t *testing.T) {
+	q, err := Parse(mustTokenize(t, "author.name:ada"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tq := assertTermQuery(t, q)
+	if tq.Term != "ada" || tq.Field != "author.name" {
+		t.Errorf("got Term=%q Field=%q, want Term=ada Field=author.name", tq.Term, tq.Field)
+	}
+}
+
 func TestParse_PhraseQuery(t *testing.T) {
 	q, err := Parse(mustTokenize(t, `"hello world"`))
 	if err != nil {
@@ -251,6 +262,12 @@ func TestParse_Errors(t *testing.T) {
 	}{
 		{"unmatched paren", "(hello world"},
 		{"field without value", "title:"},
+		{"range without field", "[1 TO 2]"},
+		{"range without TO", "price:[1 2]"},
+		{"comparison without value", "price:>"},
+		{"NaN range bound", "price:[1 TO NaN]"},
+		{"infinite range bound", "price:[-Inf TO 5]"},
+		{"infinite comparison bound", "price:>Inf"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -274,6 +291,174 @@ func TestParse_InvalidFuzziness(t *testing.T) {
 	}
 }
 
+func TestParse_NumericRangeQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"price:[10 TO 100]", "range(price:[10 TO 100])"},
+		{"price:{10 TO 100]", "range(price:{10 TO 100])"},
+		{"price:[* TO 1.5}", "range(price:[* TO 1.5})"},
+		{"year:>2000", "range(year:{2000 TO *])"},
+		{"year:>=2000", "range(year:[2000 TO *])"},
+		{"year:<-5", "range(year:[* TO -5})"},
+		{"year:<=2000", "range(year:[* TO 2000])"},
+	}
+	for _, tt := range tests {
+		q, err := Parse(mustTokenize(t, tt.input))
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", tt.input, err)
+		}
+		if _, ok := q.(*NumericRangeQuery); !ok {
+			t.Fatalf("expected *NumericRangeQuery, got %T", q)
+		}
+		if q.String() != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.input, q, tt.want)
+		}
+	}
+}
+
+func TestParse_DateRangeQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"created:[2024-01-01 TO now-7d]", "daterange(created:[2024-01-01 TO now-7d])"},
+		{"created:{now-1M/d TO *]", "daterange(created:{now-1M/d TO *])"},
+		{"created:>2024-01-01T10:00:00Z", "daterange(created:{2024-01-01T10:00:00Z TO *])"},
+		{"created:<=now", "daterange(created:[* TO now])"},
+	}
+	for _, tt := range tests {
+		q, err := Parse(mustTokenize(t, tt.input))
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", tt.input, err)
+		}
+		if _, ok := q.(*DateRangeQuery); !ok {
+			t.Fatalf("expected *DateRangeQuery, got %T", q)
+		}
+		if q.String() != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.input, q, tt.want)
+		}
+	}
+}
+
+func TestParse_RangeInBoolQuery(t *testing.T) {
+	q, err := Parse(mustTokenize(t, "laptop AND price:[* TO 1000]"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bq := assertBoolQuery(t, q)
+	if len(bq.Must) != 2 {
+		t.Fatalf("expected 2 must clauses, got %d", len(bq.Must))
+	}
+	if _, ok := bq.Must[1].(*NumericRangeQuery); !ok {
+		t.Errorf("expected *NumericRangeQuery, got %T", bq.Must[1])
+	}
+}
+
+func TestParse_Boost(t *testing.T) {
+	q, err := Parse(mustTokenize(t, `title:go^3 OR ("hello world" -spam)^0.5`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bq := assertBoolQuery(t, q)
+	if len(bq.Should) != 2 {
+		t.Fatalf("expected 2 should clauses, got %d", len(bq.Should))
+	}
+
+	first, ok := bq.Should[0].(*BoostQuery)
+	if !ok || first.Boost != 3 {
+		t.Fatalf("expected title:go boosted by 3, got %s", bq.Should[0])
+	}
+	if tq := assertTermQuery(t, first.Query); tq.Field != "title" || tq.Term != "go" {
+		t.Errorf("got Term=%q Field=%q, want Term=go Field=title", tq.Term, tq.Field)
+	}
+
+	second, ok := bq.Should[1].(*BoostQuery)
+	if !ok || second.Boost != 0.5 {
+		t.Fatalf("expected group boosted by 0.5, got %s", bq.Should[1])
+	}
+	assertBoolQuery(t, second.Query)
+
+	for _, input := range []string{"^2", "hello^2 ^3", "title:^2"} {
+		if _, err := Parse(mustTokenize(t, input)); err == nil {
+			t.Errorf("Parse(%q): expected error", input)
+		}
+	}
+}
+
+func TestParse_PhraseSlop(t *testing.T) {
+	q, err := Parse(mustTokenize(t, `title:"quick fox"~2 "lazy dog"`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bq := assertBoolQuery(t, q)
+	if len(bq.Must) != 2 {
+		t.Fatalf("expected 2 must clauses, got %d", len(bq.Must))
+	}
+	if pq := assertPhraseQuery(t, bq.Must[0]); pq.Field != "title" || pq.Phrase != "quick fox" || pq.Slop != 2 {
+		t.Errorf("got %s, want title:\"quick fox\"~2", pq)
+	}
+	if pq := assertPhraseQuery(t, bq.Must[1]); pq.Slop != 0 {
+		t.Errorf("got slop %d, want 0", pq.Slop)
+	}
+
+	if _, err := Parse(mustTokenize(t, `quick "fox"~2~3`)); err == nil {
+		t.Error("expected error for a repeated slop")
+	}
+}
+
+func TestParse_Near(t *testing.T) {
+	q, err := Parse(mustTokenize(t, "body:quick NEAR/3 body:brown NEAR/3 body:fox AND dog"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bq := assertBoolQuery(t, q)
+	if len(bq.Must) != 2 {
+		t.Fatalf("expected 2 must clauses, got %d", len(bq.Must))
+	}
+	nq, ok := bq.Must[0].(*NearQuery)
+	if !ok {
+		t.Fatalf("expected *NearQuery, got %T", bq.Must[0])
+	}
+	if nq.Field != "body" || nq.Distance != 3 || len(nq.Terms) != 3 || nq.Terms[2] != "fox" {
+		t.Errorf("unexpected near query: %+v", nq)
+	}
+
+	for _, input := range []string{
+		"quick NEAR/3 fox NEAR/2 dog",
+		`quick NEAR/3 "brown fox"`,
+		"title:quick NEAR/3 fox",
+		"quick NEAR/3",
+	} {
+		if _, err := Parse(mustTokenize(t, input)); err == nil {
+			t.Errorf("Parse(%q): expected error", input)
+		}
+	}
+}
+
+func TestParseFieldBoosts(t *testing.T) {
+	fields, err := ParseFieldBoosts("title^3 body, tags^0.5")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []FieldBoost{{"title", 3}, {"body", 1}, {"tags", 0.5}}
+	if len(fields) != len(want) {
+		t.Fatalf("got %v, want %v", fields, want)
+	}
+	for i := range want {
+		if fields[i] != want[i] {
+			t.Errorf("field %d: got %v, want %v", i, fields[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{"title^x", "^2", "body^-1"} {
+		if _, err := ParseFieldBoosts(bad); err == nil {
+			t.Errorf("ParseFieldBoosts(%q): expected error", bad)
+		}
+	}
+}
+
 // Helper functions
 
 func mustTokenize(t *testing.T, input string) []Token {
@@ -7,37 +7,125 @@ import (
 	"harshagw/postings/internal/query"
 )
 
-func (s *Searcher) boolSearch(q *query.BoolQuery) ([]Result, error) {
+// boolSearch matches the documents that satisfy every must clause and,
+// without must clauses, any should clause; should clauses alongside must
+// clauses narrow the match to documents matching at least one of them when
+// any do. A document scores the sum of the scores of the clauses it
+// matches, scaled by the fraction of clauses it matches (coordination).
+func (s *Searcher) boolSearch(q *query.BoolQuery, c collector) error {
 	// Flatten the query: extract nested MustNot clauses from Must
 	// This handles cases like "A AND NOT B" which parses as:
 	// BoolQuery{Must: [A, BoolQuery{MustNot: [B]}]}
 	must, mustNot, should := flattenBoolQuery(q)
 
-	if len(must) == 0 && len(should) == 0 && len(mustNot) > 0 {
-		return nil, fmt.Errorf("NOT queries require a positive clause")
+	if len(must) == 0 && len(should) == 0 {
+		if len(mustNot) > 0 {
+			return fmt.Errorf("NOT queries require a positive clause")
+		}
+		return nil
 	}
 
-	if len(must) == 0 && len(should) > 0 && len(mustNot) == 0 {
-		return s.executeOr(should)
+	if len(must)+len(should) == 1 && len(mustNot) == 0 {
+		return s.run(slices.Concat(must, should)[0], c)
 	}
 
-	if len(must) > 0 && len(should) == 0 && len(mustNot) == 0 {
-		return s.executeAnd(must)
+	mustClauses, err := s.executeClauses(must)
+	if err != nil {
+		return err
+	}
+	for _, clause := range mustClauses {
+		if clause.docs.IsEmpty() {
+			return nil // AND with empty = empty
+		}
+	}
+	shouldClauses, err := s.executeClauses(should)
+	if err != nil {
+		return err
+	}
+	var shouldSets []*docSet
+	for _, clause := range shouldClauses {
+		if !clause.docs.IsEmpty() {
+			shouldSets = append(shouldSets, clause.docs)
+		}
 	}
 
-	if len(must) > 0 && len(should) == 0 && len(mustNot) > 0 {
-		return s.executeAndNot(must, mustNot)
+	var result *docSet
+	if len(mustClauses) > 0 {
+		// Sort by count (smallest first) for optimal intersection
+		mustSets := make([]*docSet, len(mustClauses))
+		for i, clause := range mustClauses {
+			mustSets[i] = clause.docs
+		}
+		slices.SortFunc(mustSets, func(a, b *docSet) int {
+			return int(a.Count()) - int(b.Count())
+		})
+		result = intersectAll(mustSets)
+		if len(shouldSets) > 0 {
+			result = result.Intersect(unionAll(shouldSets))
+		}
+	} else {
+		if len(shouldSets) == 0 {
+			return nil
+		}
+		result = unionAll(shouldSets)
+	}
+	if result == nil || result.IsEmpty() {
+		return nil
 	}
 
-	if len(must) == 0 && len(should) > 0 && len(mustNot) > 0 {
-		return s.executeOrNot(should, mustNot)
+	result, err = s.subtractNot(result, mustNot)
+	if err != nil || result.IsEmpty() {
+		return err
 	}
 
-	if len(must) > 0 && len(should) > 0 {
-		return s.executeComplex(must, should, mustNot)
+	s.scoreBool(result, slices.Concat(mustClauses, shouldClauses), c)
+	return nil
+}
+
+// boolClause is an executed positive clause of a boolean query.
+type boolClause struct {
+	docs   *docSet
+	scores map[docKey]float64
+}
+
+// executeClauses runs the positive clauses of a boolean query.
+func (s *Searcher) executeClauses(queries []query.Query) ([]*boolClause, error) {
+	clauses := make([]*boolClause, len(queries))
+	for i, q := range queries {
+		hits, err := s.execute(q)
+		if err != nil {
+			return nil, err
+		}
+		c := &boolClause{docs: s.hitsToDocSet(hits), scores: make(map[docKey]float64, len(hits))}
+		for _, h := range hits {
+			c.scores[docKey{h.segmentIdx, h.docNum}] = h.score
+		}
+		clauses[i] = c
 	}
+	return clauses, nil
+}
 
-	return nil, nil
+// scoreBool scores the documents of result against the clauses.
+func (s *Searcher) scoreBool(result *docSet, clauses []*boolClause, c collector) {
+	s.collectDocSet(result, 0, collectorFunc(func(h hit) {
+		key := docKey{h.segmentIdx, h.docNum}
+		var sum float64
+		var matched int
+		for _, clause := range clauses {
+			if score, ok := clause.scores[key]; ok {
+				sum += score
+				matched++
+			}
+		}
+		h.score = coord(sum, matched, len(clauses))
+		c.collect(h)
+	}))
+}
+
+// coord scales the summed score of the matched clauses by the fraction of
+// clauses matched.
+func coord(sum float64, matched, total int) float64 {
+	return sum * float64(matched) / float64(total)
 }
 
 // flattenBoolQuery extracts nested MustNot clauses from Must/Should.
@@ -62,72 +150,6 @@ func flattenBoolQuery(q *query.BoolQuery) (must, mustNot, should []query.Query)
 	return must, mustNot, should
 }
 
-func (s *Searcher) executeOr(queries []query.Query) ([]Result, error) {
-	if len(queries) == 0 {
-		return nil, nil
-	}
-	if len(queries) == 1 {
-		return s.execute(queries[0])
-	}
-
-	// Get docSets for all queries
-	var sets []*docSet
-	for _, q := range queries {
-		ds, err := s.executeQueryToDocSet(q)
-		if err != nil {
-			return nil, err
-		}
-		if !ds.IsEmpty() {
-			sets = append(sets, ds)
-		}
-	}
-
-	if len(sets) == 0 {
-		return nil, nil
-	}
-
-	result := unionAll(sets)
-	if result == nil || result.IsEmpty() {
-		return nil, nil
-	}
-
-	return s.materializeResults(result, ""), nil
-}
-
-func (s *Searcher) executeAnd(queries []query.Query) ([]Result, error) {
-	if len(queries) == 0 {
-		return nil, nil
-	}
-	if len(queries) == 1 {
-		return s.execute(queries[0])
-	}
-
-	// Get docSets for all queries
-	var sets []*docSet
-	for _, q := range queries {
-		ds, err := s.executeQueryToDocSet(q)
-		if err != nil {
-			return nil, err
-		}
-		if ds.IsEmpty() {
-			return nil, nil // AND with empty = empty
-		}
-		sets = append(sets, ds)
-	}
-
-	// Sort by count (smallest first) for optimal intersection
-	slices.SortFunc(sets, func(a, b *docSet) int {
-		return int(a.Count()) - int(b.Count())
-	})
-
-	result := intersectAll(sets)
-	if result == nil || result.IsEmpty() {
-		return nil, nil
-	}
-
-	return s.materializeResults(result, ""), nil
-}
-
 // collectDocSets executes queries and collects their docSets.
 // If requireNonEmpty is true, returns nil on first empty set (for AND semantics).
 func (s *Searcher) collectDocSets(queries []query.Query, requireNonEmpty bool) ([]*docSet, error) {
@@ -162,75 +184,6 @@ func (s *Searcher) subtractNot(result *docSet, mustNot []query.Query) (*docSet,
 	return result, nil
 }
 
-func (s *Searcher) executeAndNot(must []query.Query, mustNot []query.Query) ([]Result, error) {
-	mustSets, err := s.collectDocSets(must, true)
-	if err != nil || mustSets == nil {
-		return nil, err
-	}
-
-	result := intersectAll(mustSets)
-	if result == nil || result.IsEmpty() {
-		return nil, nil
-	}
-
-	result, err = s.subtractNot(result, mustNot)
-	if err != nil || result.IsEmpty() {
-		return nil, err
-	}
-
-	return s.materializeResults(result, ""), nil
-}
-
-func (s *Searcher) executeOrNot(should []query.Query, mustNot []query.Query) ([]Result, error) {
-	shouldSets, err := s.collectDocSets(should, false)
-	if err != nil || len(shouldSets) == 0 {
-		return nil, err
-	}
-
-	result := unionAll(shouldSets)
-	if result == nil || result.IsEmpty() {
-		return nil, nil
-	}
-
-	result, err = s.subtractNot(result, mustNot)
-	if err != nil || result.IsEmpty() {
-		return nil, err
-	}
-
-	return s.materializeResults(result, ""), nil
-}
-
-func (s *Searcher) executeComplex(must []query.Query, should []query.Query, mustNot []query.Query) ([]Result, error) {
-	mustSets, err := s.collectDocSets(must, true)
-	if err != nil || mustSets == nil {
-		return nil, err
-	}
-
-	result := intersectAll(mustSets)
-	if result == nil || result.IsEmpty() {
-		return nil, nil
-	}
-
-	// Intersect with OR of should queries
-	shouldSets, err := s.collectDocSets(should, false)
-	if err != nil {
-		return nil, err
-	}
-	if len(shouldSets) > 0 {
-		result = result.Intersect(unionAll(shouldSets))
-	}
-	if result.IsEmpty() {
-		return nil, nil
-	}
-
-	result, err = s.subtractNot(result, mustNot)
-	if err != nil || result.IsEmpty() {
-		return nil, err
-	}
-
-	return s.materializeResults(result, ""), nil
-}
-
 // executeQueryToDocSet executes a query and returns results as a docSet.
 // This allows set-based boolean operations on any query type.
 func (s *Searcher) executeQueryToDocSet(q query.Query) (*docSet, error) {
@@ -239,48 +192,35 @@ func (s *Searcher) executeQueryToDocSet(q query.Query) (*docSet, error) {
 	}
 	switch v := q.(type) {
 	case *query.TermQuery:
-		return s.termDocSet(v.Term, v.Field), nil
-	case *query.PhraseQuery, *query.PrefixQuery, *query.RegexQuery, *query.FuzzyQuery, *query.BoolQuery:
-		// Execute query, convert results to docSet
-		results, err := s.execute(q)
+		return s.termQueryDocSet(v.Term, v.Field), nil
+	case *query.NumericRangeQuery:
+		return s.numericRangeDocSet(v), nil
+	case *query.DateRangeQuery:
+		return s.dateRangeDocSet(v)
+	case *query.BoostQuery:
+		return s.executeQueryToDocSet(v.Query)
+	case *query.PhraseQuery, *query.PrefixQuery, *query.RegexQuery, *query.FuzzyQuery, *query.BoolQuery,
+		*query.NearQuery, *query.MultiMatchQuery, query.SpanQuery:
+		// Execute query, convert hits to docSet
+		hits, err := s.execute(q)
 		if err != nil {
 			return nil, err
 		}
-		return s.resultsToDocSet(results), nil
+		return s.hitsToDocSet(hits), nil
 	default:
 		return nil, fmt.Errorf("unknown query type: %T", q)
 	}
 }
 
-// resultsToDocSet converts a slice of Results to a docSet.
-func (s *Searcher) resultsToDocSet(results []Result) *docSet {
+// hitsToDocSet converts a slice of hits to a docSet.
+func (s *Searcher) hitsToDocSet(hits []hit) *docSet {
 	ds := newDocSet(s.snapshot)
-	if len(results) == 0 {
-		return ds
-	}
-
-	// Build docID -> docNum mapping for each segment and builder
-	for _, r := range results {
-		// Check each segment for this docID
-		for i, segSnap := range s.snapshot.Segments() {
-			seg := segSnap.Segment()
-			if docNum, ok := seg.DocNum(r.DocID); ok {
-				if segSnap.Deleted() == nil || !segSnap.Deleted().Contains(uint32(docNum)) {
-					ds.segmentDocs[i].docs.Add(uint32(docNum))
-					break // Found in this segment, no need to check others
-				}
-			}
-		}
-		// Check builder
-		if builder := s.snapshot.Builder(); builder != nil {
-			for docNum, docID := range builder.DocIDs {
-				if docID == r.DocID && !builder.IsDeleted(uint64(docNum)) {
-					ds.builderDocs.Add(uint32(docNum))
-					break
-				}
-			}
+	for _, h := range hits {
+		if h.segmentIdx < 0 {
+			ds.builderDocs.Add(uint32(h.docNum))
+		} else {
+			ds.segmentDocs[h.segmentIdx].docs.Add(uint32(h.docNum))
 		}
 	}
-
 	return ds
 }
@@ -1,6 +1,7 @@
 package search
 
 import (
+	"slices"
 	"testing"
 
 	"harshagw/postings/internal/index"
@@ -654,3 +655,99 @@ func TestBoolQuery_ResultsSortedByScoreDescending(t *testing.T) {
 		}
 	}
 }
+
+// ============ Ranking Tests ============
+
+// createRankingIndex indexes documents whose ranking depends on term
+// frequency, field length and how many clauses they match.
+func createRankingIndex(t *testing.T) *index.Index {
+	t.Helper()
+	idx, err := index.New(index.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	t.Cleanup(func() { idx.Close() })
+
+	docs := map[string]string{
+		"both":       "search engine internals",
+		"search3":    "search search search tips",
+		"search1":    "search tips and tricks for beginners",
+		"engine":     "engine repair manual",
+		"searchable": "searchable searching searcher archive",
+		"unrelated":  "gardening for beginners",
+	}
+	for id, body := range docs {
+		if err := idx.Index(id, map[string]any{"body": body}); err != nil {
+			t.Fatalf("Index error: %v", err)
+		}
+	}
+	idx.Flush()
+	idx.Index("engines", map[string]any{"body": "engines and search"})
+	return idx
+}
+
+func rankedIDs(t *testing.T, s *Searcher, q query.Query) []string {
+	t.Helper()
+	results, err := s.RunQuery(q)
+	if err != nil {
+		t.Fatalf("RunQuery(%v) error: %v", q, err)
+	}
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.DocID
+	}
+	return ids
+}
+
+func TestBoolQuery_OrRanksDocsMatchingMoreClauses(t *testing.T) {
+	s, cleanup := createSearcher(t, createRankingIndex(t))
+	defer cleanup()
+
+	ids := rankedIDs(t, s, &query.BoolQuery{Should: []query.Query{
+		&query.TermQuery{Field: "body", Term: "search"},
+		&query.TermQuery{Field: "body", Term: "engine"},
+	}})
+	if len(ids) != 5 || ids[0] != "both" {
+		t.Errorf("expected the doc matching both clauses first, got %v", ids)
+	}
+	// Among single-clause matches, term frequency and length still count.
+	if slices.Index(ids, "search3") > slices.Index(ids, "search1") {
+		t.Errorf("expected search3 above search1, got %v", ids)
+	}
+}
+
+func TestBoolQuery_AndRanksByTermFrequency(t *testing.T) {
+	s, cleanup := createSearcher(t, createRankingIndex(t))
+	defer cleanup()
+
+	ids := rankedIDs(t, s, &query.BoolQuery{
+		Must:    []query.Query{&query.TermQuery{Field: "body", Term: "search"}},
+		MustNot: []query.Query{&query.TermQuery{Field: "body", Term: "engine"}},
+	})
+	if want := []string{"search3", "engines", "search1"}; !slices.Equal(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+}
+
+func TestBoolQuery_RangeClausesScoreConstant(t *testing.T) {
+	idx := createNumericIndex(t)
+	defer idx.Close()
+	s, cleanup := createSearcher(t, idx)
+	defer cleanup()
+
+	min := 50.0
+	results, err := s.RunQuery(&query.BoolQuery{Should: []query.Query{
+		&query.NumericRangeQuery{Field: "price", Min: &min, MinInclusive: true},
+		&query.TermQuery{Field: "name", Term: "stand"},
+	}})
+	if err != nil {
+		t.Fatalf("RunQuery error: %v", err)
+	}
+	if len(results) != 2 || results[0].DocID != "doc3" {
+		t.Fatalf("expected doc3 (range and term) first, got %+v", results)
+	}
+	// doc2 matches only the range: a constant 1, halved by coordination.
+	if results[1].DocID != "doc2" || results[1].Score != 0.5 {
+		t.Errorf("expected doc2 with score 0.5, got %+v", results[1])
+	}
+}
@@ -0,0 +1,8 @@
+package search
+
+import "harshagw/postings/internal/query"
+
+// boostSearch runs the boosted query and multiplies its scores by the boost.
+func (s *Searcher) boostSearch(q *query.BoostQuery, c collector) error {
+	return s.run(q.Query, boosted{c: c, boost: q.Boost})
+}
@@ -0,0 +1,98 @@
+package search
+
+import "container/heap"
+
+// hit is a scored document identified by where it is stored. External IDs
+// are only looked up for the hits that are returned.
+type hit struct {
+	segmentIdx int // index into the snapshot's segments, -1 for the builder
+	docNum     uint64
+	score      float64
+}
+
+// worse orders hits by ascending score. Ties go to the document stored
+// later, so that pages are stable.
+func (h hit) worse(other hit) bool {
+	if h.score != other.score {
+		return h.score < other.score
+	}
+	if h.segmentIdx != other.segmentIdx {
+		return h.segmentIdx > other.segmentIdx
+	}
+	return h.docNum > other.docNum
+}
+
+// collector receives the hits of a query as they are scored, in no
+// particular order.
+type collector interface {
+	collect(h hit)
+}
+
+// collectorFunc adapts a function to a collector.
+type collectorFunc func(h hit)
+
+func (f collectorFunc) collect(h hit) { f(h) }
+
+// hitList collects every hit.
+type hitList []hit
+
+func (l *hitList) collect(h hit) { *l = append(*l, h) }
+
+// boosted multiplies the scores of hits by boost before passing them on.
+type boosted struct {
+	c     collector
+	boost float64
+}
+
+func (b boosted) collect(h hit) {
+	h.score *= b.boost
+	b.c.collect(h)
+}
+
+// topK keeps the k best hits in a min-heap whose root is the worst kept,
+// and counts every hit offered. The heap grows as hits arrive, so a large
+// k costs nothing until there are that many hits.
+type topK struct {
+	k     int
+	hits  []hit
+	total uint64
+}
+
+func newTopK(k int) *topK {
+	return &topK{k: k}
+}
+
+func (c *topK) Len() int           { return len(c.hits) }
+func (c *topK) Less(i, j int) bool { return c.hits[i].worse(c.hits[j]) }
+func (c *topK) Swap(i, j int)      { c.hits[i], c.hits[j] = c.hits[j], c.hits[i] }
+func (c *topK) Push(x any)         { c.hits = append(c.hits, x.(hit)) }
+func (c *topK) Pop() any {
+	last := c.hits[len(c.hits)-1]
+	c.hits = c.hits[:len(c.hits)-1]
+	return last
+}
+
+// collect offers a hit, replacing the worst kept hit if it is better.
+func (c *topK) collect(h hit) {
+	c.total++
+	if c.k <= 0 {
+		return
+	}
+	if len(c.hits) < c.k {
+		heap.Push(c, h)
+		return
+	}
+	if c.hits[0].worse(h) {
+		c.hits[0] = h
+		heap.Fix(c, 0)
+	}
+}
+
+// sorted empties the collector and returns its hits, best first.
+func (c *topK) sorted() []hit {
+	out := make([]hit, len(c.hits))
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(c).(hit)
+	}
+	return out
+}
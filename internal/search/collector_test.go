@@ -0,0 +1,45 @@
+package search
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTopK_KeepsBestHitsInOrder(t *testing.T) {
+	c := newTopK(3)
+	for i, score := range []float64{1.0, 3.0, 2.0, 0.5, 4.0, 2.0} {
+		c.collect(hit{segmentIdx: 0, docNum: uint64(i), score: score})
+	}
+
+	var got []uint64
+	for _, h := range c.sorted() {
+		got = append(got, h.docNum)
+	}
+	// Equal scores keep the earlier document.
+	if want := []uint64{4, 1, 2}; !slices.Equal(got, want) {
+		t.Errorf("got docNums %v, want %v", got, want)
+	}
+	if c.total != 6 {
+		t.Errorf("total = %d, want 6", c.total)
+	}
+}
+
+func TestTopK_ZeroKeepsNothing(t *testing.T) {
+	c := newTopK(0)
+	c.collect(hit{score: 1})
+	if got := c.sorted(); len(got) != 0 {
+		t.Errorf("expected no hits, got %v", got)
+	}
+	if c.total != 1 {
+		t.Errorf("total = %d, want 1", c.total)
+	}
+}
+
+func TestBoosted_ScalesScores(t *testing.T) {
+	var hits hitList
+	c := boosted{c: &hits, boost: 2}
+	c.collect(hit{docNum: 1, score: 1.5})
+	if len(hits) != 1 || hits[0].score != 3 {
+		t.Errorf("got %v, want one hit scoring 3", hits)
+	}
+}
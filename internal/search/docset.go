@@ -57,6 +57,14 @@ func (ds *docSet) Count() uint64 {
 	return count
 }
 
+// Contains reports whether the document at key is in the set.
+func (ds *docSet) Contains(key docKey) bool {
+	if key.segmentIdx < 0 {
+		return ds.builderDocs.Contains(uint32(key.docNum))
+	}
+	return ds.segmentDocs[key.segmentIdx].docs.Contains(uint32(key.docNum))
+}
+
 // Clone creates a deep copy of the docSet.
 func (ds *docSet) Clone() *docSet {
 	result := &docSet{
@@ -105,79 +113,28 @@ func (ds *docSet) Subtract(other *docSet) *docSet {
 	return result
 }
 
-// materializeResults converts a docSet to a slice of Results.
-// It retrieves the external document IDs and calculates scores.
-func (s *Searcher) materializeResults(ds *docSet, field string) []Result {
+// collectDocSet passes the documents of a docSet to c as hits with the
+// same score.
+func (s *Searcher) collectDocSet(ds *docSet, score float64, c collector) {
 	if ds == nil || ds.IsEmpty() {
-		return nil
+		return
 	}
 
-	var matches []searchMatch
-	seen := make(map[string]bool)
-
-	// Materialize from segments (newest to oldest for proper deduplication)
-	segments := s.snapshot.Segments()
-	for i := len(segments) - 1; i >= 0; i-- {
-		segSnap := segments[i]
-		seg := segSnap.Segment()
-		docs := ds.segmentDocs[i].docs
-
-		iter := docs.Iterator()
+	for i, sd := range ds.segmentDocs {
+		iter := sd.docs.Iterator()
 		for iter.HasNext() {
-			docNum := uint64(iter.Next())
-			extID, ok := seg.ExternalID(docNum)
-			if !ok || seen[extID] {
-				continue
-			}
-			seen[extID] = true
-
-			// Get field length for scoring
-			fieldLen := uint64(0)
-			if field != "" {
-				fieldLen = seg.FieldLength(field, docNum)
-			}
-
-			matches = append(matches, searchMatch{
-				docID:       extID,
-				tf:          1.0, // Default TF for docSet results
-				fieldLength: fieldLen,
-				field:       field,
-				segmentIdx:  i,
-			})
+			c.collect(hit{segmentIdx: i, docNum: uint64(iter.Next()), score: score})
 		}
 	}
 
-	// Materialize from builder
-	if builder := s.snapshot.Builder(); builder != nil {
-		iter := ds.builderDocs.Iterator()
-		for iter.HasNext() {
-			docNum := uint64(iter.Next())
-			if builder.IsDeleted(docNum) {
-				continue
-			}
-			if docNum < uint64(len(builder.DocIDs)) {
-				extID := builder.DocIDs[docNum]
-				if !seen[extID] {
-					seen[extID] = true
-
-					fieldLen := uint64(0)
-					if field != "" {
-						fieldLen = builder.FieldLength(field, docNum)
-					}
-
-					matches = append(matches, searchMatch{
-						docID:       extID,
-						tf:          1.0,
-						fieldLength: fieldLen,
-						field:       field,
-						segmentIdx:  -1,
-					})
-				}
-			}
+	builder := s.snapshot.Builder()
+	iter := ds.builderDocs.Iterator()
+	for iter.HasNext() {
+		docNum := uint64(iter.Next())
+		if builder != nil && !builder.IsDeleted(docNum) {
+			c.collect(hit{segmentIdx: -1, docNum: docNum, score: score})
 		}
 	}
-
-	return s.scoreAndSort(matches, field)
 }
 
 // unionAll performs fast union of multiple docSets.
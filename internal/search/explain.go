@@ -0,0 +1,532 @@
+package search
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"harshagw/postings/internal/query"
+	"harshagw/postings/internal/segment"
+	"harshagw/postings/internal/similarity"
+)
+
+// Explanation is a node in the tree of how a document's score was computed.
+// Value is the node's contribution, computed from its Details as stated in
+// Description. A document that does not match has Match false and the reason
+// in Description.
+type Explanation struct {
+	Match       bool
+	Value       float64
+	Description string
+	Details     []*Explanation
+}
+
+// String renders the tree, one node per line, children indented.
+func (e *Explanation) String() string {
+	var b strings.Builder
+	e.write(&b, 0)
+	return b.String()
+}
+
+func (e *Explanation) write(b *strings.Builder, depth int) {
+	fmt.Fprintf(b, "%s%s = %s\n", strings.Repeat("  ", depth), strconv.FormatFloat(e.Value, 'g', 6, 64), e.Description)
+	for _, d := range e.Details {
+		d.write(b, depth+1)
+	}
+}
+
+// noMatch explains why a document did not match.
+func noMatch(reason string, details ...*Explanation) *Explanation {
+	return &Explanation{Description: "no match: " + reason, Details: details}
+}
+
+// Explain returns how q scores the live document docID. Its Value is the
+// score Search gives the document; if the document does not match, the
+// explanation says which clause failed.
+func (s *Searcher) Explain(q query.Query, docID string) (*Explanation, error) {
+	key, ok := s.lookupDoc(docID)
+	if !ok {
+		return nil, fmt.Errorf("document not found: %s", docID)
+	}
+	e := &explainer{s: s, key: key, stats: newStatsCache(s.snapshot)}
+	return e.explain(q)
+}
+
+// lookupDoc finds where the live copy of a document is stored, newest first.
+func (s *Searcher) lookupDoc(docID string) (docKey, bool) {
+	if builder := s.snapshot.Builder(); builder != nil {
+		for i := len(builder.DocIDs) - 1; i >= 0; i-- {
+			if builder.DocIDs[i] == docID && !builder.IsDeleted(uint64(i)) {
+				return docKey{-1, uint64(i)}, true
+			}
+		}
+	}
+
+	segments := s.snapshot.Segments()
+	for i := len(segments) - 1; i >= 0; i-- {
+		segSnap := segments[i]
+		docNum, ok := segSnap.Segment().DocNum(docID)
+		if !ok || (segSnap.Deleted() != nil && segSnap.Deleted().Contains(uint32(docNum))) {
+			continue
+		}
+		return docKey{i, docNum}, true
+	}
+	return docKey{}, false
+}
+
+// explainer explains the score of one document. Each method mirrors the
+// search path of its query type, so the values add up to the same score.
+type explainer struct {
+	s     *Searcher
+	key   docKey
+	stats *statsCache
+}
+
+func (e *explainer) explain(q query.Query) (*Explanation, error) {
+	switch v := q.(type) {
+	case nil:
+		return noMatch("empty query"), nil
+	case *query.TermQuery:
+		return e.explainAnalyzed(q, v.Term, 0, e.s.termQueryFields(v.Field)), nil
+	case *query.PhraseQuery:
+		return e.explainAnalyzed(q, v.Phrase, v.Slop, e.s.getFieldsToSearch(v.Field)), nil
+	case *query.NearQuery:
+		return e.explainNear(v)
+	case *query.PrefixQuery:
+		return e.explainMultiTerm(q, e.s.prefixTerms(v.Prefix, v.Field), v.Field), nil
+	case *query.RegexQuery:
+		terms, err := e.s.regexTerms(v.Pattern, v.Field)
+		if err != nil {
+			return nil, err
+		}
+		return e.explainMultiTerm(q, terms, v.Field), nil
+	case *query.FuzzyQuery:
+		return e.explainMultiTerm(q, e.s.fuzzyTerms(v.Term, v.Fuzziness, v.Field), v.Field), nil
+	case *query.NumericRangeQuery:
+		return e.explainRange(q, e.s.numericRangeDocSet(v)), nil
+	case *query.DateRangeQuery:
+		ds, err := e.s.dateRangeDocSet(v)
+		if err != nil {
+			return nil, err
+		}
+		return e.explainRange(q, ds), nil
+	case *query.BoolQuery:
+		return e.explainBool(v)
+	case *query.MultiMatchQuery:
+		return e.explainMultiMatch(v), nil
+	case query.SpanQuery:
+		return e.explainSpan(v)
+	case *query.BoostQuery:
+		d, err := e.explain(v.Query)
+		if err != nil || !d.Match {
+			return d, err
+		}
+		return &Explanation{
+			Match:       true,
+			Value:       d.Value * v.Boost,
+			Description: fmt.Sprintf("boost %g times:", v.Boost),
+			Details:     []*Explanation{d},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown query type: %T", q)
+	}
+}
+
+// explainAnalyzed mirrors analyzedSearch: the document's matches in each
+// field, with each alternative of the text, are scored as scoreMatches
+// scores them.
+func (e *explainer) explainAnalyzed(q query.Query, text string, slop int, fields []string) *Explanation {
+	var matches []searchMatch
+	var misses []*Explanation
+	for _, f := range fields {
+		for _, tokens := range e.s.analyzeForField(text, f) {
+			ph := newPhrase(tokens, slop)
+			var m searchMatch
+			var ok bool
+			if len(ph.terms) == 1 {
+				m, ok = e.matchTerm(ph.terms[0], f)
+			} else {
+				m, ok = e.matchPhrase(ph, f)
+			}
+			if ok {
+				matches = append(matches, m)
+			} else {
+				misses = append(misses, noMatch(fmt.Sprintf("%s not in field %s", termsString(ph.terms), f)))
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return noMatch(fmt.Sprintf("%s matched no field", q), misses...)
+	}
+	return e.explainMatches(matches)
+}
+
+// explainNear mirrors nearSearch.
+func (e *explainer) explainNear(q *query.NearQuery) (*Explanation, error) {
+	var matches []searchMatch
+	var misses []*Explanation
+	for _, f := range e.s.getFieldsToSearch(q.Field) {
+		phrases, err := e.s.nearPhrases(q, f)
+		if err != nil {
+			return nil, err
+		}
+		for _, ph := range phrases {
+			if m, ok := e.matchPhrase(ph, f); ok {
+				matches = append(matches, m)
+			} else {
+				misses = append(misses, noMatch(fmt.Sprintf("%s not within %d positions in field %s", termsString(ph.terms), q.Distance, f)))
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return noMatch(fmt.Sprintf("%s matched no field", q), misses...), nil
+	}
+	return e.explainMatches(matches), nil
+}
+
+// explainSpan mirrors spanSearch.
+func (e *explainer) explainSpan(q query.SpanQuery) (*Explanation, error) {
+	fields, err := e.s.spanFields(q)
+	if err != nil {
+		return nil, err
+	}
+	var matches []searchMatch
+	var misses []*Explanation
+	for _, f := range fields {
+		sq, err := e.s.compileSpan(q, f)
+		if err != nil {
+			return nil, err
+		}
+		if freq, terms := spanFreq(sq, e.s.newSpanSource(e.key.segmentIdx, f), e.key.docNum); freq > 0 {
+			matches = append(matches, e.match(freq, f, terms))
+		} else {
+			misses = append(misses, noMatch(fmt.Sprintf("no span in field %s", f)))
+		}
+	}
+	if len(matches) == 0 {
+		return noMatch(fmt.Sprintf("%s matched no field", q), misses...), nil
+	}
+	return e.explainMatches(matches), nil
+}
+
+// explainMultiTerm mirrors multiTermSearch: the scores of the expanded
+// terms the document contains are added up.
+func (e *explainer) explainMultiTerm(q query.Query, terms []string, field string) *Explanation {
+	fields := e.s.getFieldsToSearch(field)
+	var sum float64
+	var details []*Explanation
+	for _, term := range terms {
+		var matches []searchMatch
+		for _, f := range fields {
+			if m, ok := e.matchTerm(term, f); ok {
+				matches = append(matches, m)
+			}
+		}
+		if len(matches) > 0 {
+			d := e.explainMatches(matches)
+			sum += d.Value
+			details = append(details, d)
+		}
+	}
+	if len(details) == 0 {
+		return noMatch(fmt.Sprintf("%s expanded to %d terms, none in the document", q, len(terms)))
+	}
+	return &Explanation{
+		Match:       true,
+		Value:       sum,
+		Description: fmt.Sprintf("%s, sum of %d of %d expanded terms:", q, len(details), len(terms)),
+		Details:     details,
+	}
+}
+
+func (e *explainer) explainRange(q query.Query, ds *docSet) *Explanation {
+	if !ds.Contains(e.key) {
+		return noMatch(fmt.Sprintf("%s has no value in range", q))
+	}
+	return &Explanation{Match: true, Value: rangeScore, Description: fmt.Sprintf("%s, constant score", q)}
+}
+
+// explainBool mirrors boolSearch.
+func (e *explainer) explainBool(q *query.BoolQuery) (*Explanation, error) {
+	must, mustNot, should := flattenBoolQuery(q)
+	if len(must) == 0 && len(should) == 0 {
+		if len(mustNot) > 0 {
+			return nil, fmt.Errorf("NOT queries require a positive clause")
+		}
+		return noMatch("empty boolean query"), nil
+	}
+	if len(must)+len(should) == 1 && len(mustNot) == 0 {
+		return e.explain(slices.Concat(must, should)[0])
+	}
+
+	clauses := make([]*Explanation, 0, len(must)+len(should))
+	for _, c := range must {
+		d, err := e.explain(c)
+		if err != nil {
+			return nil, err
+		}
+		if !d.Match {
+			return noMatch(fmt.Sprintf("required clause %s did not match", c), d), nil
+		}
+		clauses = append(clauses, d)
+	}
+
+	shouldMatched := false
+	for _, c := range should {
+		d, err := e.explain(c)
+		if err != nil {
+			return nil, err
+		}
+		shouldMatched = shouldMatched || d.Match
+		clauses = append(clauses, d)
+	}
+	if len(should) > 0 && !shouldMatched {
+		if len(must) == 0 {
+			return noMatch("no optional clause matched", clauses...), nil
+		}
+		// Optional clauses only narrow the match when some document has them.
+		shouldClauses, err := e.s.executeClauses(should)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range shouldClauses {
+			if !c.docs.IsEmpty() {
+				return noMatch("no optional clause matched, though other documents match one", clauses[len(must):]...), nil
+			}
+		}
+	}
+
+	for _, c := range mustNot {
+		ds, err := e.s.executeQueryToDocSet(c)
+		if err != nil {
+			return nil, err
+		}
+		if ds.Contains(e.key) {
+			return noMatch(fmt.Sprintf("excluded by %s", c)), nil
+		}
+	}
+
+	var sum float64
+	var matched int
+	for _, d := range clauses {
+		if d.Match {
+			sum += d.Value
+			matched++
+		}
+	}
+	return &Explanation{
+		Match:       true,
+		Value:       coord(sum, matched, len(clauses)),
+		Description: fmt.Sprintf("sum of the matching clauses, times coord %d/%d:", matched, len(clauses)),
+		Details:     clauses,
+	}, nil
+}
+
+// matchTerm returns the document's match of term in field.
+func (e *explainer) matchTerm(term, field string) (searchMatch, bool) {
+	p, ok := e.posting(term, field)
+	if !ok {
+		return searchMatch{}, false
+	}
+	return e.match(float64(p.Frequency), field, []string{term}), true
+}
+
+// matchPhrase returns the document's match of a phrase in field. Like a
+// phrase search, it counts once however often it occurs, with the
+// frequency of its tightest match.
+func (e *explainer) matchPhrase(ph phrase, field string) (searchMatch, bool) {
+	positions := make([][]uint64, len(ph.terms))
+	for i, term := range ph.terms {
+		p, ok := e.posting(term, field)
+		if !ok {
+			return searchMatch{}, false
+		}
+		positions[i] = p.Positions
+	}
+	freq := ph.freq(positions)
+	if freq == 0 {
+		return searchMatch{}, false
+	}
+	return e.match(freq, field, ph.terms), true
+}
+
+func (e *explainer) match(tf float64, field string, terms []string) searchMatch {
+	m := searchMatch{segmentIdx: e.key.segmentIdx, docNum: e.key.docNum, tf: tf, field: field, terms: terms}
+	if e.key.segmentIdx < 0 {
+		m.fieldLength = e.s.snapshot.Builder().FieldLength(field, e.key.docNum)
+	} else {
+		m.fieldLength = e.s.snapshot.Segments()[e.key.segmentIdx].Segment().FieldLength(field, e.key.docNum)
+	}
+	return m
+}
+
+// posting returns the document's posting of term in field.
+func (e *explainer) posting(term, field string) (segment.Posting, bool) {
+	var postings []segment.Posting
+	if e.key.segmentIdx < 0 {
+		postings = e.s.snapshot.Builder().Fields[field][term]
+	} else {
+		var err error
+		postings, err = e.s.snapshot.Segments()[e.key.segmentIdx].Search(term, field)
+		if err != nil {
+			return segment.Posting{}, false
+		}
+	}
+	i, ok := slices.BinarySearchFunc(postings, e.key.docNum, func(p segment.Posting, docNum uint64) int {
+		return cmp.Compare(p.DocNum, docNum)
+	})
+	if !ok {
+		return segment.Posting{}, false
+	}
+	return postings[i], true
+}
+
+// explainMatches explains the score of the document's matches of one term
+// or phrase with the similarity of the first match's field.
+func (e *explainer) explainMatches(matches []searchMatch) *Explanation {
+	c := e.stats
+	matches = c.scored(matches)
+	sim := c.similarity(matches[0].field)
+	multi, isMulti := sim.(similarity.MultiFieldSimilarity)
+
+	terms := matches[0].terms
+	breakdowns := make([]*similarity.Explanation, len(terms))
+	for i := range terms {
+		if isMulti {
+			breakdowns[i] = multi.ExplainFields(c.fieldsStats(matches, i))
+		} else {
+			breakdowns[i] = sim.Explain(c.stats(matches[0], i))
+		}
+	}
+
+	fields := make([]string, len(matches))
+	for i, m := range matches {
+		fields[i] = m.field
+	}
+	weight := e.weight(fields, terms, sim)
+
+	if len(terms) == 1 {
+		expl := fromSimilarity(breakdowns[0])
+		expl.Description = weight + ", " + expl.Description
+		return expl
+	}
+	expl := &Explanation{Match: true, Value: c.score(matches), Description: weight + ", sum of the phrase terms:"}
+	for i, b := range breakdowns {
+		d := fromSimilarity(b)
+		d.Description = fmt.Sprintf("term %s, %s", terms[i], d.Description)
+		expl.Details = append(expl.Details, d)
+	}
+	return expl
+}
+
+// weight describes the score of terms in fields of the document.
+func (e *explainer) weight(fields, terms []string, sim similarity.Similarity) string {
+	var where string
+	if e.key.segmentIdx < 0 {
+		where = fmt.Sprintf("doc %d in the in-memory builder", e.key.docNum)
+	} else {
+		where = fmt.Sprintf("doc %d in segment %s", e.key.docNum, e.s.snapshot.Segments()[e.key.segmentIdx].ID())
+	}
+	return fmt.Sprintf("weight(%s:%s in %s) [%s]", strings.Join(fields, "+"), termsString(terms), where, sim)
+}
+
+// explainMultiMatch mirrors multiMatchSearch.
+func (e *explainer) explainMultiMatch(q *query.MultiMatchQuery) *Explanation {
+	mm := e.s.newMultiMatcher(q, e.stats)
+	d := mm.newDoc()
+	best := make([][]*Explanation, len(mm.fields)) // field -> position -> best term
+	for i, f := range mm.fields {
+		best[i] = make([]*Explanation, len(mm.positions))
+		for p, terms := range f.terms {
+			for _, term := range terms {
+				m, ok := e.matchTerm(term, f.field)
+				if !ok {
+					continue
+				}
+				score := mm.termScore(m, i, p)
+				if best[i][p] == nil || score > d.scores[i][p] {
+					best[i][p] = e.explainMultiMatchTerm(mm, m, i, p, score)
+				}
+				d.add(i, p, score)
+			}
+		}
+	}
+	matched := d.matchedCount()
+	if matched == 0 {
+		return noMatch(fmt.Sprintf("%s matched no term in any field", q))
+	}
+
+	var how string
+	var details []*Explanation
+	switch q.Type {
+	case query.CrossFields:
+		how = fmt.Sprintf("sum over the terms of the best field plus %g times the others", q.TieBreaker)
+		scores := mm.positionScores(d)
+		for p := range mm.positions {
+			if !d.matched[p] {
+				continue
+			}
+			expl := &Explanation{Match: true, Value: scores[p], Description: fmt.Sprintf("term at position %d:", mm.positions[p])}
+			for i := range mm.fields {
+				if best[i][p] != nil {
+					expl.Details = append(expl.Details, best[i][p])
+				}
+			}
+			details = append(details, expl)
+		}
+	default:
+		how = "sum of the fields"
+		if q.Type != query.MostFields {
+			how = fmt.Sprintf("best field plus %g times the others", q.TieBreaker)
+		}
+		scores := mm.fieldScores(d)
+		for i, f := range mm.fields {
+			expl := &Explanation{Match: true, Value: scores[i], Description: fmt.Sprintf("field %s, sum of its terms:", f.field)}
+			for _, t := range best[i] {
+				if t != nil {
+					expl.Details = append(expl.Details, t)
+				}
+			}
+			if len(expl.Details) > 0 {
+				details = append(details, expl)
+			}
+		}
+	}
+	return &Explanation{
+		Match:       true,
+		Value:       mm.score(d),
+		Description: fmt.Sprintf("%s, %s, times coord %d/%d:", q, how, matched, len(mm.positions)),
+		Details:     details,
+	}
+}
+
+// explainMultiMatchTerm explains the score of a multi-match term in the
+// i-th field at position p.
+func (e *explainer) explainMultiMatchTerm(mm *multiMatcher, m searchMatch, i, p int, score float64) *Explanation {
+	sim := e.stats.similarity(m.field)
+	expl := fromSimilarity(sim.Explain(mm.termStats(m, p)))
+	expl.Description = e.weight([]string{m.field}, m.terms, sim) + ", " + expl.Description
+	if boost := mm.fields[i].boost; boost != 1 {
+		return &Explanation{Match: true, Value: score, Description: fmt.Sprintf("boost %g times:", boost), Details: []*Explanation{expl}}
+	}
+	return expl
+}
+
+// fromSimilarity converts a similarity's breakdown of a matching score.
+func fromSimilarity(se *similarity.Explanation) *Explanation {
+	e := &Explanation{Match: true, Value: se.Value, Description: se.Description}
+	for _, d := range se.Details {
+		e.Details = append(e.Details, fromSimilarity(d))
+	}
+	return e
+}
+
+// termsString renders a term, or the terms of a phrase in quotes.
+func termsString(terms []string) string {
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return strconv.Quote(strings.Join(terms, " "))
+}
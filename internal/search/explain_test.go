@@ -0,0 +1,177 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"harshagw/postings/internal/index"
+	"harshagw/postings/internal/query"
+	"harshagw/postings/internal/similarity"
+)
+
+// createExplainIndex spreads documents over a segment and the builder, with
+// one document updated so that its old copy is deleted. A nil similarity
+// keeps the default.
+func createExplainIndex(t *testing.T, sim similarity.Similarity) *index.Index {
+	t.Helper()
+	config := index.DefaultConfig(t.TempDir())
+	config.Similarity = sim
+	idx, err := index.New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	t.Cleanup(func() { idx.Close() })
+
+	idx.Index("doc1", map[string]any{"title": "Hello World", "body": "hello hello search engine", "price": 10})
+	idx.Index("doc2", map[string]any{"title": "Go Programming", "body": "learning go programming", "price": 25})
+	idx.Index("doc3", map[string]any{"title": "Hello Go", "body": "hello from go world", "price": 40})
+	idx.Flush()
+	idx.Index("doc4", map[string]any{"title": "Search Engines", "body": "inverted index and postings", "price": 55})
+	idx.Index("doc2", map[string]any{"title": "Go Programming", "body": "learning go programming again", "price": 30})
+	return idx
+}
+
+func parseQuery(t *testing.T, queryString string) query.Query {
+	t.Helper()
+	tokens, err := query.Tokenize(queryString)
+	if err != nil {
+		t.Fatalf("Tokenize(%q) error: %v", queryString, err)
+	}
+	q, err := query.Parse(tokens)
+	if err != nil {
+		t.Fatalf("Parse(%q) error: %v", queryString, err)
+	}
+	return q
+}
+
+// The explanation of every document must agree with the search: the same
+// score for hits and no match for the rest.
+func TestExplain_MatchesSearchScores(t *testing.T) {
+	queryStrings := []string{
+		"hello",
+		"title:hello",
+		`"hello world"`,
+		"go OR search",
+		"hello AND go",
+		"hello AND NOT title:go",
+		"title:go OR (hello AND world)",
+		"eng*",
+		"programing~1",
+		"/hel+o/",
+		"price:[20 TO 50] OR hello",
+		"doc4",
+		"title:hello^3 OR body:go^0.5",
+		`("hello world" OR programming)^2`,
+		`"hello engine"~3`,
+		`body:"go learning"~2 OR world`,
+		"hello NEAR/2 search",
+	}
+	queries := []query.Query{
+		&query.SpanNearQuery{
+			Clauses: []query.SpanQuery{
+				&query.SpanTermQuery{Term: "hello"},
+				&query.SpanMultiQuery{Query: &query.PrefixQuery{Prefix: "eng"}},
+			},
+			Slop: 2,
+		},
+		&query.SpanNotQuery{
+			Include: &query.SpanOrQuery{Clauses: []query.SpanQuery{
+				&query.SpanTermQuery{Field: "body", Term: "go"},
+				&query.SpanTermQuery{Field: "body", Term: "hello"},
+			}},
+			Exclude: &query.SpanTermQuery{Field: "body", Term: "learning"},
+			Pre:     1,
+		},
+		&query.SpanFirstQuery{Match: &query.SpanTermQuery{Term: "hello"}, End: 1},
+	}
+	for _, qs := range queryStrings {
+		queries = append(queries, parseQuery(t, qs))
+	}
+	sims := []similarity.Similarity{
+		similarity.NewBM25(),
+		similarity.TFIDF{},
+		similarity.NewBM25F(map[string]float64{"title": 2}),
+		similarity.NewDFR(),
+		similarity.NewLMDirichlet(),
+		&similarity.Constant{Value: 1},
+	}
+	for _, sim := range sims {
+		idx := createExplainIndex(t, sim)
+		s, cleanup := createSearcher(t, idx)
+		defer cleanup()
+
+		for _, q := range queries {
+			results, err := s.RunQuery(q)
+			if err != nil {
+				t.Fatalf("RunQuery(%s) error: %v", q, err)
+			}
+			scores := make(map[string]float64)
+			for _, r := range results {
+				scores[r.DocID] = r.Score
+			}
+
+			for _, id := range []string{"doc1", "doc2", "doc3", "doc4"} {
+				expl, err := s.Explain(q, id)
+				if err != nil {
+					t.Fatalf("Explain(%s, %s) error: %v", q, id, err)
+				}
+				score, hit := scores[id]
+				if expl.Match != hit || expl.Value != score {
+					t.Errorf("%s, %s, %s: explained match=%v value=%v, search hit=%v score=%v\n%s",
+						sim, q, id, expl.Match, expl.Value, hit, score, expl)
+				}
+			}
+		}
+	}
+}
+
+func TestExplain_TermDetails(t *testing.T) {
+	idx := createExplainIndex(t, nil)
+	s, cleanup := createSearcher(t, idx)
+	defer cleanup()
+
+	expl, err := s.Explain(&query.TermQuery{Field: "body", Term: "hello"}, "doc1")
+	if err != nil {
+		t.Fatalf("Explain error: %v", err)
+	}
+	if len(expl.Details) != 2 {
+		t.Fatalf("expected idf and tf details:\n%s", expl)
+	}
+	idf, tf := expl.Details[0], expl.Details[1]
+	if df, n := idf.Details[0].Value, idf.Details[1].Value; df != 2 || n != 4 {
+		t.Errorf("df = %v, N = %v, want 2 and 4", df, n)
+	}
+	if freq, dl := tf.Details[0].Value, tf.Details[3].Value; freq != 2 || dl != 4 {
+		t.Errorf("freq = %v, dl = %v, want 2 and 4", freq, dl)
+	}
+	if !strings.Contains(expl.Description, "segment") {
+		t.Errorf("description should name the segment: %s", expl.Description)
+	}
+}
+
+func TestExplain_NonMatchReasons(t *testing.T) {
+	idx := createExplainIndex(t, nil)
+	s, cleanup := createSearcher(t, idx)
+	defer cleanup()
+
+	cases := []struct {
+		query, docID, reason string
+	}{
+		{"hello AND NOT title:go", "doc3", "excluded by term(title:go)"},
+		{"hello AND postings", "doc1", "required clause term(postings) did not match"},
+		{"title:world", "doc2", "term(title:world) matched no field"},
+	}
+	for _, c := range cases {
+		expl, err := s.Explain(parseQuery(t, c.query), c.docID)
+		if err != nil {
+			t.Fatalf("Explain(%q) error: %v", c.query, err)
+		}
+		if expl.Match || !strings.Contains(expl.Description, c.reason) {
+			t.Errorf("%q on %s: got %q, want a non-match with %q", c.query, c.docID, expl.Description, c.reason)
+		}
+	}
+
+	if _, err := s.Explain(&query.TermQuery{Term: "hello"}, "missing"); err == nil {
+		t.Error("expected an error for an unknown document")
+	}
+}
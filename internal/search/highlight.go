@@ -0,0 +1,599 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"harshagw/postings/internal/analysis"
+	"harshagw/postings/internal/index"
+	"harshagw/postings/internal/mapping"
+	"harshagw/postings/internal/query"
+	"harshagw/postings/internal/segment"
+)
+
+// HighlightOptions configures the fragments returned by Highlight. Zero
+// fields take their defaults.
+type HighlightOptions struct {
+	PreTag       string // inserted before each match; default "<em>"
+	PostTag      string // inserted after each match; default "</em>"
+	FragmentSize int    // approximate fragment length in bytes; default 100
+	NumFragments int    // best fragments kept per field; default 3
+}
+
+func (o HighlightOptions) withDefaults() HighlightOptions {
+	if o.PreTag == "" && o.PostTag == "" {
+		o.PreTag, o.PostTag = "<em>", "</em>"
+	}
+	if o.FragmentSize <= 0 {
+		o.FragmentSize = 100
+	}
+	if o.NumFragments <= 0 {
+		o.NumFragments = 3
+	}
+	return o
+}
+
+// Highlight loads the stored document of each result into Doc, sets
+// MatchedTerms to the indexed terms the query matched, and fills Fragments
+// with the best-scoring snippets of each matching text field. Fragments are
+// ranked by the number of distinct terms they contain, then by matches.
+// Must-not clauses are not highlighted.
+//
+// Matches are located with the offsets stored in the postings of fields
+// mapped with offsets; other fields are analyzed again.
+func (s *Searcher) Highlight(q query.Query, results []Result, opts HighlightOptions) error {
+	opts = opts.withDefaults()
+	clauses, err := s.highlightClauses(q)
+	if err != nil {
+		return err
+	}
+
+	for i := range results {
+		sd, ok, err := s.loadStoredDoc(results[i].DocID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		results[i].Doc = sd.doc
+		results[i].MatchedTerms, results[i].Fragments = s.highlightDoc(sd, clauses, opts)
+	}
+	return nil
+}
+
+// highlightClause is a query leaf whose matches are highlighted. Text
+// clauses (terms and phrases) are analyzed per field like the query; the
+// others match single indexed terms.
+type highlightClause struct {
+	field string
+	text  string
+	loose bool              // highlight the text's terms on their own, not as a phrase
+	match termMatcher       // nil for text clauses
+	find  segmentTermFinder // the terms of a segment field that match
+}
+
+// highlightClauses returns the leaves of q that can match text.
+func (s *Searcher) highlightClauses(q query.Query) ([]highlightClause, error) {
+	switch v := q.(type) {
+	case *query.TermQuery:
+		return []highlightClause{{field: v.Field, text: v.Term}}, nil
+	case *query.PhraseQuery:
+		return []highlightClause{{field: v.Field, text: v.Phrase, loose: v.Slop > 0}}, nil
+	case *query.NearQuery:
+		return []highlightClause{{field: v.Field, text: strings.Join(v.Terms, " "), loose: true}}, nil
+	case *query.PrefixQuery:
+		return []highlightClause{{
+			field: v.Field,
+			match: func(f, term string) bool {
+				return strings.HasPrefix(term, s.normalizeForField(v.Prefix, f))
+			},
+			find: func(seg *segment.Segment, f string) ([]string, error) {
+				return seg.MatchingTerms(regexp.QuoteMeta(s.normalizeForField(v.Prefix, f))+".*", f)
+			},
+		}}, nil
+	case *query.RegexQuery:
+		re, err := regexp.Compile("^(?:" + v.Pattern + ")$")
+		if err != nil {
+			return nil, err
+		}
+		return []highlightClause{{
+			field: v.Field,
+			match: func(_, term string) bool { return re.MatchString(term) },
+			find: func(seg *segment.Segment, f string) ([]string, error) {
+				return seg.MatchingTerms(v.Pattern, f)
+			},
+		}}, nil
+	case *query.FuzzyQuery:
+		return []highlightClause{{
+			field: v.Field,
+			match: func(f, term string) bool {
+				return levenshteinDistance(s.normalizeForField(v.Term, f), term) <= int(v.Fuzziness)
+			},
+			find: func(seg *segment.Segment, f string) ([]string, error) {
+				return seg.FuzzyTerms(s.normalizeForField(v.Term, f), v.Fuzziness, f)
+			},
+		}}, nil
+	case *query.BoolQuery:
+		var clauses []highlightClause
+		for _, sub := range slices.Concat(v.Must, v.Should) {
+			c, err := s.highlightClauses(sub)
+			if err != nil {
+				return nil, err
+			}
+			clauses = append(clauses, c...)
+		}
+		return clauses, nil
+	case *query.BoostQuery:
+		return s.highlightClauses(v.Query)
+	case *query.SpanTermQuery:
+		return []highlightClause{{
+			field: v.Field,
+			match: func(f, term string) bool { return term == s.normalizeForField(v.Term, f) },
+			find: func(_ *segment.Segment, f string) ([]string, error) {
+				return []string{s.normalizeForField(v.Term, f)}, nil
+			},
+		}}, nil
+	case *query.SpanMultiQuery:
+		return s.highlightClauses(v.Query)
+	case *query.SpanNearQuery:
+		return s.spanHighlightClauses(v.Clauses)
+	case *query.SpanOrQuery:
+		return s.spanHighlightClauses(v.Clauses)
+	case *query.SpanNotQuery:
+		return s.highlightClauses(v.Include)
+	case *query.SpanFirstQuery:
+		return s.highlightClauses(v.Match)
+	case *query.MultiMatchQuery:
+		// Each term is highlighted on its own in the fields it was analyzed for.
+		var clauses []highlightClause
+		fields, _ := s.multiMatchFields(v)
+		for _, f := range fields {
+			for _, terms := range f.terms {
+				for _, term := range terms {
+					clauses = append(clauses, highlightClause{
+						field: f.field,
+						match: func(_, t string) bool { return t == term },
+						find: func(*segment.Segment, string) ([]string, error) {
+							return []string{term}, nil
+						},
+					})
+				}
+			}
+		}
+		return clauses, nil
+	}
+	return nil, nil
+}
+
+// spanHighlightClauses highlights each term of span clauses on its own,
+// wherever it occurs.
+func (s *Searcher) spanHighlightClauses(spans []query.SpanQuery) ([]highlightClause, error) {
+	var clauses []highlightClause
+	for _, sub := range spans {
+		c, err := s.highlightClauses(sub)
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, c...)
+	}
+	return clauses, nil
+}
+
+// storedDoc is a live document and where it is stored.
+type storedDoc struct {
+	doc     map[string]any
+	segSnap *index.SegmentSnapshot // nil for the builder
+	docNum  uint64
+}
+
+// loadStoredDoc loads the live copy of a document.
+func (s *Searcher) loadStoredDoc(docID string) (storedDoc, bool, error) {
+	key, ok := s.lookupDoc(docID)
+	if !ok {
+		return storedDoc{}, false, nil
+	}
+	if key.segmentIdx < 0 {
+		return storedDoc{doc: s.snapshot.Builder().Docs[key.docNum], docNum: key.docNum}, true, nil
+	}
+
+	segSnap := s.snapshot.Segments()[key.segmentIdx]
+	doc, err := segSnap.Segment().LoadDoc(key.docNum)
+	if err != nil {
+		return storedDoc{}, false, fmt.Errorf("failed to load document %s: %w", docID, err)
+	}
+	return storedDoc{doc: doc, segSnap: segSnap, docNum: key.docNum}, true, nil
+}
+
+// highlightDoc returns the terms the clauses matched in a document and the
+// best fragments of each matching field.
+func (s *Searcher) highlightDoc(sd storedDoc, clauses []highlightClause, opts HighlightOptions) ([]string, map[string][]string) {
+	values := storedValues(sd.doc)
+
+	fieldSet := make(map[string]bool)
+	for _, c := range clauses {
+		if c.field != "" {
+			fieldSet[c.field] = true
+			continue
+		}
+		for f := range values {
+			fieldSet[f] = true
+		}
+	}
+
+	matched := make(map[string]bool)
+	var fragments map[string][]string
+	for f := range fieldSet {
+		if f == segment.IDField || len(values[f]) == 0 || s.snapshot.Mapping().TypeFor(f) != mapping.TypeText {
+			continue
+		}
+		var fieldClauses []highlightClause
+		for _, c := range clauses {
+			if c.field == "" || c.field == f {
+				fieldClauses = append(fieldClauses, c)
+			}
+		}
+
+		spans := s.matchSpans(f, s.fieldTokens(sd, f, values[f], fieldClauses), fieldClauses)
+		if len(spans) == 0 {
+			continue
+		}
+		for _, sp := range spans {
+			for _, term := range sp.terms {
+				matched[term] = true
+			}
+		}
+
+		var frags []fragment
+		base := 0
+		for i, v := range values[f] {
+			var local []highlightSpan
+			for _, sp := range spans {
+				if sp.start >= base && sp.end <= base+len(v) {
+					local = append(local, highlightSpan{start: sp.start - base, end: sp.end - base, terms: sp.terms})
+				}
+			}
+			for _, frag := range buildFragments(v, local, opts) {
+				frag.value = i
+				frags = append(frags, frag)
+			}
+			base += len(v) + 1
+		}
+		if len(frags) == 0 {
+			continue
+		}
+
+		sort.SliceStable(frags, func(i, j int) bool {
+			a, b := frags[i], frags[j]
+			if a.terms != b.terms {
+				return a.terms > b.terms
+			}
+			if a.hits != b.hits {
+				return a.hits > b.hits
+			}
+			if a.value != b.value {
+				return a.value < b.value
+			}
+			return a.start < b.start
+		})
+		if fragments == nil {
+			fragments = make(map[string][]string)
+		}
+		for _, frag := range frags[:min(len(frags), opts.NumFragments)] {
+			fragments[f] = append(fragments[f], frag.text)
+		}
+	}
+
+	terms := make([]string, 0, len(matched))
+	for term := range matched {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	return terms, fragments
+}
+
+// storedValues returns the string values of each field of a stored
+// document, keyed by dotted path and in the order they were indexed.
+func storedValues(doc map[string]any) map[string][]string {
+	values := make(map[string][]string)
+	for key, value := range doc {
+		collectValues(values, key, value)
+	}
+	return values
+}
+
+func collectValues(values map[string][]string, field string, value any) {
+	switch v := value.(type) {
+	case string:
+		values[field] = append(values[field], v)
+	case map[string]any:
+		for key, child := range v {
+			collectValues(values, field+"."+key, child)
+		}
+	case []any:
+		for _, elem := range v {
+			collectValues(values, field, elem)
+		}
+	case []string:
+		values[field] = append(values[field], v...)
+	case []map[string]any:
+		for _, elem := range v {
+			collectValues(values, field, elem)
+		}
+	}
+}
+
+// fieldTokens returns the tokens of a document field that clauses may
+// match, with offsets running on across values as in the index.
+func (s *Searcher) fieldTokens(sd storedDoc, field string, values []string, clauses []highlightClause) []analysis.TokenPosition {
+	var hasOffsets bool
+	if sd.segSnap != nil {
+		hasOffsets = sd.segSnap.Segment().HasOffsets(field)
+	} else {
+		hasOffsets = s.snapshot.Mapping().OffsetsFor(field)
+	}
+	if !hasOffsets {
+		return s.reanalyze(field, values)
+	}
+
+	candidates := make(map[string]bool)
+	for _, c := range clauses {
+		if c.match == nil {
+			for _, tokens := range s.analyzeForField(c.text, field) {
+				for _, t := range tokens {
+					candidates[t.Token] = true
+				}
+			}
+			continue
+		}
+		if sd.segSnap != nil {
+			terms, _ := c.find(sd.segSnap.Segment(), field)
+			for _, term := range terms {
+				candidates[term] = true
+			}
+			continue
+		}
+		for term := range s.snapshot.Builder().Fields[field] {
+			if c.match(field, term) {
+				candidates[term] = true
+			}
+		}
+	}
+
+	var tokens []analysis.TokenPosition
+	for term := range candidates {
+		var postings []segment.Posting
+		if sd.segSnap != nil {
+			postings, _ = sd.segSnap.Search(term, field)
+		} else {
+			postings = s.snapshot.Builder().Fields[field][term]
+		}
+		i := sort.Search(len(postings), func(i int) bool { return postings[i].DocNum >= sd.docNum })
+		if i == len(postings) || postings[i].DocNum != sd.docNum {
+			continue
+		}
+		p := postings[i]
+		for j, pos := range p.Positions {
+			if j < len(p.Offsets) {
+				tokens = append(tokens, analysis.TokenPosition{
+					Token:    term,
+					Position: pos,
+					Start:    int(p.Offsets[j].Start),
+					End:      int(p.Offsets[j].End),
+				})
+			}
+		}
+	}
+	return tokens
+}
+
+// reanalyze analyzes the values of a field the way they were indexed.
+func (s *Searcher) reanalyze(field string, values []string) []analysis.TokenPosition {
+	a := s.snapshot.AnalyzerFor(field)
+	var tokens []analysis.TokenPosition
+	var base int
+	var basePos uint64
+	for _, v := range values {
+		analyzed := a.Analyze(v)
+		var last uint64
+		for _, t := range analyzed {
+			t.Start += base
+			t.End += base
+			t.Position += basePos
+			last = max(last, t.Position)
+			tokens = append(tokens, t)
+		}
+		if len(analyzed) > 0 {
+			basePos = last + 1 + segment.PositionGap
+		}
+		base += len(v) + 1
+	}
+	return tokens
+}
+
+// highlightSpan is the byte range of a match and the terms it matched.
+type highlightSpan struct {
+	start, end int
+	terms      []string
+}
+
+// matchSpans returns the ranges of tokens matched by clauses. A phrase
+// matches as one span from its first to its last term.
+func (s *Searcher) matchSpans(field string, tokens []analysis.TokenPosition, clauses []highlightClause) []highlightSpan {
+	byTerm := make(map[string][]analysis.TokenPosition)
+	for _, t := range tokens {
+		byTerm[t.Token] = append(byTerm[t.Token], t)
+	}
+
+	var spans []highlightSpan
+	for _, c := range clauses {
+		if c.match != nil {
+			for term, occs := range byTerm {
+				if segment.IsNumericTerm(term) || !c.match(field, term) {
+					continue
+				}
+				for _, t := range occs {
+					spans = append(spans, highlightSpan{start: t.Start, end: t.End, terms: []string{term}})
+				}
+			}
+			continue
+		}
+
+		for _, path := range s.analyzeForField(c.text, field) {
+			if c.loose {
+				for _, t := range path {
+					for _, occ := range byTerm[t.Token] {
+						spans = append(spans, highlightSpan{start: occ.Start, end: occ.End, terms: []string{t.Token}})
+					}
+				}
+				continue
+			}
+			terms, offsets := phraseTerms(path)
+		occurrence:
+			for _, first := range byTerm[terms[0]] {
+				sp := highlightSpan{start: first.Start, end: first.End, terms: terms}
+				for i := 1; i < len(terms); i++ {
+					j := slices.IndexFunc(byTerm[terms[i]], func(t analysis.TokenPosition) bool {
+						return t.Position == first.Position+offsets[i]
+					})
+					if j < 0 {
+						continue occurrence
+					}
+					t := byTerm[terms[i]][j]
+					sp.start, sp.end = min(sp.start, t.Start), max(sp.end, t.End)
+				}
+				spans = append(spans, sp)
+			}
+		}
+	}
+	return spans
+}
+
+// fragment is a highlighted snippet of one field value.
+type fragment struct {
+	text  string
+	terms int // distinct terms matched
+	hits  int // matches
+	value int // index of the value within the field
+	start int
+}
+
+// buildFragments cuts text into non-overlapping fragments of about
+// opts.FragmentSize bytes around the spans, breaking at whitespace where
+// possible, and wraps each span in the tags.
+func buildFragments(text string, spans []highlightSpan, opts HighlightOptions) []fragment {
+	spans = mergeSpans(spans)
+
+	var out []fragment
+	prevEnd := 0
+	for i := 0; i < len(spans); {
+		// Take as many spans as fit, centred in the window.
+		j := i
+		for j+1 < len(spans) && spans[j+1].end-spans[i].start <= opts.FragmentSize {
+			j++
+		}
+		matchLen := spans[j].end - spans[i].start
+		ws := max(prevEnd, spans[i].start-max(0, opts.FragmentSize-matchLen)/2)
+		we := min(len(text), ws+max(opts.FragmentSize, matchLen))
+		if we == len(text) {
+			ws = max(prevEnd, min(ws, we-opts.FragmentSize))
+		}
+		for j+1 < len(spans) && spans[j+1].end <= we {
+			j++
+		}
+		if j+1 < len(spans) {
+			we = min(we, spans[j+1].start)
+		}
+
+		// Break at whitespace outside the matches.
+		if ws > 0 && !isSpaceBefore(text, ws) {
+			if k := strings.IndexFunc(text[ws:spans[i].start], unicode.IsSpace); k >= 0 {
+				ws += k + 1
+			} else {
+				for ws > prevEnd && !utf8.RuneStart(text[ws]) {
+					ws--
+				}
+			}
+		}
+		if we < len(text) && !isSpaceAt(text, we) {
+			if k := strings.LastIndexFunc(text[spans[j].end:we], unicode.IsSpace); k >= 0 {
+				we = spans[j].end + k
+			} else {
+				for we > spans[j].end && !utf8.RuneStart(text[we]) {
+					we--
+				}
+			}
+		}
+
+		terms := make(map[string]bool)
+		var b strings.Builder
+		pos := ws
+		for _, sp := range spans[i : j+1] {
+			for _, term := range sp.terms {
+				terms[term] = true
+			}
+			b.WriteString(text[pos:sp.start])
+			b.WriteString(opts.PreTag)
+			b.WriteString(text[sp.start:sp.end])
+			b.WriteString(opts.PostTag)
+			pos = sp.end
+		}
+		b.WriteString(text[pos:we])
+
+		out = append(out, fragment{
+			text:  strings.TrimSpace(b.String()),
+			terms: len(terms),
+			hits:  j - i + 1,
+			start: ws,
+		})
+		prevEnd = we
+		i = j + 1
+	}
+	return out
+}
+
+// mergeSpans sorts spans and joins overlapping ones, such as the stacked
+// tokens of n-grams or a phrase containing a separately matched term.
+func mergeSpans(spans []highlightSpan) []highlightSpan {
+	spans = slices.Clone(spans)
+	slices.SortFunc(spans, func(a, b highlightSpan) int {
+		if a.start != b.start {
+			return a.start - b.start
+		}
+		return b.end - a.end
+	})
+
+	var out []highlightSpan
+	for _, sp := range spans {
+		if n := len(out); n > 0 && sp.start < out[n-1].end {
+			last := &out[n-1]
+			last.end = max(last.end, sp.end)
+			for _, term := range sp.terms {
+				if !slices.Contains(last.terms, term) {
+					last.terms = append(last.terms, term)
+				}
+			}
+			continue
+		}
+		sp.terms = slices.Clone(sp.terms)
+		out = append(out, sp)
+	}
+	return out
+}
+
+// isSpaceBefore reports whether the rune before byte i of text is a space.
+func isSpaceBefore(text string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(text[:i])
+	return unicode.IsSpace(r)
+}
+
+// isSpaceAt reports whether the rune at byte i of text is a space.
+func isSpaceAt(text string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	return unicode.IsSpace(r)
+}
@@ -0,0 +1,171 @@
+package search
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"harshagw/postings/internal/index"
+	"harshagw/postings/internal/mapping"
+	"harshagw/postings/internal/query"
+)
+
+func TestHighlight_FragmentsAndMatchedTerms(t *testing.T) {
+	snapshot := createTestSnapshot(t)
+	defer snapshot.Close()
+	s := New(snapshot)
+	defer s.Close()
+
+	q := &query.BoolQuery{Should: []query.Query{
+		&query.TermQuery{Term: "hello"},
+		&query.PhraseQuery{Field: "body", Phrase: "go world"},
+	}}
+	results, err := s.RunQuery(q)
+	if err != nil {
+		t.Fatalf("RunQuery error: %v", err)
+	}
+	if err := s.Highlight(q, results, HighlightOptions{}); err != nil {
+		t.Fatalf("Highlight error: %v", err)
+	}
+
+	byID := make(map[string]Result)
+	for _, r := range results {
+		byID[r.DocID] = r
+	}
+	doc3 := byID["doc3"]
+	if doc3.Doc["title"] != "Hello Go" {
+		t.Errorf("Doc not loaded: %v", doc3.Doc)
+	}
+	if want := []string{"go", "hello", "world"}; !slices.Equal(doc3.MatchedTerms, want) {
+		t.Errorf("MatchedTerms = %v, want %v", doc3.MatchedTerms, want)
+	}
+	want := map[string][]string{
+		"title": {"<em>Hello</em> Go"},
+		"body":  {"<em>Hello</em> from <em>Go world</em>."},
+	}
+	if !reflect.DeepEqual(doc3.Fragments, want) {
+		t.Errorf("Fragments = %v, want %v", doc3.Fragments, want)
+	}
+}
+
+func TestHighlight_IgnoresMustNot(t *testing.T) {
+	snapshot := createTestSnapshot(t)
+	defer snapshot.Close()
+	s := New(snapshot)
+	defer s.Close()
+
+	q := &query.BoolQuery{
+		Must:    []query.Query{&query.TermQuery{Field: "title", Term: "go"}},
+		MustNot: []query.Query{&query.TermQuery{Term: "hello"}},
+	}
+	results, err := s.RunQuery(q)
+	if err != nil {
+		t.Fatalf("RunQuery error: %v", err)
+	}
+	if err := s.Highlight(q, results, HighlightOptions{PreTag: "[", PostTag: "]"}); err != nil {
+		t.Fatalf("Highlight error: %v", err)
+	}
+	if len(results) != 1 || !slices.Equal(results[0].Fragments["title"], []string{"[Go] Programming"}) {
+		t.Errorf("unexpected results: %+v", results)
+	}
+	if _, ok := results[0].Fragments["body"]; ok {
+		t.Errorf("body should not be highlighted for a title query: %v", results[0].Fragments)
+	}
+}
+
+// A multi-match query highlights its terms on their own, only in its fields.
+func TestHighlight_MultiMatch(t *testing.T) {
+	snapshot := createTestSnapshot(t)
+	defer snapshot.Close()
+	s := New(snapshot)
+	defer s.Close()
+
+	q := &query.BoostQuery{Query: &query.MultiMatchQuery{Text: "world hello", Fields: []query.FieldBoost{{Field: "body", Boost: 2}}}, Boost: 2}
+	results, err := s.RunQuery(q)
+	if err != nil {
+		t.Fatalf("RunQuery error: %v", err)
+	}
+	if err := s.Highlight(q, results, HighlightOptions{}); err != nil {
+		t.Fatalf("Highlight error: %v", err)
+	}
+
+	for _, r := range results {
+		if r.DocID != "doc3" {
+			continue
+		}
+		want := map[string][]string{"body": {"<em>Hello</em> from Go <em>world</em>."}}
+		if !reflect.DeepEqual(r.Fragments, want) {
+			t.Errorf("Fragments = %v, want %v", r.Fragments, want)
+		}
+		return
+	}
+	t.Errorf("doc3 not found: %+v", results)
+}
+
+// Offsets stored in the postings and offsets from re-analysis must point at
+// the same text, including through char filters.
+func TestHighlight_StoredOffsetsMatchReanalysis(t *testing.T) {
+	for _, offsets := range []bool{false, true} {
+		m := mapping.New(nil)
+		m.Fields = map[string]mapping.FieldMapping{
+			"body": {Analyzer: "custom", Options: map[string]string{"char_filters": "html_strip", "token_filters": "lowercase"}, Offsets: offsets},
+		}
+		if err := m.Compile(nil); err != nil {
+			t.Fatalf("Compile error: %v", err)
+		}
+		config := index.DefaultConfig(t.TempDir())
+		config.Mapping = m
+		idx, err := index.New(config)
+		if err != nil {
+			t.Fatalf("New index error: %v", err)
+		}
+		defer idx.Close()
+
+		idx.Index("doc1", map[string]any{"body": []any{"<p>Searching &amp; indexing</p>", "<b>Search</b> engines"}})
+		idx.Flush()
+		idx.Index("doc2", map[string]any{"body": "a search engine"})
+
+		s, cleanup := createSearcher(t, idx)
+		defer cleanup()
+
+		q := &query.PrefixQuery{Field: "body", Prefix: "search"}
+		results, err := s.RunQuery(q)
+		if err != nil {
+			t.Fatalf("RunQuery error: %v", err)
+		}
+		if err := s.Highlight(q, results, HighlightOptions{}); err != nil {
+			t.Fatalf("Highlight error: %v", err)
+		}
+
+		got := make(map[string][]string)
+		for _, r := range results {
+			got[r.DocID] = r.Fragments["body"]
+		}
+		want := map[string][]string{
+			"doc1": {"<p><em>Searching</em> &amp; indexing</p>", "<b><em>Search</em></b> engines"},
+			"doc2": {"a <em>search</em> engine"},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("offsets=%v: fragments = %v, want %v", offsets, got, want)
+		}
+	}
+}
+
+func TestBuildFragments(t *testing.T) {
+	text := "alpha beta gamma delta epsilon zeta eta theta iota kappa"
+	spans := []highlightSpan{
+		{start: 0, end: 5, terms: []string{"alpha"}},
+		{start: 17, end: 22, terms: []string{"delta"}},
+		{start: 46, end: 50, terms: []string{"iota"}},
+		{start: 46, end: 50, terms: []string{"iota"}}, // stacked tokens merge
+	}
+	got := buildFragments(text, spans, HighlightOptions{PreTag: "[", PostTag: "]", FragmentSize: 24})
+
+	want := []fragment{
+		{text: "[alpha] beta gamma [delta]", terms: 2, hits: 2, start: 0},
+		{text: "eta theta [iota] kappa", terms: 1, hits: 1, start: 36},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("buildFragments =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,215 @@
+package search
+
+import (
+	"slices"
+
+	"harshagw/postings/internal/query"
+	"harshagw/postings/internal/similarity"
+)
+
+// multiMatchSearch matches the terms of a multi-match query's text in each
+// of its fields. A document scores, for each field and query position, its
+// best scoring term there times the field's boost; the query type decides
+// how these combine, and the result is scaled by the fraction of positions
+// matched in any field (coordination).
+func (s *Searcher) multiMatchSearch(q *query.MultiMatchQuery, c collector) {
+	mm := s.newMultiMatcher(q, newStatsCache(s.snapshot))
+
+	docs := make(map[docKey]*multiMatchDoc)
+	for i, f := range mm.fields {
+		for p, terms := range f.terms {
+			for _, term := range terms {
+				for _, m := range s.termMatches(term, f.field) {
+					key := docKey{m.segmentIdx, m.docNum}
+					d, ok := docs[key]
+					if !ok {
+						d = mm.newDoc()
+						docs[key] = d
+					}
+					d.add(i, p, mm.termScore(m, i, p))
+				}
+			}
+		}
+	}
+
+	for key, d := range docs {
+		c.collect(hit{segmentIdx: key.segmentIdx, docNum: key.docNum, score: mm.score(d)})
+	}
+}
+
+// multiMatcher is a multi-match query with its text analyzed per field.
+type multiMatcher struct {
+	q         *query.MultiMatchQuery
+	stats     *statsCache
+	fields    []multiMatchField
+	positions []uint64 // distinct positions of the analyzed terms, in order
+
+	// Cross-field statistics per position: the largest document frequency
+	// of its terms and number of documents over the fields.
+	docFreq  []float64
+	docCount []float64
+}
+
+// multiMatchField is a field of a multi-match query and its terms at each
+// query position; several terms share a position when the field's search
+// analyzer expands synonyms.
+type multiMatchField struct {
+	field string
+	boost float64
+	terms [][]string
+}
+
+func (s *Searcher) newMultiMatcher(q *query.MultiMatchQuery, stats *statsCache) *multiMatcher {
+	mm := &multiMatcher{q: q, stats: stats}
+	mm.fields, mm.positions = s.multiMatchFields(q)
+
+	if q.Type == query.CrossFields {
+		mm.docFreq = make([]float64, len(mm.positions))
+		mm.docCount = make([]float64, len(mm.positions))
+		for _, f := range mm.fields {
+			n := float64(stats.snapshot.FieldStats(f.field).DocCount)
+			for p, terms := range f.terms {
+				for _, term := range terms {
+					mm.docFreq[p] = max(mm.docFreq[p], float64(stats.snapshot.TermStats(f.field, term).DocFreq))
+				}
+				mm.docCount[p] = max(mm.docCount[p], n)
+			}
+		}
+	}
+	return mm
+}
+
+// multiMatchFields analyzes the text of q for each of its fields, or every
+// field if it names none. It also returns the distinct positions of the
+// terms over all fields, in order, which index each field's terms.
+func (s *Searcher) multiMatchFields(q *query.MultiMatchQuery) ([]multiMatchField, []uint64) {
+	fields := q.Fields
+	if len(fields) == 0 {
+		for _, f := range s.getFieldsToSearch("") {
+			fields = append(fields, query.FieldBoost{Field: f, Boost: 1})
+		}
+	}
+
+	var positions []uint64
+	analyzed := make([]map[uint64][]string, len(fields))
+	for i, f := range fields {
+		analyzed[i] = make(map[uint64][]string)
+		for _, tokens := range s.analyzeForField(q.Text, f.Field) {
+			for _, t := range tokens {
+				if !slices.Contains(analyzed[i][t.Position], t.Token) {
+					analyzed[i][t.Position] = append(analyzed[i][t.Position], t.Token)
+				}
+				if !slices.Contains(positions, t.Position) {
+					positions = append(positions, t.Position)
+				}
+			}
+		}
+	}
+	slices.Sort(positions)
+
+	out := make([]multiMatchField, len(fields))
+	for i, f := range fields {
+		terms := make([][]string, len(positions))
+		for p, pos := range positions {
+			terms[p] = analyzed[i][pos]
+		}
+		out[i] = multiMatchField{field: f.Field, boost: f.Boost, terms: terms}
+	}
+	return out, positions
+}
+
+// termStats returns the statistics a match at position p is scored with.
+func (mm *multiMatcher) termStats(m searchMatch, p int) similarity.Stats {
+	stats := mm.stats.stats(m, 0)
+	if mm.q.Type == query.CrossFields {
+		stats.DocFreq, stats.DocCount = mm.docFreq[p], mm.docCount[p]
+	}
+	return stats
+}
+
+// termScore scores a match in the i-th field at position p.
+func (mm *multiMatcher) termScore(m searchMatch, i, p int) float64 {
+	return mm.fields[i].boost * mm.stats.similarity(m.field).Score(mm.termStats(m, p))
+}
+
+// multiMatchDoc holds a document's best term score per field and position.
+type multiMatchDoc struct {
+	scores  [][]float64 // field -> position -> score
+	matched []bool      // position -> matched in any field
+}
+
+func (mm *multiMatcher) newDoc() *multiMatchDoc {
+	d := &multiMatchDoc{scores: make([][]float64, len(mm.fields)), matched: make([]bool, len(mm.positions))}
+	for i := range d.scores {
+		d.scores[i] = make([]float64, len(mm.positions))
+	}
+	return d
+}
+
+func (d *multiMatchDoc) add(i, p int, score float64) {
+	d.scores[i][p] = max(d.scores[i][p], score)
+	d.matched[p] = true
+}
+
+// score combines a document's term scores as the query type says.
+func (mm *multiMatcher) score(d *multiMatchDoc) float64 {
+	var score float64
+	switch mm.q.Type {
+	case query.MostFields:
+		for _, fs := range mm.fieldScores(d) {
+			score += fs
+		}
+	case query.CrossFields:
+		for _, ps := range mm.positionScores(d) {
+			score += ps
+		}
+	default:
+		score = disMax(mm.fieldScores(d), mm.q.TieBreaker)
+	}
+	return coord(score, d.matchedCount(), len(mm.positions))
+}
+
+// fieldScores returns the sum of each field's term scores.
+func (mm *multiMatcher) fieldScores(d *multiMatchDoc) []float64 {
+	scores := make([]float64, len(d.scores))
+	for i, row := range d.scores {
+		for _, score := range row {
+			scores[i] += score
+		}
+	}
+	return scores
+}
+
+// positionScores returns each position's best field score plus the tie
+// breaker times the others.
+func (mm *multiMatcher) positionScores(d *multiMatchDoc) []float64 {
+	scores := make([]float64, len(mm.positions))
+	column := make([]float64, len(d.scores))
+	for p := range scores {
+		for i := range d.scores {
+			column[i] = d.scores[i][p]
+		}
+		scores[p] = disMax(column, mm.q.TieBreaker)
+	}
+	return scores
+}
+
+func (d *multiMatchDoc) matchedCount() int {
+	var n int
+	for _, ok := range d.matched {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+// disMax returns the largest score plus tieBreaker times the others.
+func disMax(scores []float64, tieBreaker float64) float64 {
+	var best, sum float64
+	for _, score := range scores {
+		best = max(best, score)
+		sum += score
+	}
+	return best + tieBreaker*(sum-best)
+}
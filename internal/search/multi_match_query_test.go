@@ -0,0 +1,159 @@
+package search
+
+import (
+	"testing"
+
+	"harshagw/postings/internal/index"
+	"harshagw/postings/internal/query"
+)
+
+func createMultiMatchIndex(t *testing.T) *index.Index {
+	t.Helper()
+	idx, err := index.New(index.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	t.Cleanup(func() { idx.Close() })
+
+	idx.Index("both", map[string]any{"title": "go tips", "body": "go is fun to write"})
+	idx.Index("title", map[string]any{"title": "go tips", "body": "rust is fun to write"})
+	idx.Index("body", map[string]any{"title": "rust tips", "body": "go is fun to write"})
+	idx.Flush()
+	idx.Index("names", map[string]any{"first": "john", "last": "smith"})
+	idx.Index("johns", map[string]any{"first": "john", "last": "johnson john"})
+	idx.Index("other", map[string]any{"first": "jane", "last": "doe", "title": "other", "body": "other"})
+	return idx
+}
+
+func mustFieldBoosts(t *testing.T, s string) []query.FieldBoost {
+	t.Helper()
+	fields, err := query.ParseFieldBoosts(s)
+	if err != nil {
+		t.Fatalf("ParseFieldBoosts(%q) error: %v", s, err)
+	}
+	return fields
+}
+
+func runMultiMatch(t *testing.T, s *Searcher, q *query.MultiMatchQuery) map[string]float64 {
+	t.Helper()
+	results, err := s.RunQuery(q)
+	if err != nil {
+		t.Fatalf("RunQuery(%s) error: %v", q, err)
+	}
+	scores := make(map[string]float64)
+	for _, r := range results {
+		scores[r.DocID] = r.Score
+	}
+	return scores
+}
+
+func TestMultiMatch_BestAndMostFields(t *testing.T) {
+	s, cleanup := createSearcher(t, createMultiMatchIndex(t))
+	defer cleanup()
+
+	fields := mustFieldBoosts(t, "title body")
+	best := runMultiMatch(t, s, &query.MultiMatchQuery{Text: "go", Fields: fields, Type: query.BestFields})
+	most := runMultiMatch(t, s, &query.MultiMatchQuery{Text: "go", Fields: fields, Type: query.MostFields})
+
+	if len(best) != 3 || len(most) != 3 {
+		t.Fatalf("expected 3 matches, got best %v, most %v", best, most)
+	}
+	// "both" and "title" have the same title, so their best field scores the same.
+	if best["both"] != best["title"] {
+		t.Errorf("best_fields: expected both == title, got %v", best)
+	}
+	if most["both"] <= most["title"] || most["both"] <= most["body"] {
+		t.Errorf("most_fields: expected both to score highest, got %v", most)
+	}
+	if want := most["title"] + most["body"]; most["both"] != want {
+		t.Errorf("most_fields: expected both = %v, got %v", want, most["both"])
+	}
+
+	tie := runMultiMatch(t, s, &query.MultiMatchQuery{Text: "go", Fields: fields, Type: query.BestFields, TieBreaker: 1})
+	if tie["both"] != most["both"] {
+		t.Errorf("best_fields with tie breaker 1 should equal most_fields: %v, %v", tie["both"], most["both"])
+	}
+}
+
+func TestMultiMatch_FieldBoosts(t *testing.T) {
+	s, cleanup := createSearcher(t, createMultiMatchIndex(t))
+	defer cleanup()
+
+	plain := runMultiMatch(t, s, &query.MultiMatchQuery{Text: "go", Fields: mustFieldBoosts(t, "title body")})
+	boosted := runMultiMatch(t, s, &query.MultiMatchQuery{Text: "go", Fields: mustFieldBoosts(t, "title^3 body")})
+
+	if boosted["title"] != 3*plain["title"] {
+		t.Errorf("expected the title match to triple: %v -> %v", plain["title"], boosted["title"])
+	}
+	if boosted["body"] != plain["body"] {
+		t.Errorf("expected the body match to stay: %v -> %v", plain["body"], boosted["body"])
+	}
+	if boosted["title"] <= boosted["body"] {
+		t.Errorf("expected the boosted title match first, got %v", boosted)
+	}
+}
+
+func TestMultiMatch_CrossFields(t *testing.T) {
+	s, cleanup := createSearcher(t, createMultiMatchIndex(t))
+	defer cleanup()
+
+	fields := mustFieldBoosts(t, "first last")
+	cross := runMultiMatch(t, s, &query.MultiMatchQuery{Text: "john smith", Fields: fields, Type: query.CrossFields})
+	if len(cross) != 2 {
+		t.Fatalf("expected 2 matches, got %v", cross)
+	}
+	if cross["names"] <= cross["johns"] {
+		t.Errorf("expected the document matching both terms first, got %v", cross)
+	}
+
+	// "john" is rarer in last than in first; blending uses the larger
+	// document frequency, so the match in last does not win on rarity alone.
+	johns := runMultiMatch(t, s, &query.MultiMatchQuery{Text: "john", Fields: fields, Type: query.CrossFields})
+	best := runMultiMatch(t, s, &query.MultiMatchQuery{Text: "john", Fields: fields, Type: query.BestFields})
+	if johns["johns"] >= best["johns"] {
+		t.Errorf("expected blended statistics to lower the score: cross %v, best %v", johns["johns"], best["johns"])
+	}
+}
+
+func TestMultiMatch_AllFieldsAndExplain(t *testing.T) {
+	s, cleanup := createSearcher(t, createMultiMatchIndex(t))
+	defer cleanup()
+
+	queries := []*query.MultiMatchQuery{
+		{Text: "go tips"},
+		{Text: "go john", Type: query.MostFields},
+		{Text: "john smith", Fields: mustFieldBoosts(t, "first^2 last"), Type: query.CrossFields, TieBreaker: 0.3},
+		{Text: "rust fun", Fields: mustFieldBoosts(t, "title^3 body"), TieBreaker: 0.1},
+	}
+	for _, q := range queries {
+		scores := runMultiMatch(t, s, q)
+		if len(scores) == 0 {
+			t.Errorf("%s: expected matches", q)
+		}
+		for _, id := range []string{"both", "title", "body", "names", "johns", "other"} {
+			expl, err := s.Explain(q, id)
+			if err != nil {
+				t.Fatalf("Explain(%s, %s) error: %v", q, id, err)
+			}
+			score, hit := scores[id]
+			if expl.Match != hit || expl.Value != score {
+				t.Errorf("%s, %s: explained match=%v value=%v, search hit=%v score=%v\n%s",
+					q, id, expl.Match, expl.Value, hit, score, expl)
+			}
+		}
+	}
+
+	plain := runMultiMatch(t, s, &query.MultiMatchQuery{Text: "rust"})
+	boosted, err := s.RunQuery(&query.BoostQuery{Query: &query.MultiMatchQuery{Text: "rust"}, Boost: 2})
+	if err != nil {
+		t.Fatalf("RunQuery error: %v", err)
+	}
+	if len(boosted) != len(plain) {
+		t.Fatalf("expected %d boosted results, got %d", len(plain), len(boosted))
+	}
+	for _, r := range boosted {
+		if r.Score != 2*plain[r.DocID] {
+			t.Errorf("%s: expected boosted score %v, got %v", r.DocID, 2*plain[r.DocID], r.Score)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package search
+
+import (
+	"fmt"
+	"slices"
+
+	"harshagw/postings/internal/mapping"
+	"harshagw/postings/internal/query"
+)
+
+// nearSearch matches documents with every term of q in one field, in any
+// order, with at most q.Distance other positions between them. It scores
+// like a phrase whose frequency is 1 / (1 + distance) of the tightest match.
+func (s *Searcher) nearSearch(q *query.NearQuery, c collector) error {
+	var matches []searchMatch
+	for _, f := range s.getFieldsToSearch(q.Field) {
+		phrases, err := s.nearPhrases(q, f)
+		if err != nil {
+			return err
+		}
+		for _, ph := range phrases {
+			matches = append(matches, s.phraseMatches(ph, f)...)
+		}
+	}
+	s.scoreMatches(matches, c)
+	return nil
+}
+
+// maxNearCombinations limits the phrases a NEAR query expands to when its
+// terms have synonyms.
+const maxNearCombinations = 64
+
+// nearPhrases analyzes the terms of q for a text field into unordered
+// phrases, one per combination of the terms' alternatives. A term that
+// analyzes to several tokens contributes each of them, with tokens stacked
+// at one position counting as one position; one that analyzes to none,
+// such as a stop word, is left out. More than maxNearCombinations
+// combinations are an error rather than dropped.
+func (s *Searcher) nearPhrases(q *query.NearQuery, field string) ([]phrase, error) {
+	if s.snapshot.Mapping().TypeFor(field) != mapping.TypeText {
+		return nil, nil
+	}
+
+	combinations := []phrase{{slop: q.Distance, unordered: true}}
+	for _, text := range q.Terms {
+		alternatives := s.analyzeForField(text, field)
+		if len(alternatives) == 0 {
+			continue
+		}
+		var next []phrase
+		for _, ph := range combinations {
+			// Each term's tokens keep their positions, after those of the
+			// terms before it.
+			var base uint64
+			if n := len(ph.offsets); n > 0 {
+				base = ph.offsets[n-1] + 1
+			}
+			for _, tokens := range alternatives {
+				terms, offsets := phraseTerms(tokens)
+				for i := range offsets {
+					offsets[i] += base
+				}
+				next = append(next, phrase{
+					terms:     slices.Concat(ph.terms, terms),
+					offsets:   slices.Concat(ph.offsets, offsets),
+					slop:      ph.slop,
+					unordered: true,
+				})
+			}
+		}
+		if len(next) > maxNearCombinations {
+			return nil, fmt.Errorf("%s expands to more than %d combinations of synonyms in field %s", q, maxNearCombinations, field)
+		}
+		combinations = next
+	}
+
+	var phrases []phrase
+	for _, ph := range combinations {
+		if len(ph.terms) > 0 {
+			phrases = append(phrases, ph)
+		}
+	}
+	return phrases, nil
+}
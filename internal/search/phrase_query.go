@@ -1,56 +1,57 @@
 package search
 
 import (
+	"cmp"
+	"slices"
+
+	"harshagw/postings/internal/analysis"
 	"harshagw/postings/internal/index"
 	"harshagw/postings/internal/segment"
 )
 
-// phraseSearch searches for an exact phrase in a field.
-// If field is empty, searches all fields.
-func (s *Searcher) phraseSearch(phrase, field string) ([]Result, error) {
-	tokens := s.snapshot.Analyzer().Analyze(phrase)
+// phraseSearch searches for a phrase in a field, with its terms up to slop
+// positions out of place. If field is empty, searches all fields.
+func (s *Searcher) phraseSearch(text string, slop int, field string, c collector) error {
+	s.analyzedSearch(text, slop, s.getFieldsToSearch(field), c)
+	return nil
+}
 
-	if len(tokens) == 0 {
-		return nil, nil
-	}
+// phrase is a sequence of terms matched by their positions. An exact phrase
+// has slop 0. A sloppy phrase matches if its terms can be moved into place
+// with at most slop position moves in total, as in Lucene. An unordered
+// phrase matches its terms in any order with at most slop other positions
+// between them, and uses its offsets only to tell which terms share a query
+// position.
+type phrase struct {
+	terms     []string
+	offsets   []uint64 // each term's position relative to the first
+	slop      int
+	unordered bool
+}
+
+func newPhrase(tokens []analysis.TokenPosition, slop int) phrase {
+	terms, offsets := phraseTerms(tokens)
+	return phrase{terms: terms, offsets: offsets, slop: slop}
+}
 
+// phraseTerms returns the terms of a phrase and each term's position
+// relative to the first, which keeps gaps left by removed stop words.
+func phraseTerms(tokens []analysis.TokenPosition) ([]string, []uint64) {
 	terms := make([]string, len(tokens))
+	offsets := make([]uint64, len(tokens))
 	for i, t := range tokens {
 		terms[i] = t.Token
+		offsets[i] = t.Position - tokens[0].Position
 	}
-
-	if len(terms) == 1 {
-		return s.termSearch(terms[0], field)
-	}
-
-	var matches []searchMatch
-	seen := make(map[string]bool)
-
-	fields := s.getFieldsToSearch(field)
-
-	for _, f := range fields {
-		segments := s.snapshot.Segments()
-		for i := len(segments) - 1; i >= 0; i-- {
-			segSnap := segments[i]
-			segMatches := s.phraseMatchInSegment(segSnap, terms, f, seen)
-			matches = append(matches, segMatches...)
-		}
-
-		if s.snapshot.Builder() != nil {
-			builderMatches := s.phraseMatchInBuilder(terms, f, seen)
-			matches = append(matches, builderMatches...)
-		}
-	}
-
-	return s.scoreAndSort(matches, field), nil
+	return terms, offsets
 }
 
-func (s *Searcher) phraseMatchInSegment(segSnap *index.SegmentSnapshot, terms []string, field string, seen map[string]bool) []searchMatch {
+func (s *Searcher) phraseMatchInSegment(segSnap *index.SegmentSnapshot, ph phrase, field string, segIdx int) []searchMatch {
 	var matches []searchMatch
 	seg := segSnap.Segment()
 
-	termPostings := make([][]segment.Posting, len(terms))
-	for i, term := range terms {
+	termPostings := make([][]segment.Posting, len(ph.terms))
+	for i, term := range ph.terms {
 		postings, err := segSnap.Search(term, field)
 		if err != nil || len(postings) == 0 {
 			return matches
@@ -60,7 +61,7 @@ func (s *Searcher) phraseMatchInSegment(segSnap *index.SegmentSnapshot, terms []
 
 	docPositions := make(map[uint64][][]uint64)
 	for _, p := range termPostings[0] {
-		docPositions[p.DocNum] = make([][]uint64, len(terms))
+		docPositions[p.DocNum] = make([][]uint64, len(ph.terms))
 	}
 
 	for termIdx, postings := range termPostings {
@@ -83,18 +84,14 @@ func (s *Searcher) phraseMatchInSegment(segSnap *index.SegmentSnapshot, terms []
 			continue
 		}
 
-		if phraseMatch(positions) {
-			extID, ok := seg.ExternalID(docNum)
-			if !ok || seen[extID] {
-				continue
-			}
-			seen[extID] = true
+		if freq := ph.freq(positions); freq > 0 {
 			matches = append(matches, searchMatch{
-				docID:       extID,
-				tf:          1.0,
+				segmentIdx:  segIdx,
+				docNum:      docNum,
+				tf:          freq,
 				fieldLength: seg.FieldLength(field, docNum),
 				field:       field,
-				segmentIdx:  -1,
+				terms:       ph.terms,
 			})
 		}
 	}
@@ -102,7 +99,194 @@ func (s *Searcher) phraseMatchInSegment(segSnap *index.SegmentSnapshot, terms []
 	return matches
 }
 
-func phraseMatch(positions [][]uint64) bool {
+// freq returns how well a document matches the phrase given the positions
+// of each term in it: 1 for an exact match, 1 / (1 + distance) for the
+// tightest sloppy match, and 0 if there is none. Like an exact phrase, a
+// sloppy phrase counts once however often it occurs.
+func (p phrase) freq(positions [][]uint64) float64 {
+	if p.slop == 0 && !p.unordered {
+		if phraseMatch(positions, p.offsets) {
+			return 1
+		}
+		return 0
+	}
+
+	groups, n := offsetGroups(p.offsets)
+	offsets := p.offsets
+	if p.unordered {
+		offsets = nil
+	}
+	distance, ok := matchLength(positions, offsets, groups)
+	if p.unordered {
+		// Adjacent query positions in any order are at distance 0.
+		distance -= n - 1
+	}
+	if !ok || distance > p.slop {
+		return 0
+	}
+	return 1 / float64(1+distance)
+}
+
+// offsetGroups numbers the distinct offsets of a phrase's terms, which
+// never decrease. Terms with the same offset, such as tokens stacked at one
+// query position, are one group. n is the number of groups.
+func offsetGroups(offsets []uint64) (groups []int, n int) {
+	groups = make([]int, len(offsets))
+	for i, off := range offsets {
+		if i == 0 || off != offsets[i-1] {
+			n++
+		}
+		groups[i] = n - 1
+	}
+	return groups, n
+}
+
+// matchLength returns the smallest spread of position - offset over a
+// choice of one position per term, with no position chosen by terms of two
+// groups; with nil offsets it is the smallest span of positions holding
+// every term. ok is false if the terms cannot be placed that way.
+func matchLength(positions [][]uint64, offsets []uint64, groups []int) (int, bool) {
+	type occurrence struct {
+		at   int64 // position - offset
+		term int
+		pos  uint64
+	}
+	var occs []occurrence
+	for i, ps := range positions {
+		for _, pos := range ps {
+			at := int64(pos)
+			if offsets != nil {
+				at -= int64(offsets[i])
+			}
+			occs = append(occs, occurrence{at: at, term: i, pos: pos})
+		}
+	}
+	slices.SortFunc(occs, func(a, b occurrence) int { return cmp.Compare(a.at, b.at) })
+
+	// Slide a window over the occurrences, shrinking it from the left while
+	// it still holds every term at distinct positions.
+	best, ok := 0, false
+	window := make([][]uint64, len(positions)) // term -> positions in the window
+	placed := newPlacement(window, groups)
+	covered := 0
+	left := 0
+	for _, o := range occs {
+		if len(window[o.term]) == 0 {
+			covered++
+		}
+		window[o.term] = append(window[o.term], o.pos)
+		for covered == len(positions) && placed.fits() {
+			if length := int(o.at - occs[left].at); !ok || length < best {
+				best, ok = length, true
+			}
+			l := occs[left]
+			window[l.term] = window[l.term][1:]
+			if len(window[l.term]) == 0 {
+				covered--
+			}
+			left++
+		}
+	}
+	return best, ok
+}
+
+// placement places each term of a window at one of its candidate
+// positions. Terms of one group may share a position; terms of different
+// groups may not, which only matters for repeated terms or tokens stacked
+// at one position. Its bookkeeping is reused from one check to the next.
+type placement struct {
+	candidates [][]uint64       // term -> candidate positions
+	groups     []int            // term -> group
+	users      map[uint64][]int // position -> terms placed there
+	at         []uint64         // term -> its position, if placed
+	placed     []bool
+	seen       map[uint64]int // position -> the last search that visited it
+	search     int
+}
+
+func newPlacement(candidates [][]uint64, groups []int) *placement {
+	return &placement{
+		candidates: candidates,
+		groups:     groups,
+		users:      make(map[uint64][]int),
+		at:         make([]uint64, len(candidates)),
+		placed:     make([]bool, len(candidates)),
+		seen:       make(map[uint64]int),
+	}
+}
+
+// fits reports whether every term can be placed.
+func (p *placement) fits() bool {
+	clear(p.users)
+	clear(p.placed)
+	for term := range p.candidates {
+		p.search++
+		if !p.place(term) {
+			return false
+		}
+	}
+	return true
+}
+
+// place puts term at a candidate position other than its current one: a
+// free one or one its group holds if there is any, or else one held by
+// another group whose terms there can be moved elsewhere.
+func (p *placement) place(term int) bool {
+	group := p.groups[term]
+	for _, pos := range p.candidates[term] {
+		if p.placed[term] && pos == p.at[term] {
+			continue
+		}
+		if users := p.users[pos]; len(users) == 0 || p.groups[users[0]] == group {
+			p.move(term, pos)
+			return true
+		}
+	}
+	for _, pos := range p.candidates[term] {
+		if p.seen[pos] == p.search || p.placed[term] && pos == p.at[term] {
+			continue
+		}
+		p.seen[pos] = p.search
+		for len(p.users[pos]) > 0 && p.groups[p.users[pos][0]] != group {
+			if !p.place(p.users[pos][0]) {
+				break
+			}
+		}
+		if users := p.users[pos]; len(users) == 0 || p.groups[users[0]] == group {
+			p.move(term, pos)
+			return true
+		}
+	}
+	return false
+}
+
+// move places term at pos, taking it from its previous position.
+func (p *placement) move(term int, pos uint64) {
+	if p.placed[term] {
+		old := p.at[term]
+		p.users[old] = slices.DeleteFunc(p.users[old], func(t int) bool { return t == term })
+	}
+	p.at[term], p.placed[term] = pos, true
+	p.users[pos] = append(p.users[pos], term)
+}
+
+// phraseMatches returns the live documents matching ph in field, from the
+// newest segment to the oldest and then the builder.
+func (s *Searcher) phraseMatches(ph phrase, field string) []searchMatch {
+	var matches []searchMatch
+	segments := s.snapshot.Segments()
+	for i := len(segments) - 1; i >= 0; i-- {
+		matches = append(matches, s.phraseMatchInSegment(segments[i], ph, field, i)...)
+	}
+	if s.snapshot.Builder() != nil {
+		matches = append(matches, s.phraseMatchInBuilder(ph, field)...)
+	}
+	return matches
+}
+
+// phraseMatch reports whether some occurrence of the first term is followed
+// by term i at offsets[i] positions later, for every i.
+func phraseMatch(positions [][]uint64, offsets []uint64) bool {
 	if len(positions) == 0 {
 		return false
 	}
@@ -110,7 +294,7 @@ func phraseMatch(positions [][]uint64) bool {
 	for _, start := range positions[0] {
 		ok := true
 		for i := 1; i < len(positions); i++ {
-			expectedPos := start + uint64(i)
+			expectedPos := start + offsets[i]
 			if !binarySearchUint64(positions[i], expectedPos) {
 				ok = false
 				break
@@ -123,7 +307,7 @@ func phraseMatch(positions [][]uint64) bool {
 	return false
 }
 
-func (s *Searcher) phraseMatchInBuilder(terms []string, field string, seen map[string]bool) []searchMatch {
+func (s *Searcher) phraseMatchInBuilder(ph phrase, field string) []searchMatch {
 	var matches []searchMatch
 	builder := s.snapshot.Builder()
 
@@ -132,8 +316,8 @@ func (s *Searcher) phraseMatchInBuilder(terms []string, field string, seen map[s
 		return matches
 	}
 
-	termPostings := make([][]segment.Posting, len(terms))
-	for i, term := range terms {
+	termPostings := make([][]segment.Posting, len(ph.terms))
+	for i, term := range ph.terms {
 		postings, ok := fieldTerms[term]
 		if !ok || len(postings) == 0 {
 			return matches
@@ -144,7 +328,7 @@ func (s *Searcher) phraseMatchInBuilder(terms []string, field string, seen map[s
 	docPositions := make(map[uint64][][]uint64)
 	for _, p := range termPostings[0] {
 		if !builder.IsDeleted(p.DocNum) {
-			docPositions[p.DocNum] = make([][]uint64, len(terms))
+			docPositions[p.DocNum] = make([][]uint64, len(ph.terms))
 		}
 	}
 
@@ -168,20 +352,15 @@ func (s *Searcher) phraseMatchInBuilder(terms []string, field string, seen map[s
 			continue
 		}
 
-		if phraseMatch(positions) {
-			if docNum < uint64(len(builder.DocIDs)) {
-				extID := builder.DocIDs[docNum]
-				if !seen[extID] {
-					seen[extID] = true
-					matches = append(matches, searchMatch{
-						docID:       extID,
-						tf:          1.0,
-						fieldLength: builder.FieldLength(field, docNum),
-						field:       field,
-						segmentIdx:  -1,
-					})
-				}
-			}
+		if freq := ph.freq(positions); freq > 0 {
+			matches = append(matches, searchMatch{
+				segmentIdx:  -1,
+				docNum:      docNum,
+				tf:          freq,
+				fieldLength: builder.FieldLength(field, docNum),
+				field:       field,
+				terms:       ph.terms,
+			})
 		}
 	}
 
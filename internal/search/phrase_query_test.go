@@ -1,9 +1,11 @@
 package search
 
 import (
+	"slices"
 	"testing"
 
 	"harshagw/postings/internal/index"
+	"harshagw/postings/internal/mapping"
 	"harshagw/postings/internal/query"
 )
 
@@ -312,3 +314,313 @@ func TestPhraseQuery_RejectsPartialOverlap(t *testing.T) {
 		t.Errorf("expected 0 results for partial overlap, got %d", len(results))
 	}
 }
+
+func TestPhraseQuery_DoesNotSpanArrayValues(t *testing.T) {
+	idx, err := index.New(index.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"tags": []any{"big data", "science fiction"}})
+	idx.Flush()
+	idx.Index("doc2", map[string]any{"tags": []any{"data science"}})
+
+	s, sCleanup := createSearcher(t, idx)
+	defer sCleanup()
+
+	results, err := s.RunQueryString(`tags:"data science"`)
+	if err != nil {
+		t.Fatalf("RunQueryString error: %v", err)
+	}
+	if len(results) != 1 || results[0].DocID != "doc2" {
+		t.Errorf("expected only doc2, got %v", results)
+	}
+
+	results, _ = s.RunQueryString(`tags:"science fiction"`)
+	if len(results) != 1 || results[0].DocID != "doc1" {
+		t.Errorf("expected phrase within a value to match doc1, got %v", results)
+	}
+}
+
+func TestPhraseQuery_EdgeNGramField(t *testing.T) {
+	m, err := mapping.Parse([]byte(`{"fields": {"name": {"analyzer": "custom", "options": {
+		"token_filters": "lowercase,edge_ngram", "min_gram": "1", "max_gram": "10"}}}}`), nil)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	config := index.DefaultConfig(t.TempDir())
+	config.Mapping = m
+	idx, err := index.New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"name": "Quick Brown Fox"})
+	idx.Flush()
+	idx.Index("doc2", map[string]any{"name": "Brown Quickly"})
+	idx.Index("doc3", map[string]any{"name": "Quiet Bay"})
+
+	s, sCleanup := createSearcher(t, idx)
+	defer sCleanup()
+
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		// A partial word matches every word it prefixes.
+		{`name:qui`, []string{"doc1", "doc2", "doc3"}},
+		{`name:quic`, []string{"doc1", "doc2"}},
+		// Grams share their word's position, so phrases keep word order.
+		{`name:"quick bro"`, []string{"doc1"}},
+		{`name:"brown qu"`, []string{"doc2"}},
+		{`name:"qu b"`, []string{"doc1", "doc3"}},
+		{`name:"fox quick"`, nil},
+		// Sloppy and unordered matches place a word's grams together.
+		{`name:"quick brown"~1`, []string{"doc1"}},
+		{`name:"quick fox"~2`, []string{"doc1"}},
+		{`name:"fox quick"~2`, nil},
+		{`name:quick NEAR/2 name:fox`, []string{"doc1"}},
+		{`name:fox NEAR/1 name:quick`, []string{"doc1"}},
+		{`name:fox NEAR/0 name:quick`, nil},
+	}
+
+	for _, tt := range tests {
+		results, err := s.RunQueryString(tt.q)
+		if err != nil {
+			t.Fatalf("RunQueryString(%s) error: %v", tt.q, err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.DocID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("RunQueryString(%s) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestPhraseQuery_CJKBigrams(t *testing.T) {
+	m, err := mapping.Parse([]byte(`{"fields": {"body": {"analyzer": "cjk"}}}`), nil)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	config := index.DefaultConfig(t.TempDir())
+	config.Mapping = m
+	idx, err := index.New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"body": "我们在东京都政府工作"})
+	idx.Flush()
+	idx.Index("doc2", map[string]any{"body": "京都是一个美丽的城市"})
+
+	s, sCleanup := createSearcher(t, idx)
+	defer sCleanup()
+
+	tests := []struct {
+		q    query.Query
+		want []string
+	}{
+		{&query.TermQuery{Field: "body", Term: "京都"}, []string{"doc1", "doc2"}},
+		// Longer queries become consecutive bigrams matched as a phrase.
+		{&query.TermQuery{Field: "body", Term: "东京都"}, []string{"doc1"}},
+		{&query.PhraseQuery{Field: "body", Phrase: "东京都政府"}, []string{"doc1"}},
+		{&query.PhraseQuery{Field: "body", Phrase: "美丽的城市"}, []string{"doc2"}},
+		{&query.PhraseQuery{Field: "body", Phrase: "东京城市"}, nil},
+	}
+
+	for _, tt := range tests {
+		results, err := s.RunQuery(tt.q)
+		if err != nil {
+			t.Fatalf("RunQuery(%v) error: %v", tt.q, err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.DocID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("RunQuery(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestPhraseQuery_Synonyms(t *testing.T) {
+	m, err := mapping.Parse([]byte(`{"fields": {
+		"body":  {"analyzer": "custom", "options": {"token_filters": "lowercase,synonym", "synonyms": "nyc, new york; laptop, notebook"}},
+		"title": {"search_analyzer": "custom", "search_options": {"token_filters": "lowercase,synonym", "synonyms": "nyc, new york"}}
+	}}`), nil)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	config := index.DefaultConfig(t.TempDir())
+	config.Mapping = m
+	idx, err := index.New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"body": "pizza in NYC tonight", "title": "NYC pizza"})
+	idx.Index("doc2", map[string]any{"body": "New York pizza", "title": "New York bagels"})
+	idx.Flush()
+	idx.Index("doc3", map[string]any{"body": "notebook stand"})
+	idx.Index("doc4", map[string]any{"body": "york new", "title": "york new"})
+
+	s, sCleanup := createSearcher(t, idx)
+	defer sCleanup()
+
+	tests := []struct {
+		q    query.Query
+		want []string
+	}{
+		// Index-time synonyms, also expanded at query time.
+		{&query.TermQuery{Field: "body", Term: "laptop"}, []string{"doc3"}},
+		{&query.TermQuery{Field: "body", Term: "nyc"}, []string{"doc1", "doc2"}},
+		{&query.PhraseQuery{Field: "body", Phrase: "nyc pizza"}, []string{"doc2"}},
+		{&query.PhraseQuery{Field: "body", Phrase: "in new york tonight"}, []string{"doc1"}},
+		{&query.PhraseQuery{Field: "body", Phrase: "in nyc tonight"}, []string{"doc1"}},
+		// Query-time synonyms only.
+		{&query.TermQuery{Field: "title", Term: "new york"}, []string{"doc1", "doc2"}},
+		{&query.PhraseQuery{Field: "title", Phrase: "new york pizza"}, []string{"doc1"}},
+		{&query.PhraseQuery{Field: "title", Phrase: "nyc bagels"}, []string{"doc2"}},
+	}
+
+	for _, tt := range tests {
+		results, err := s.RunQuery(tt.q)
+		if err != nil {
+			t.Fatalf("RunQuery(%v) error: %v", tt.q, err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.DocID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("RunQuery(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+func createProximityIndex(t *testing.T) *Searcher {
+	t.Helper()
+	idx, err := index.New(index.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	t.Cleanup(func() { idx.Close() })
+
+	idx.Index("exact", map[string]any{"body": "the quick fox ran"})
+	idx.Index("gap1", map[string]any{"body": "the quick brown fox ran"})
+	idx.Flush()
+	idx.Index("gap2", map[string]any{"body": "the quick brown old fox ran"})
+	idx.Index("reversed", map[string]any{"body": "the fox was quick"})
+	idx.Index("repeat", map[string]any{"body": "fox"})
+
+	s, cleanup := createSearcher(t, idx)
+	t.Cleanup(cleanup)
+	return s
+}
+
+func resultIDs(t *testing.T, s *Searcher, q query.Query) []string {
+	t.Helper()
+	results, err := s.RunQuery(q)
+	if err != nil {
+		t.Fatalf("RunQuery(%s) error: %v", q, err)
+	}
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.DocID
+	}
+	return ids
+}
+
+func TestPhraseQuery_Slop(t *testing.T) {
+	s := createProximityIndex(t)
+
+	tests := []struct {
+		q    query.Query
+		want []string // best first
+	}{
+		{&query.PhraseQuery{Phrase: "quick fox"}, []string{"exact"}},
+		{&query.PhraseQuery{Phrase: "quick fox", Slop: 1}, []string{"exact", "gap1"}},
+		{&query.PhraseQuery{Phrase: "quick fox", Slop: 2}, []string{"exact", "gap1", "gap2"}},
+		// Swapping two adjacent terms takes two moves.
+		{&query.PhraseQuery{Phrase: "fox quick", Slop: 1}, []string{"reversed"}},
+		{&query.PhraseQuery{Phrase: "fox quick", Slop: 2}, []string{"reversed", "exact"}},
+		// A repeated term needs two positions.
+		{&query.PhraseQuery{Phrase: "fox fox", Slop: 3}, nil},
+	}
+	for _, tt := range tests {
+		if got := resultIDs(t, s, tt.q); !slices.Equal(got, tt.want) {
+			t.Errorf("RunQuery(%s) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestNearQuery_Unordered(t *testing.T) {
+	s := createProximityIndex(t)
+
+	tests := []struct {
+		q    query.Query
+		want []string // best first
+	}{
+		{&query.NearQuery{Terms: []string{"quick", "fox"}, Distance: 0}, []string{"exact"}},
+		{&query.NearQuery{Terms: []string{"fox", "quick"}, Distance: 1}, []string{"exact", "gap1", "reversed"}},
+		{&query.NearQuery{Field: "body", Terms: []string{"fox", "quick", "brown"}, Distance: 1}, []string{"gap1", "gap2"}},
+		{&query.NearQuery{Field: "title", Terms: []string{"quick", "fox"}, Distance: 5}, nil},
+	}
+	for _, tt := range tests {
+		got := resultIDs(t, s, tt.q)
+		// Documents at the same distance tie; compare them as a set.
+		if !slices.Equal(sortedIDs(got), sortedIDs(tt.want)) || (len(got) > 0 && got[0] != tt.want[0]) {
+			t.Errorf("RunQuery(%s) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestNearQuery_TooManySynonymCombinations(t *testing.T) {
+	m, err := mapping.Parse([]byte(`{"fields": {
+		"body": {"search_analyzer": "custom", "search_options": {"token_filters": "lowercase,synonym",
+			"synonyms": "one, uno; two, dos; three, tres; four, cuatro; five, cinco; six, seis; seven, siete"}}
+	}}`), nil)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	config := index.DefaultConfig(t.TempDir())
+	config.Mapping = m
+	idx, err := index.New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+	idx.Index("doc1", map[string]any{"body": "uno dos tres cuatro cinco seis siete"})
+
+	s, sCleanup := createSearcher(t, idx)
+	defer sCleanup()
+
+	// Six terms with two alternatives each make 64 combinations.
+	six := &query.NearQuery{Field: "body", Terms: []string{"one", "two", "three", "four", "five", "six"}, Distance: 1}
+	if got := resultIDs(t, s, six); !slices.Equal(got, []string{"doc1"}) {
+		t.Errorf("RunQuery(%s) = %v, want [doc1]", six, got)
+	}
+
+	seven := &query.NearQuery{Field: "body", Terms: append(six.Terms, "seven"), Distance: 1}
+	if _, err := s.RunQuery(seven); err == nil {
+		t.Errorf("RunQuery(%s): expected an error for 128 combinations", seven)
+	}
+	if _, err := s.Explain(seven, "doc1"); err == nil {
+		t.Errorf("Explain(%s): expected an error for 128 combinations", seven)
+	}
+}
+
+func sortedIDs(ids []string) []string {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	return ids
+}
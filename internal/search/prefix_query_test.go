@@ -308,3 +308,14 @@ func TestPrefixQuery_MultipleMatchingTerms(t *testing.T) {
 		t.Errorf("expected doc1, got %s", results[0].DocID)
 	}
 }
+
+func TestPrefixQuery_SumsScoresOfExpandedTerms(t *testing.T) {
+	s, cleanup := createSearcher(t, createRankingIndex(t))
+	defer cleanup()
+
+	// "searchable" contains three distinct expansions of search*.
+	ids := rankedIDs(t, s, &query.PrefixQuery{Field: "body", Prefix: "search"})
+	if len(ids) != 5 || ids[0] != "searchable" || ids[1] != "search3" {
+		t.Errorf("unexpected ranking: %v", ids)
+	}
+}
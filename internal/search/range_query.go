@@ -0,0 +1,114 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"harshagw/postings/internal/mapping"
+	"harshagw/postings/internal/query"
+	"harshagw/postings/internal/segment"
+)
+
+// numericRangeSearch returns documents with a numeric value in the range.
+// Ranges are filters: every match scores rangeScore.
+func (s *Searcher) numericRangeSearch(q *query.NumericRangeQuery, c collector) error {
+	s.collectDocSet(s.numericRangeDocSet(q), rangeScore, c)
+	return nil
+}
+
+// dateRangeSearch returns documents with a date in the range.
+func (s *Searcher) dateRangeSearch(q *query.DateRangeQuery, c collector) error {
+	ds, err := s.dateRangeDocSet(q)
+	if err != nil {
+		return err
+	}
+	s.collectDocSet(ds, rangeScore, c)
+	return nil
+}
+
+// rangeScore is the constant score of a range match.
+const rangeScore = 1.0
+
+// dateRangeDocSet resolves the bounds with the field's date formats, or
+// relative to now, and runs the range over the stored milliseconds. The
+// parser makes any range with a non-numeric bound a date range, so for a
+// field not mapped as a date such a bound is invalid.
+func (s *Searcher) dateRangeDocSet(q *query.DateRangeQuery) (*docSet, error) {
+	m := s.snapshot.Mapping()
+	if m.TypeFor(q.Field) != mapping.TypeDate {
+		bound := q.Start
+		if _, err := strconv.ParseFloat(bound, 64); err == nil || bound == "" {
+			bound = q.End
+		}
+		return nil, fmt.Errorf("invalid range bound %q for field %s: not a number", bound, q.Field)
+	}
+
+	nq := &query.NumericRangeQuery{
+		Field:        q.Field,
+		MinInclusive: q.StartInclusive,
+		MaxInclusive: q.EndInclusive,
+	}
+	if q.Start != "" {
+		t, err := m.ParseDate(q.Field, q.Start)
+		if err != nil {
+			return nil, err
+		}
+		v := mapping.DateValue(t)
+		nq.Min = &v
+	}
+	if q.End != "" {
+		t, err := m.ParseDate(q.Field, q.End)
+		if err != nil {
+			return nil, err
+		}
+		v := mapping.DateValue(t)
+		nq.Max = &v
+	}
+	return s.numericRangeDocSet(nq), nil
+}
+
+// numericRangeDocSet scans the field's numeric terms in [min, max].
+func (s *Searcher) numericRangeDocSet(q *query.NumericRangeQuery) *docSet {
+	min, max := math.Inf(-1), math.Inf(1)
+	minInclusive, maxInclusive := true, true
+	if q.Min != nil {
+		min, minInclusive = *q.Min, q.MinInclusive
+	}
+	if q.Max != nil {
+		max, maxInclusive = *q.Max, q.MaxInclusive
+	}
+	start, end := segment.NumericTermRange(min, max, minInclusive, maxInclusive)
+	return s.termRangeDocSet(q.Field, string(start), string(end))
+}
+
+// termRangeDocSet returns documents with a term in [start, end) in field.
+func (s *Searcher) termRangeDocSet(field, start, end string) *docSet {
+	ds := newDocSet(s.snapshot)
+	if start >= end {
+		return ds
+	}
+
+	for i, segSnap := range s.snapshot.Segments() {
+		bm, err := segSnap.Segment().TermRangeBitmap(field, []byte(start), []byte(end), segSnap.Deleted())
+		if err != nil {
+			continue
+		}
+		ds.segmentDocs[i].docs.Or(bm)
+	}
+
+	if builder := s.snapshot.Builder(); builder != nil {
+		for term, postings := range builder.Fields[field] {
+			if term < start || term >= end {
+				continue
+			}
+			for _, p := range postings {
+				if !builder.IsDeleted(p.DocNum) {
+					ds.builderDocs.Add(uint32(p.DocNum))
+				}
+			}
+		}
+	}
+
+	return ds
+}
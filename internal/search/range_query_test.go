@@ -0,0 +1,173 @@
+package search
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"harshagw/postings/internal/index"
+	"harshagw/postings/internal/mapping"
+)
+
+func createNumericIndex(t *testing.T) *index.Index {
+	t.Helper()
+	m, err := mapping.Parse([]byte(`{"fields": {"year": {"type": "numeric"}}}`), nil)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	config := index.DefaultConfig(t.TempDir())
+	config.FlushThreshold = 10000
+	config.Mapping = m
+	idx, err := index.New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+
+	idx.Index("doc1", map[string]any{"name": "budget laptop", "price": 10.0, "year": "1999"})
+	idx.Index("doc2", map[string]any{"name": "gaming laptop", "price": 99.5, "year": 2001})
+	idx.Flush()
+	idx.Index("doc3", map[string]any{"name": "laptop stand", "price": 100, "year": "2020"})
+	idx.Index("doc4", map[string]any{"name": "refund", "price": -5})
+	return idx
+}
+
+func TestNumericRangeQuery(t *testing.T) {
+	idx := createNumericIndex(t)
+	defer idx.Close()
+
+	s, sCleanup := createSearcher(t, idx)
+	defer sCleanup()
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"price:[10 TO 100]", []string{"doc1", "doc2", "doc3"}},
+		{"price:{10 TO 100}", []string{"doc2"}},
+		{"price:[* TO 0]", []string{"doc4"}},
+		{"price:>=99.5", []string{"doc2", "doc3"}},
+		{"price:<10", []string{"doc4"}},
+		{"price:[100 TO 10]", nil},
+		{"year:>2000", []string{"doc2", "doc3"}},
+		{"year:2001", []string{"doc2"}},
+		// price is not mapped, but numbers are indexed as numeric terms.
+		{"price:10", []string{"doc1"}},
+		{"price:99.5 OR price:100", []string{"doc2", "doc3"}},
+		{"laptop AND price:[* TO 50]", []string{"doc1"}},
+		{"laptop -year:[2000 TO *]", []string{"doc1"}},
+		{"refund OR price:>99", []string{"doc2", "doc3", "doc4"}},
+	}
+
+	for _, tt := range tests {
+		results, err := s.RunQueryString(tt.query)
+		if err != nil {
+			t.Fatalf("RunQueryString(%q) error: %v", tt.query, err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.DocID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("RunQueryString(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestNumericRangeQuery_InvalidBound(t *testing.T) {
+	idx := createNumericIndex(t)
+	defer idx.Close()
+
+	s, sCleanup := createSearcher(t, idx)
+	defer sCleanup()
+
+	for _, qs := range []string{"price:[a TO 2]", "year:[1 TO b}", "price:>now"} {
+		_, err := s.RunQueryString(qs)
+		if err == nil || !strings.Contains(err.Error(), "invalid range bound") {
+			t.Errorf("RunQueryString(%q) error = %v, want an invalid range bound", qs, err)
+		}
+	}
+}
+
+func TestNumericRangeQuery_SurvivesMerge(t *testing.T) {
+	idx := createNumericIndex(t)
+	defer idx.Close()
+
+	idx.Flush()
+	if err := idx.ForceMerge(); err != nil {
+		t.Fatalf("ForceMerge error: %v", err)
+	}
+
+	s, sCleanup := createSearcher(t, idx)
+	defer sCleanup()
+
+	results, err := s.RunQueryString("price:[0 TO 99.5]")
+	if err != nil {
+		t.Fatalf("RunQueryString error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("expected 2 results after merge, got %d", len(results))
+	}
+}
+
+func TestDateRangeQuery(t *testing.T) {
+	m, err := mapping.Parse([]byte(`{"fields": {"created": {"type": "date"}}}`), nil)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	config := index.DefaultConfig(t.TempDir())
+	config.FlushThreshold = 10000
+	config.Mapping = m
+	idx, err := index.New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	now := time.Now().UTC()
+	idx.Index("old", map[string]any{"title": "report", "created": "2023-06-01T12:00:00Z"})
+	idx.Index("jan", map[string]any{"title": "report", "created": "2024-01-15"})
+	idx.Flush()
+	idx.Index("recent", map[string]any{"title": "report", "created": now.Add(-24 * time.Hour).Format(time.RFC3339)})
+	idx.Index("bad", map[string]any{"title": "report", "created": "yesterday"})
+	// Date math is for queries; as a value it would depend on when it was indexed.
+	idx.Index("relative", map[string]any{"title": "report", "created": "now-1d"})
+
+	s, sCleanup := createSearcher(t, idx)
+	defer sCleanup()
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"created:[2024-01-01 TO now-7d]", []string{"jan"}},
+		{"created:>now-7d", []string{"recent"}},
+		{"created:<2024-01-01", []string{"old"}},
+		{"created:[2023-06-01T12:00:00Z TO 2024-01-15]", []string{"jan", "old"}},
+		{"created:{2023-06-01T12:00:00Z TO 2024-01-15}", nil},
+		{"created:2024-01-15", []string{"jan"}},
+		{"report -created:[* TO now-30d]", []string{"bad", "recent", "relative"}},
+	}
+
+	for _, tt := range tests {
+		results, err := s.RunQueryString(tt.query)
+		if err != nil {
+			t.Fatalf("RunQueryString(%q) error: %v", tt.query, err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.DocID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("RunQueryString(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	for _, qs := range []string{"created:[last-week TO now]", "created:>now-99999999999999999999d"} {
+		if _, err := s.RunQueryString(qs); err == nil {
+			t.Errorf("RunQueryString(%q): expected error for invalid date bound", qs)
+		}
+	}
+}
@@ -2,47 +2,66 @@ package search
 
 import (
 	"regexp"
+	"sort"
 
 	"harshagw/postings/internal/segment"
 )
 
 // regexSearch searches for documents containing terms that match the regex pattern.
-func (s *Searcher) regexSearch(pattern, field string) ([]Result, error) {
+func (s *Searcher) regexSearch(pattern, field string, c collector) error {
+	terms, err := s.regexTerms(pattern, field)
+	if err != nil {
+		return err
+	}
+	s.multiTermSearch(terms, field, c)
+	return nil
+}
+
+// regexTerms returns the indexed terms matching the regex pattern.
+func (s *Searcher) regexTerms(pattern, field string) ([]string, error) {
 	re, err := regexp.Compile(pattern)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.automatonSearch(field,
+	return s.expandTerms(field,
 		func(seg *segment.Segment, f string) ([]string, error) {
 			return seg.MatchingTerms(pattern, f)
 		},
-		func(term string) bool {
+		func(_, term string) bool {
 			return re.MatchString(term)
 		},
-	)
+	), nil
 }
 
 // fuzzySearch searches for documents containing terms within edit distance of the query.
-func (s *Searcher) fuzzySearch(term string, fuzziness uint8, field string) ([]Result, error) {
-	return s.automatonSearch(field,
+// The term is normalized with each field's analyzer first.
+func (s *Searcher) fuzzySearch(term string, fuzziness uint8, field string, c collector) error {
+	s.multiTermSearch(s.fuzzyTerms(term, fuzziness, field), field, c)
+	return nil
+}
+
+// fuzzyTerms returns the indexed terms within edit distance of term.
+func (s *Searcher) fuzzyTerms(term string, fuzziness uint8, field string) []string {
+	return s.expandTerms(field,
 		func(seg *segment.Segment, f string) ([]string, error) {
-			return seg.FuzzyTerms(term, fuzziness, f)
+			return seg.FuzzyTerms(s.normalizeForField(term, f), fuzziness, f)
 		},
-		func(candidate string) bool {
-			return levenshteinDistance(term, candidate) <= int(fuzziness)
+		func(f, candidate string) bool {
+			return levenshteinDistance(s.normalizeForField(term, f), candidate) <= int(fuzziness)
 		},
 	)
 }
 
-// termMatcher is a function that checks if a term matches a pattern.
-type termMatcher func(term string) bool
+// termMatcher is a function that checks if a term of field matches a pattern.
+type termMatcher func(field, term string) bool
 
 // segmentTermFinder extracts matching terms from a segment for a given field.
 type segmentTermFinder func(seg *segment.Segment, field string) ([]string, error)
 
-// termSearch is a generic search that finds terms using segment and builder matchers.
-func (s *Searcher) automatonSearch(field string, segFinder segmentTermFinder, builderMatcher termMatcher) ([]Result, error) {
+// expandTerms returns the sorted, non-numeric terms of the searched fields
+// found by segFinder in segments and by builderMatcher in the builder.
+func (s *Searcher) expandTerms(field string, segFinder segmentTermFinder, builderMatcher termMatcher) []string {
 	matchingTerms := make(map[string]bool)
 	fields := s.getFieldsToSearch(field)
 
@@ -55,7 +74,9 @@ func (s *Searcher) automatonSearch(field string, segFinder segmentTermFinder, bu
 				continue
 			}
 			for _, term := range terms {
-				matchingTerms[term] = true
+				if !segment.IsNumericTerm(term) {
+					matchingTerms[term] = true
+				}
 			}
 		}
 	}
@@ -65,7 +86,7 @@ func (s *Searcher) automatonSearch(field string, segFinder segmentTermFinder, bu
 		for _, f := range fields {
 			if fieldTerms, ok := builder.Fields[f]; ok {
 				for term := range fieldTerms {
-					if builderMatcher(term) {
+					if !segment.IsNumericTerm(term) && builderMatcher(f, term) {
 						matchingTerms[term] = true
 					}
 				}
@@ -73,16 +94,12 @@ func (s *Searcher) automatonSearch(field string, segFinder segmentTermFinder, bu
 		}
 	}
 
-	if len(matchingTerms) == 0 {
-		return nil, nil
-	}
-
 	terms := make([]string, 0, len(matchingTerms))
 	for term := range matchingTerms {
 		terms = append(terms, term)
 	}
-
-	return s.multiTermSearch(terms, field), nil
+	sort.Strings(terms)
+	return terms
 }
 
 // getFieldsToSearch returns fields to search.
@@ -113,6 +130,7 @@ func (s *Searcher) getFieldsToSearch(field string) []string {
 	for f := range fieldSet {
 		fields = append(fields, f)
 	}
+	sort.Strings(fields)
 	return fields
 }
 
@@ -151,33 +169,20 @@ func (s *Searcher) termDocSet(term, field string) *docSet {
 	return ds
 }
 
-// multiTermSearch searches for multiple terms and returns results as OR of all.
-func (s *Searcher) multiTermSearch(terms []string, field string) []Result {
-	if len(terms) == 0 {
-		return nil
-	}
-	if len(terms) == 1 {
-		results, _ := s.termSearch(terms[0], field)
-		return results
-	}
-
-	// Get docSets for all terms
-	var sets []*docSet
+// multiTermSearch scores the documents containing any of terms. Each term
+// is scored with its own frequency and document frequency, and a document
+// scores the sum over the terms it contains, added in the order of terms.
+func (s *Searcher) multiTermSearch(terms []string, field string, c collector) {
+	fields := s.getFieldsToSearch(field)
+	scores := make(map[docKey]float64)
+	sum := collectorFunc(func(h hit) {
+		scores[docKey{h.segmentIdx, h.docNum}] += h.score
+	})
 	for _, term := range terms {
-		ds := s.termDocSet(term, field)
-		if !ds.IsEmpty() {
-			sets = append(sets, ds)
-		}
-	}
-
-	if len(sets) == 0 {
-		return nil
+		s.termSearch(term, fields, sum)
 	}
 
-	result := unionAll(sets)
-	if result == nil || result.IsEmpty() {
-		return nil
+	for key, score := range scores {
+		c.collect(hit{segmentIdx: key.segmentIdx, docNum: key.docNum, score: score})
 	}
-
-	return s.materializeResults(result, field)
 }
@@ -1,73 +1,131 @@
 package search
 
 import (
-	"harshagw/postings/internal/index"
-	"math"
-)
+	"slices"
 
-// BM25 scoring constants.
-const (
-	BM25_k1 = 1.2
-	BM25_b  = 0.75
+	"harshagw/postings/internal/index"
+	"harshagw/postings/internal/segment"
+	"harshagw/postings/internal/similarity"
 )
 
-func (s *Searcher) scoreAndSort(matches []searchMatch, field string) []Result {
-	totalDocs := s.snapshot.TotalDocs()
-	df := uint64(len(matches))
-
-	results := make([]Result, len(matches))
+// scoreMatches scores the matches of a term or phrase, which may include
+// several matches of a document in different fields or with different
+// alternatives of the query text. The hits are passed to c unordered.
+//
+// A document is scored with the similarity of the field of its first match.
+// Term statistics are per field and taken over the whole snapshot, so a
+// term's score in a field does not depend on which other fields were
+// searched. A phrase scores the sum over its terms.
+func (s *Searcher) scoreMatches(matches []searchMatch, c collector) {
+	stats := newStatsCache(s.snapshot)
+	for _, group := range groupMatches(matches) {
+		c.collect(hit{segmentIdx: group[0].segmentIdx, docNum: group[0].docNum, score: stats.score(stats.scored(group))})
+	}
+}
 
-	if s.snapshot.ScoringMode() == index.ScoringBM25 {
-		// Cache avg field lengths per field for multi-field searches
-		avgFieldLengthCache := make(map[string]float64)
-		getAvgFieldLength := func(f string) float64 {
-			if avg, ok := avgFieldLengthCache[f]; ok {
-				return avg
-			}
-			avg := s.snapshot.AvgFieldLength(f)
-			if avg == 0 {
-				avg = 1
-			}
-			avgFieldLengthCache[f] = avg
-			return avg
+// groupMatches groups matches by document, keeping their order.
+func groupMatches(matches []searchMatch) [][]searchMatch {
+	byDoc := make(map[docKey]int)
+	var groups [][]searchMatch
+	for _, m := range matches {
+		key := docKey{m.segmentIdx, m.docNum}
+		i, ok := byDoc[key]
+		if !ok {
+			i = len(groups)
+			byDoc[key] = i
+			groups = append(groups, nil)
 		}
+		groups[i] = append(groups[i], m)
+	}
+	return groups
+}
 
-		idf := math.Log(1 + (float64(totalDocs)-float64(df)+0.5)/(float64(df)+0.5))
+// statsCache memoizes snapshot statistics and similarities while scoring
+// one query.
+type statsCache struct {
+	snapshot     *index.IndexSnapshot
+	termStats    map[[2]string]segment.TermStats // field, term -> stats
+	similarities map[string]similarity.Similarity
+}
 
-		for i, m := range matches {
-			// Use the field from the match, fall back to provided field
-			matchField := m.field
-			if matchField == "" {
-				matchField = field
-			}
-			avgFieldLength := getAvgFieldLength(matchField)
+func newStatsCache(snapshot *index.IndexSnapshot) *statsCache {
+	return &statsCache{
+		snapshot:     snapshot,
+		termStats:    make(map[[2]string]segment.TermStats),
+		similarities: make(map[string]similarity.Similarity),
+	}
+}
 
-			fieldLen := float64(m.fieldLength)
-			if fieldLen == 0 {
-				fieldLen = avgFieldLength
-			}
-			tf := m.tf
-			score := idf * (tf * (BM25_k1 + 1)) / (tf + BM25_k1*(1-BM25_b+BM25_b*fieldLen/avgFieldLength))
-			results[i] = Result{
-				DocID: m.docID,
-				Score: score,
-			}
+func (c *statsCache) similarity(field string) similarity.Similarity {
+	sim, ok := c.similarities[field]
+	if !ok {
+		sim = c.snapshot.SimilarityFor(field)
+		c.similarities[field] = sim
+	}
+	return sim
+}
+
+// scored returns the matches of a document that are scored: the first one
+// and, if its field's similarity scores several fields together, the first
+// match of the same terms in each other field with that similarity.
+func (c *statsCache) scored(group []searchMatch) []searchMatch {
+	first := group[0]
+	sim := c.similarity(first.field)
+	if _, ok := sim.(similarity.MultiFieldSimilarity); !ok {
+		return group[:1]
+	}
+	out := group[:1:1]
+	for _, m := range group[1:] {
+		if c.similarity(m.field) != sim || !slices.Equal(m.terms, first.terms) {
+			continue
+		}
+		if !slices.ContainsFunc(out, func(o searchMatch) bool { return o.field == m.field }) {
+			out = append(out, m)
 		}
-	} else {
-		idf := math.Log(float64(totalDocs+1)/float64(df+1)) + 1.0
-		for i, m := range matches {
-			var tf float64
-			if m.tf > 0 {
-				tf = 1.0 + math.Log(m.tf)
-			}
-			score := tf * idf
-			results[i] = Result{
-				DocID: m.docID,
-				Score: score,
-			}
+	}
+	return out
+}
+
+// score scores the matches returned by scored, summing over the terms.
+func (c *statsCache) score(matches []searchMatch) float64 {
+	sim := c.similarity(matches[0].field)
+	multi, isMulti := sim.(similarity.MultiFieldSimilarity)
+	var score float64
+	for i := range matches[0].terms {
+		if isMulti {
+			score += multi.ScoreFields(c.fieldsStats(matches, i))
+		} else {
+			score += sim.Score(c.stats(matches[0], i))
 		}
 	}
+	return score
+}
+
+// stats returns the statistics of the i-th term of a match.
+func (c *statsCache) stats(m searchMatch, i int) similarity.Stats {
+	key := [2]string{m.field, m.terms[i]}
+	ts, ok := c.termStats[key]
+	if !ok {
+		ts = c.snapshot.TermStats(m.field, m.terms[i])
+		c.termStats[key] = ts
+	}
+	fs := c.snapshot.FieldStats(m.field)
+	return similarity.Stats{
+		Field:            m.field,
+		DocFreq:          float64(ts.DocFreq),
+		TotalTermFreq:    float64(ts.TotalTermFreq),
+		DocCount:         float64(fs.DocCount),
+		SumTotalTermFreq: float64(fs.SumTotalTermFreq),
+		Freq:             m.tf,
+		FieldLength:      float64(m.fieldLength),
+	}
+}
 
-	sortByScore(results)
-	return results
+// fieldsStats returns the statistics of the i-th term of each match.
+func (c *statsCache) fieldsStats(matches []searchMatch, i int) []similarity.Stats {
+	out := make([]similarity.Stats, len(matches))
+	for j, m := range matches {
+		out[j] = c.stats(m, i)
+	}
+	return out
 }
@@ -5,7 +5,9 @@ import (
 	"testing"
 
 	"harshagw/postings/internal/index"
+	"harshagw/postings/internal/mapping"
 	"harshagw/postings/internal/query"
+	"harshagw/postings/internal/similarity"
 )
 
 
@@ -615,11 +617,148 @@ func TestScoring_ManyDocuments(t *testing.T) {
 // ============ BM25 Parameter Verification ============
 
 func TestBM25_UsesCorrectConstants(t *testing.T) {
-	// Verify the constants are set correctly
-	if BM25_k1 != 1.2 {
-		t.Errorf("BM25_k1 should be 1.2, got %f", BM25_k1)
+	// The default similarity is BM25 with the usual parameters
+	idx, err := index.New(index.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+	snapshot, err := idx.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot error: %v", err)
+	}
+	defer snapshot.Close()
+
+	bm25, ok := snapshot.SimilarityFor("body").(*similarity.BM25)
+	if !ok {
+		t.Fatalf("expected BM25, got %v", snapshot.SimilarityFor("body"))
+	}
+	if bm25.K1 != 1.2 {
+		t.Errorf("k1 should be 1.2, got %f", bm25.K1)
+	}
+	if bm25.B != 0.75 {
+		t.Errorf("b should be 0.75, got %f", bm25.B)
+	}
+}
+
+// ============ Term Statistics ============
+
+func TestBM25_IDFIsPerField(t *testing.T) {
+	dir := t.TempDir()
+	config := index.DefaultConfig(dir)
+	config.ScoringMode = index.ScoringBM25
+
+	idx, err := index.New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"title": "go"})
+	idx.Index("doc2", map[string]any{"body": "go"})
+	idx.Flush()
+	idx.Index("doc3", map[string]any{"title": "rust"})
+
+	snapshot, err := idx.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot error: %v", err)
+	}
+	defer snapshot.Close()
+
+	s := New(snapshot)
+	defer s.Close()
+
+	scoreOf := func(q query.Query, docID string) float64 {
+		results, err := s.RunQuery(q)
+		if err != nil {
+			t.Fatalf("RunQuery error: %v", err)
+		}
+		for _, r := range results {
+			if r.DocID == docID {
+				return r.Score
+			}
+		}
+		t.Fatalf("%s not found for %v", docID, q)
+		return 0
+	}
+
+	// Two of the three docs have a title and one of those contains "go".
+	want := math.Log(2)
+	fielded := scoreOf(&query.TermQuery{Field: "title", Term: "go"}, "doc1")
+	unfielded := scoreOf(&query.TermQuery{Term: "go"}, "doc1")
+	if math.Abs(fielded-want) > 1e-9 || math.Abs(unfielded-want) > 1e-9 {
+		t.Errorf("title:go scored %f, go scored %f, want %f for both", fielded, unfielded, want)
+	}
+}
+
+// ============ Similarities ============
+
+func TestSimilarity_PerField(t *testing.T) {
+	config := index.DefaultConfig(t.TempDir())
+	config.Mapping = &mapping.IndexMapping{
+		Fields: map[string]mapping.FieldMapping{
+			"tags": {Similarity: "constant", SimilarityOptions: map[string]string{"score": "0.5"}},
+		},
+	}
+	idx, err := index.New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"tags": "go go go", "body": "go"})
+	idx.Index("doc2", map[string]any{"tags": "go rust", "body": "rust"})
+
+	s, cleanup := createSearcher(t, idx)
+	defer cleanup()
+
+	results, err := s.RunQuery(&query.TermQuery{Field: "tags", Term: "go"})
+	if err != nil {
+		t.Fatalf("RunQuery error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Score != 0.5 {
+			t.Errorf("expected constant score 0.5 for %s, got %f", r.DocID, r.Score)
+		}
+	}
+
+	results, err = s.RunQuery(&query.TermQuery{Field: "body", Term: "go"})
+	if err != nil {
+		t.Fatalf("RunQuery error: %v", err)
+	}
+	if len(results) != 1 || results[0].Score == 0.5 {
+		t.Errorf("expected body to keep the default similarity, got %+v", results)
+	}
+}
+
+func TestSimilarity_BM25FCombinesFields(t *testing.T) {
+	config := index.DefaultConfig(t.TempDir())
+	config.Similarity = similarity.NewBM25F(map[string]float64{"title": 3})
+	idx, err := index.New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	idx.Index("both", map[string]any{"title": "go", "body": "go"})
+	idx.Index("title", map[string]any{"title": "go", "body": "rust"})
+	idx.Index("body", map[string]any{"title": "rust", "body": "go"})
+
+	s, cleanup := createSearcher(t, idx)
+	defer cleanup()
+
+	results, err := s.RunQuery(&query.TermQuery{Term: "go"})
+	if err != nil {
+		t.Fatalf("RunQuery error: %v", err)
+	}
+	var order []string
+	for _, r := range results {
+		order = append(order, r.DocID)
 	}
-	if BM25_b != 0.75 {
-		t.Errorf("BM25_b should be 0.75, got %f", BM25_b)
+	if len(order) != 3 || order[0] != "both" || order[1] != "title" || order[2] != "body" {
+		t.Errorf("expected both, title, body; got %v", order)
 	}
 }
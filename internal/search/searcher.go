@@ -2,31 +2,92 @@ package search
 
 import (
 	"fmt"
+	"math"
+
 	"harshagw/postings/internal/index"
 	"harshagw/postings/internal/query"
 )
 
-// Result represents a search hit with score.
+// Result represents a search hit with score. Doc, MatchedTerms and
+// Fragments are filled in by Highlight.
 type Result struct {
 	DocID        string
 	Score        float64
 	Doc          map[string]any
 	MatchedTerms []string
+	Fragments    map[string][]string // field -> highlighted snippets, best first
 }
 
 // Searcher performs searches on an index snapshot.
 type Searcher struct {
 	snapshot *index.IndexSnapshot
+	closed   bool
 }
 
 // New creates a new searcher for a snapshot.
+// The searcher holds its own reference on the snapshot until Close.
 func New(snapshot *index.IndexSnapshot) *Searcher {
+	snapshot.AddRef()
 	return &Searcher{snapshot: snapshot}
 }
 
 // Close releases searcher resources.
 func (s *Searcher) Close() error {
-	return nil
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.snapshot.DecRef()
+}
+
+// DefaultSize is the number of hits a SearchRequest returns by default.
+const DefaultSize = 10
+
+// SearchRequest is a query and the page of hits to return.
+type SearchRequest struct {
+	Query     query.Query
+	From      int               // hits to skip
+	Size      int               // hits to return; 0 means DefaultSize
+	Highlight *HighlightOptions // highlights the returned hits if set
+}
+
+// SearchResponse is one page of hits.
+type SearchResponse struct {
+	Total uint64   // number of matching documents
+	Hits  []Result // best first
+}
+
+// Search runs a request. Hits go to a collector as they are scored, which
+// keeps only the best From+Size, and only the returned hits are resolved to
+// external IDs.
+func (s *Searcher) Search(req SearchRequest) (*SearchResponse, error) {
+	if req.From < 0 || req.Size < 0 {
+		return nil, fmt.Errorf("invalid page: from %d, size %d", req.From, req.Size)
+	}
+	size := req.Size
+	if size == 0 {
+		size = DefaultSize
+	}
+
+	// Avoid overflowing From+Size.
+	top := newTopK(req.From + min(size, math.MaxInt-req.From))
+	if err := s.run(req.Query, top); err != nil {
+		return nil, err
+	}
+	page := top.sorted()
+	page = page[min(req.From, len(page)):]
+
+	results, err := s.resolve(page)
+	if err != nil {
+		return nil, err
+	}
+	resp := &SearchResponse{Total: top.total, Hits: results}
+	if req.Highlight != nil {
+		if err := s.Highlight(req.Query, resp.Hits, *req.Highlight); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
 }
 
 // RunQueryString parses and executes a query string.
@@ -41,33 +102,82 @@ func (s *Searcher) RunQueryString(queryString string) ([]Result, error) {
 		return nil, err
 	}
 
-	return s.execute(ast)
+	return s.RunQuery(ast)
 }
 
-// RunQuery executes a pre-parsed query AST.
+// RunQuery executes a pre-parsed query AST and returns every hit, best
+// first. Use Search to fetch a page.
 func (s *Searcher) RunQuery(q query.Query) ([]Result, error) {
-	return s.execute(q)
+	all := newTopK(math.MaxInt)
+	if err := s.run(q, all); err != nil {
+		return nil, err
+	}
+	return s.resolve(all.sorted())
 }
 
-// execute executes a query AST and returns the results.
-func (s *Searcher) execute(q query.Query) ([]Result, error) {
-	if q == nil {
+// resolve looks up the external IDs of hits.
+func (s *Searcher) resolve(hits []hit) ([]Result, error) {
+	if len(hits) == 0 {
 		return nil, nil
 	}
+	segments := s.snapshot.Segments()
+	results := make([]Result, 0, len(hits))
+	for _, h := range hits {
+		var id string
+		if h.segmentIdx < 0 {
+			id = s.snapshot.Builder().DocIDs[h.docNum]
+		} else {
+			var ok bool
+			if id, ok = segments[h.segmentIdx].Segment().ExternalID(h.docNum); !ok {
+				return nil, fmt.Errorf("no external ID for document %d in segment %d", h.docNum, h.segmentIdx)
+			}
+		}
+		results = append(results, Result{DocID: id, Score: h.score})
+	}
+	return results, nil
+}
+
+// execute executes a query AST and returns its unordered hits.
+func (s *Searcher) execute(q query.Query) ([]hit, error) {
+	var hits hitList
+	if err := s.run(q, &hits); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// run executes a query AST, passing its hits to c as they are scored.
+func (s *Searcher) run(q query.Query, c collector) error {
+	if q == nil {
+		return nil
+	}
 	switch v := q.(type) {
 	case *query.TermQuery:
-		return s.termSearch(v.Term, v.Field)
+		return s.termQuerySearch(v.Term, v.Field, c)
 	case *query.PhraseQuery:
-		return s.phraseSearch(v.Phrase, v.Field)
+		return s.phraseSearch(v.Phrase, v.Slop, v.Field, c)
 	case *query.PrefixQuery:
-		return s.prefixSearch(v.Prefix, v.Field)
+		return s.prefixSearch(v.Prefix, v.Field, c)
 	case *query.RegexQuery:
-		return s.regexSearch(v.Pattern, v.Field)
+		return s.regexSearch(v.Pattern, v.Field, c)
 	case *query.FuzzyQuery:
-		return s.fuzzySearch(v.Term, v.Fuzziness, v.Field)
+		return s.fuzzySearch(v.Term, v.Fuzziness, v.Field, c)
+	case *query.NumericRangeQuery:
+		return s.numericRangeSearch(v, c)
+	case *query.DateRangeQuery:
+		return s.dateRangeSearch(v, c)
 	case *query.BoolQuery:
-		return s.boolSearch(v)
+		return s.boolSearch(v, c)
+	case *query.NearQuery:
+		return s.nearSearch(v, c)
+	case *query.MultiMatchQuery:
+		s.multiMatchSearch(v, c)
+		return nil
+	case *query.BoostQuery:
+		return s.boostSearch(v, c)
+	case query.SpanQuery:
+		return s.spanSearch(v, c)
 	default:
-		return nil, fmt.Errorf("unknown query type: %T", q)
+		return fmt.Errorf("unknown query type: %T", q)
 	}
 }
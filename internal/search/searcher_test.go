@@ -1,9 +1,14 @@
 package search
 
 import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
 	"testing"
 
 	"harshagw/postings/internal/index"
+	"harshagw/postings/internal/query"
 )
 
 // createTestSnapshot creates a test index snapshot with sample documents.
@@ -447,3 +452,160 @@ func TestSearcher_MultipleQueries(t *testing.T) {
 		}
 	}
 }
+
+func TestE2E_NestedFields(t *testing.T) {
+	idx, err := index.New(index.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{
+		"title":  "Notes",
+		"author": map[string]any{"name": "Ada Lovelace", "born": 1815},
+	})
+	idx.Flush()
+	idx.Index("doc2", map[string]any{
+		"title":   "Letters",
+		"author":  map[string]any{"name": "Charles Babbage", "born": 1791},
+		"reviews": []any{map[string]any{"by": "ada"}},
+	})
+
+	s, sCleanup := createSearcher(t, idx)
+	defer sCleanup()
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"author.name:lovelace", []string{"doc1"}},
+		{"author.born:<1800", []string{"doc2"}},
+		{"reviews.by:ada", []string{"doc2"}},
+		{"ada", []string{"doc1", "doc2"}},
+		{`author.name:"charles babbage" AND title:letters`, []string{"doc2"}},
+	}
+	for _, tt := range tests {
+		results, err := s.RunQueryString(tt.query)
+		if err != nil {
+			t.Fatalf("RunQueryString(%q) error: %v", tt.query, err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.DocID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("RunQueryString(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+// ============ Paged Search Tests ============
+
+func TestSearch_PagesThroughHits(t *testing.T) {
+	dir := t.TempDir()
+	idx, err := index.New(index.DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	// Fewer repetitions of "go" in a longer body score lower.
+	for i := 0; i < 25; i++ {
+		body := "go" + strings.Repeat(" filler", i)
+		if err := idx.Index(fmt.Sprintf("doc%02d", i), map[string]any{"body": body}); err != nil {
+			t.Fatalf("Index error: %v", err)
+		}
+		if i == 12 {
+			idx.Flush()
+		}
+	}
+
+	s, cleanup := createSearcher(t, idx)
+	defer cleanup()
+
+	all, err := s.RunQueryString("go")
+	if err != nil {
+		t.Fatalf("RunQueryString error: %v", err)
+	}
+
+	var paged []Result
+	for from := 0; ; from += 10 {
+		resp, err := s.Search(SearchRequest{Query: &query.TermQuery{Term: "go"}, From: from})
+		if err != nil {
+			t.Fatalf("Search error: %v", err)
+		}
+		if resp.Total != 25 {
+			t.Fatalf("Total = %d, want 25", resp.Total)
+		}
+		if len(resp.Hits) == 0 {
+			break
+		}
+		paged = append(paged, resp.Hits...)
+	}
+	if !slices.EqualFunc(paged, all, func(a, b Result) bool { return a.DocID == b.DocID && a.Score == b.Score }) {
+		t.Errorf("pages differ from the full result list:\n%v\n%v", paged, all)
+	}
+	if all[0].DocID != "doc00" || all[24].DocID != "doc24" {
+		t.Errorf("unexpected order: first %s, last %s", all[0].DocID, all[24].DocID)
+	}
+}
+
+func TestSearch_LargePage(t *testing.T) {
+	snapshot := createTestSnapshot(t)
+	defer snapshot.Close()
+	s := New(snapshot)
+	defer s.Close()
+
+	for _, req := range []SearchRequest{
+		{Query: &query.TermQuery{Term: "go"}, From: math.MaxInt},
+		{Query: &query.TermQuery{Term: "go"}, From: 1, Size: math.MaxInt},
+	} {
+		resp, err := s.Search(req)
+		if err != nil {
+			t.Fatalf("Search(from %d, size %d) error: %v", req.From, req.Size, err)
+		}
+		if want := max(int(resp.Total)-req.From, 0); len(resp.Hits) != want {
+			t.Errorf("Search(from %d, size %d) returned %d hits, want %d", req.From, req.Size, len(resp.Hits), want)
+		}
+	}
+}
+
+func TestSearch_UnresolvableHit(t *testing.T) {
+	idx, cleanup := createTestIndex(t)
+	defer cleanup()
+	idx.Flush()
+
+	s, sCleanup := createSearcher(t, idx)
+	defer sCleanup()
+
+	if _, err := s.resolve([]hit{{segmentIdx: 0, docNum: 1000, score: 1}}); err == nil {
+		t.Error("expected an error for a hit without an external ID")
+	}
+}
+
+func TestSearch_SizeAndHighlight(t *testing.T) {
+	snapshot := createTestSnapshot(t)
+	defer snapshot.Close()
+	s := New(snapshot)
+	defer s.Close()
+
+	resp, err := s.Search(SearchRequest{
+		Query:     &query.TermQuery{Term: "go"},
+		Size:      1,
+		Highlight: &HighlightOptions{},
+	})
+	if err != nil {
+		t.Fatalf("Search error: %v", err)
+	}
+	if resp.Total != 2 || len(resp.Hits) != 1 {
+		t.Fatalf("got total %d with %d hits, want 2 with 1", resp.Total, len(resp.Hits))
+	}
+	if resp.Hits[0].Doc == nil || len(resp.Hits[0].Fragments) == 0 {
+		t.Errorf("hit not highlighted: %+v", resp.Hits[0])
+	}
+
+	if _, err := s.Search(SearchRequest{Query: &query.TermQuery{Term: "go"}, From: -1}); err == nil {
+		t.Error("expected an error for a negative From")
+	}
+}
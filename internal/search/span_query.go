@@ -0,0 +1,600 @@
+package search
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/RoaringBitmap/roaring"
+
+	"harshagw/postings/internal/query"
+	"harshagw/postings/internal/segment"
+)
+
+// spanSearch matches a span query in each field it searches. A document
+// scores like a phrase of the query's terms it contains, not counting
+// excluded ones, with a frequency adding up 1 / (1 + width) over its spans,
+// where width is how many positions the clauses of a span are apart.
+func (s *Searcher) spanSearch(q query.SpanQuery, c collector) error {
+	fields, err := s.spanFields(q)
+	if err != nil {
+		return err
+	}
+
+	var matches []searchMatch
+	for _, f := range fields {
+		sq, err := s.compileSpan(q, f)
+		if err != nil {
+			return err
+		}
+		segments := s.snapshot.Segments()
+		for i := len(segments) - 1; i >= 0; i-- {
+			matches = append(matches, s.newSpanSource(i, f).matches(sq)...)
+		}
+		if s.snapshot.Builder() != nil {
+			matches = append(matches, s.newSpanSource(-1, f).matches(sq)...)
+		}
+	}
+	s.scoreMatches(matches, c)
+	return nil
+}
+
+// spanFields returns the fields q searches: the one its leaves name, or
+// every field if they name none.
+func (s *Searcher) spanFields(q query.SpanQuery) ([]string, error) {
+	leaves := spanLeafFields(q)
+	for _, f := range leaves[min(1, len(leaves)):] {
+		if f != leaves[0] {
+			return nil, fmt.Errorf("span query %s searches fields %q and %q, want one field", q, leaves[0], f)
+		}
+	}
+	var field string
+	if len(leaves) > 0 {
+		field = leaves[0]
+	}
+	return s.getFieldsToSearch(field), nil
+}
+
+// spanLeafFields returns the field of each leaf of q.
+func spanLeafFields(q query.SpanQuery) []string {
+	switch v := q.(type) {
+	case *query.SpanTermQuery:
+		return []string{v.Field}
+	case *query.SpanMultiQuery:
+		switch mq := v.Query.(type) {
+		case *query.PrefixQuery:
+			return []string{mq.Field}
+		case *query.RegexQuery:
+			return []string{mq.Field}
+		case *query.FuzzyQuery:
+			return []string{mq.Field}
+		}
+	case *query.SpanNearQuery:
+		var fields []string
+		for _, c := range v.Clauses {
+			fields = append(fields, spanLeafFields(c)...)
+		}
+		return fields
+	case *query.SpanOrQuery:
+		var fields []string
+		for _, c := range v.Clauses {
+			fields = append(fields, spanLeafFields(c)...)
+		}
+		return fields
+	case *query.SpanNotQuery:
+		return append(spanLeafFields(v.Include), spanLeafFields(v.Exclude)...)
+	case *query.SpanFirstQuery:
+		return spanLeafFields(v.Match)
+	}
+	return nil
+}
+
+// compileSpan resolves the leaves of q to the terms they match in field.
+func (s *Searcher) compileSpan(q query.SpanQuery, field string) (spanQuery, error) {
+	switch v := q.(type) {
+	case nil:
+		return nil, fmt.Errorf("missing span clause")
+	case *query.SpanTermQuery:
+		return &spanTerms{terms: []string{s.normalizeForField(v.Term, field)}}, nil
+	case *query.SpanMultiQuery:
+		var terms []string
+		switch mq := v.Query.(type) {
+		case *query.PrefixQuery:
+			terms = s.prefixTerms(mq.Prefix, field)
+		case *query.RegexQuery:
+			var err error
+			if terms, err = s.regexTerms(mq.Pattern, field); err != nil {
+				return nil, err
+			}
+		case *query.FuzzyQuery:
+			terms = s.fuzzyTerms(mq.Term, mq.Fuzziness, field)
+		default:
+			return nil, fmt.Errorf("span_multi needs a prefix, regex or fuzzy query, got %T", v.Query)
+		}
+		return &spanTerms{terms: terms}, nil
+	case *query.SpanNearQuery:
+		if len(v.Clauses) == 0 {
+			return nil, fmt.Errorf("span_near needs at least one clause")
+		}
+		if v.Slop < 0 {
+			return nil, fmt.Errorf("invalid span_near slop: %d", v.Slop)
+		}
+		clauses, err := s.compileSpans(v.Clauses, field)
+		if err != nil {
+			return nil, err
+		}
+		return &spanNear{clauses: clauses, slop: v.Slop, inOrder: v.InOrder}, nil
+	case *query.SpanOrQuery:
+		clauses, err := s.compileSpans(v.Clauses, field)
+		if err != nil {
+			return nil, err
+		}
+		return &spanOr{clauses: clauses}, nil
+	case *query.SpanNotQuery:
+		if v.Pre < 0 || v.Post < 0 {
+			return nil, fmt.Errorf("invalid span_not window: pre %d, post %d", v.Pre, v.Post)
+		}
+		clauses, err := s.compileSpans([]query.SpanQuery{v.Include, v.Exclude}, field)
+		if err != nil {
+			return nil, err
+		}
+		return &spanNot{include: clauses[0], exclude: clauses[1], pre: uint64(v.Pre), post: uint64(v.Post)}, nil
+	case *query.SpanFirstQuery:
+		if v.End < 0 {
+			return nil, fmt.Errorf("invalid span_first end: %d", v.End)
+		}
+		match, err := s.compileSpan(v.Match, field)
+		if err != nil {
+			return nil, err
+		}
+		return &spanFirst{match: match, end: uint64(v.End)}, nil
+	default:
+		return nil, fmt.Errorf("unknown span query type: %T", q)
+	}
+}
+
+func (s *Searcher) compileSpans(clauses []query.SpanQuery, field string) ([]spanQuery, error) {
+	out := make([]spanQuery, len(clauses))
+	for i, c := range clauses {
+		sq, err := s.compileSpan(c, field)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = sq
+	}
+	return out, nil
+}
+
+// spanSource reads the positions of terms in one field of a segment, or of
+// the builder if segmentIdx is -1, caching them per term.
+type spanSource struct {
+	s          *Searcher
+	segmentIdx int
+	field      string
+	positions  map[string]map[uint64][]uint64 // term -> live doc -> positions
+}
+
+func (s *Searcher) newSpanSource(segmentIdx int, field string) *spanSource {
+	return &spanSource{s: s, segmentIdx: segmentIdx, field: field, positions: make(map[string]map[uint64][]uint64)}
+}
+
+// termPositions returns the positions of term in each live document.
+func (src *spanSource) termPositions(term string) map[uint64][]uint64 {
+	if docs, ok := src.positions[term]; ok {
+		return docs
+	}
+
+	var postings []segment.Posting
+	deleted := func(uint64) bool { return false }
+	if src.segmentIdx < 0 {
+		builder := src.s.snapshot.Builder()
+		postings = builder.Fields[src.field][term]
+		deleted = builder.IsDeleted
+	} else {
+		postings, _ = src.s.snapshot.Segments()[src.segmentIdx].Search(term, src.field)
+	}
+
+	docs := make(map[uint64][]uint64, len(postings))
+	for _, p := range postings {
+		if !deleted(p.DocNum) {
+			docs[p.DocNum] = p.Positions
+		}
+	}
+	src.positions[term] = docs
+	return docs
+}
+
+func (src *spanSource) fieldLength(docNum uint64) uint64 {
+	if src.segmentIdx < 0 {
+		return src.s.snapshot.Builder().FieldLength(src.field, docNum)
+	}
+	return src.s.snapshot.Segments()[src.segmentIdx].Segment().FieldLength(src.field, docNum)
+}
+
+// matches returns the documents of the source with a span of sq.
+func (src *spanSource) matches(sq spanQuery) []searchMatch {
+	var matches []searchMatch
+	it := sq.docs(src).Iterator()
+	for it.HasNext() {
+		docNum := uint64(it.Next())
+		if freq, terms := spanFreq(sq, src, docNum); freq > 0 {
+			matches = append(matches, searchMatch{
+				segmentIdx:  src.segmentIdx,
+				docNum:      docNum,
+				tf:          freq,
+				fieldLength: src.fieldLength(docNum),
+				field:       src.field,
+				terms:       terms,
+			})
+		}
+	}
+	return matches
+}
+
+// spanFreq returns the frequency of sq in a document, adding 1 / (1 + width)
+// over its spans, and the distinct terms of sq the document contains that
+// are not excluded. The frequency is 0 if the document has no span.
+func spanFreq(sq spanQuery, src *spanSource, docNum uint64) (float64, []string) {
+	var freq float64
+	it := sq.spans(src, docNum)
+	for it.next() {
+		freq += 1 / float64(1+it.current().width)
+	}
+	if freq == 0 {
+		return 0, nil
+	}
+
+	var terms []string
+	for _, term := range sq.docTerms(src, docNum, nil) {
+		if !slices.Contains(terms, term) {
+			terms = append(terms, term)
+		}
+	}
+	return freq, terms
+}
+
+// spanQuery is a span query compiled for one field.
+type spanQuery interface {
+	// docs returns the documents of src that may have a span.
+	docs(src *spanSource) *roaring.Bitmap
+	// spans iterates over the spans of a document.
+	spans(src *spanSource, docNum uint64) spans
+	// docTerms appends the terms of the query in a document, except excluded ones.
+	docTerms(src *spanSource, docNum uint64, out []string) []string
+}
+
+// span is the run of positions [start, end). width is how many positions
+// apart its clauses are, counting those of nested clauses.
+type span struct {
+	start, end uint64
+	width      int
+}
+
+func compareSpans(a, b span) int {
+	return cmp.Or(cmp.Compare(a.start, b.start), cmp.Compare(a.end, b.end))
+}
+
+// spans iterates over the spans of one document in order of start. next
+// advances to the next span and reports whether there is one.
+type spans interface {
+	next() bool
+	current() span
+}
+
+// started advances each of subs to its first span and returns those that
+// have one.
+func started(subs []spans) []spans {
+	out := subs[:0]
+	for _, sub := range subs {
+		if sub.next() {
+			out = append(out, sub)
+		}
+	}
+	return out
+}
+
+// collectSpans returns the remaining spans of it, sorted.
+func collectSpans(it spans) []span {
+	var out []span
+	for it.next() {
+		out = append(out, it.current())
+	}
+	slices.SortFunc(out, compareSpans)
+	return out
+}
+
+// spanTerms matches each position of any of its terms.
+type spanTerms struct {
+	terms []string
+}
+
+func (q *spanTerms) docs(src *spanSource) *roaring.Bitmap {
+	docs := roaring.New()
+	for _, term := range q.terms {
+		for docNum := range src.termPositions(term) {
+			docs.Add(uint32(docNum))
+		}
+	}
+	return docs
+}
+
+func (q *spanTerms) spans(src *spanSource, docNum uint64) spans {
+	var positions []uint64
+	for _, term := range q.terms {
+		positions = append(positions, src.termPositions(term)[docNum]...)
+	}
+	if len(q.terms) > 1 {
+		slices.Sort(positions)
+		positions = slices.Compact(positions)
+	}
+	return &termSpans{positions: positions, i: -1}
+}
+
+func (q *spanTerms) docTerms(src *spanSource, docNum uint64, out []string) []string {
+	for _, term := range q.terms {
+		if _, ok := src.termPositions(term)[docNum]; ok {
+			out = append(out, term)
+		}
+	}
+	return out
+}
+
+type termSpans struct {
+	positions []uint64
+	i         int
+}
+
+func (t *termSpans) next() bool {
+	t.i++
+	return t.i < len(t.positions)
+}
+
+func (t *termSpans) current() span {
+	pos := t.positions[t.i]
+	return span{start: pos, end: pos + 1}
+}
+
+// spanOr matches the spans of any of its clauses.
+type spanOr struct {
+	clauses []spanQuery
+}
+
+func (q *spanOr) docs(src *spanSource) *roaring.Bitmap {
+	docs := make([]*roaring.Bitmap, len(q.clauses))
+	for i, c := range q.clauses {
+		docs[i] = c.docs(src)
+	}
+	return roaring.FastOr(docs...)
+}
+
+func (q *spanOr) spans(src *spanSource, docNum uint64) spans {
+	subs := make([]spans, len(q.clauses))
+	for i, c := range q.clauses {
+		subs[i] = c.spans(src, docNum)
+	}
+	return &orSpans{subs: started(subs)}
+}
+
+func (q *spanOr) docTerms(src *spanSource, docNum uint64, out []string) []string {
+	for _, c := range q.clauses {
+		out = c.docTerms(src, docNum, out)
+	}
+	return out
+}
+
+// orSpans merges the spans of its clauses.
+type orSpans struct {
+	subs []spans // the clauses with spans left, each on its next one
+	cur  span
+}
+
+func (o *orSpans) next() bool {
+	if len(o.subs) == 0 {
+		return false
+	}
+	first := 0
+	for i := range o.subs {
+		if compareSpans(o.subs[i].current(), o.subs[first].current()) < 0 {
+			first = i
+		}
+	}
+	o.cur = o.subs[first].current()
+	if !o.subs[first].next() {
+		o.subs = slices.Delete(o.subs, first, first+1)
+	}
+	return true
+}
+
+func (o *orSpans) current() span { return o.cur }
+
+// spanNear matches a span of each clause with at most slop positions
+// between them in total.
+type spanNear struct {
+	clauses []spanQuery
+	slop    int
+	inOrder bool
+}
+
+func (q *spanNear) docs(src *spanSource) *roaring.Bitmap {
+	docs := make([]*roaring.Bitmap, len(q.clauses))
+	for i, c := range q.clauses {
+		docs[i] = c.docs(src)
+	}
+	return roaring.FastAnd(docs...)
+}
+
+func (q *spanNear) spans(src *spanSource, docNum uint64) spans {
+	subs := make([]spans, len(q.clauses))
+	for i, c := range q.clauses {
+		subs[i] = c.spans(src, docNum)
+	}
+	if q.inOrder {
+		rest := make([][]span, len(subs)-1)
+		for i, sub := range subs[1:] {
+			rest[i] = collectSpans(sub)
+		}
+		return &orderedSpans{first: subs[0], rest: rest, slop: q.slop}
+	}
+
+	u := &unorderedSpans{subs: started(slices.Clone(subs)), slop: q.slop}
+	u.done = len(u.subs) < len(subs)
+	return u
+}
+
+func (q *spanNear) docTerms(src *spanSource, docNum uint64, out []string) []string {
+	for _, c := range q.clauses {
+		out = c.docTerms(src, docNum, out)
+	}
+	return out
+}
+
+// orderedSpans matches the clauses in order. As in Lucene, each span of the
+// first clause is followed, for every other clause in turn, by the clause's
+// first span that starts where the previous one ends or later.
+type orderedSpans struct {
+	first spans
+	rest  [][]span // the sorted spans of the other clauses
+	slop  int
+	cur   span
+}
+
+func (o *orderedSpans) next() bool {
+	for o.first.next() {
+		if sp, ok := o.stretch(o.first.current()); ok {
+			o.cur = sp
+			return true
+		}
+	}
+	return false
+}
+
+func (o *orderedSpans) current() span { return o.cur }
+
+// stretch extends a span of the first clause over the other clauses.
+func (o *orderedSpans) stretch(first span) (span, bool) {
+	match, gaps := first, 0
+	for _, candidates := range o.rest {
+		i, _ := slices.BinarySearchFunc(candidates, match.end, func(sp span, pos uint64) int {
+			return cmp.Compare(sp.start, pos)
+		})
+		if i == len(candidates) {
+			return span{}, false
+		}
+		next := candidates[i]
+		if gaps += int(next.start - match.end); gaps > o.slop {
+			return span{}, false
+		}
+		match = span{start: match.start, end: next.end, width: match.width + next.width}
+	}
+	match.width += gaps
+	return match, true
+}
+
+// unorderedSpans matches the clauses in any order. Each clause is on one
+// span; while they do not match, the one starting first moves on.
+type unorderedSpans struct {
+	subs []spans
+	slop int
+	done bool // some clause has no spans left
+	cur  span
+}
+
+func (u *unorderedSpans) next() bool {
+	for !u.done {
+		match, ok := u.match()
+		first := 0
+		for i := range u.subs {
+			if compareSpans(u.subs[i].current(), u.subs[first].current()) < 0 {
+				first = i
+			}
+		}
+		u.done = !u.subs[first].next()
+		if ok {
+			u.cur = match
+			return true
+		}
+	}
+	return false
+}
+
+func (u *unorderedSpans) current() span { return u.cur }
+
+// match reports whether the current spans of the clauses, taken in order of
+// start, do not overlap and leave at most slop positions between them.
+func (u *unorderedSpans) match() (span, bool) {
+	cur := make([]span, len(u.subs))
+	for i, sub := range u.subs {
+		cur[i] = sub.current()
+	}
+	slices.SortFunc(cur, compareSpans)
+
+	match, gaps := cur[0], 0
+	for _, sp := range cur[1:] {
+		if sp.start < match.end {
+			return span{}, false
+		}
+		gaps += int(sp.start - match.end)
+		match = span{start: match.start, end: sp.end, width: match.width + sp.width}
+	}
+	if gaps > u.slop {
+		return span{}, false
+	}
+	match.width += gaps
+	return match, true
+}
+
+// spanNot matches the spans of include that no span of exclude overlaps,
+// with include's spans widened by pre positions before and post after.
+type spanNot struct {
+	include, exclude spanQuery
+	pre, post        uint64
+}
+
+func (q *spanNot) docs(src *spanSource) *roaring.Bitmap {
+	return q.include.docs(src)
+}
+
+func (q *spanNot) spans(src *spanSource, docNum uint64) spans {
+	excluded := collectSpans(q.exclude.spans(src, docNum))
+	return &filterSpans{spans: q.include.spans(src, docNum), keep: func(sp span) bool {
+		start, end := sp.start-min(sp.start, q.pre), sp.end+q.post
+		return !slices.ContainsFunc(excluded, func(x span) bool { return x.start < end && x.end > start })
+	}}
+}
+
+func (q *spanNot) docTerms(src *spanSource, docNum uint64, out []string) []string {
+	return q.include.docTerms(src, docNum, out)
+}
+
+// spanFirst matches the spans of match that end by position end.
+type spanFirst struct {
+	match spanQuery
+	end   uint64
+}
+
+func (q *spanFirst) docs(src *spanSource) *roaring.Bitmap {
+	return q.match.docs(src)
+}
+
+func (q *spanFirst) spans(src *spanSource, docNum uint64) spans {
+	return &filterSpans{spans: q.match.spans(src, docNum), keep: func(sp span) bool { return sp.end <= q.end }}
+}
+
+func (q *spanFirst) docTerms(src *spanSource, docNum uint64, out []string) []string {
+	return q.match.docTerms(src, docNum, out)
+}
+
+// filterSpans passes on the spans that keep accepts.
+type filterSpans struct {
+	spans
+	keep func(span) bool
+}
+
+func (f *filterSpans) next() bool {
+	for f.spans.next() {
+		if f.keep(f.spans.current()) {
+			return true
+		}
+	}
+	return false
+}
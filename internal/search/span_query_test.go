@@ -0,0 +1,87 @@
+package search
+
+import (
+	"slices"
+	"testing"
+
+	"harshagw/postings/internal/query"
+)
+
+func spanTerm(term string) *query.SpanTermQuery {
+	return &query.SpanTermQuery{Field: "body", Term: term}
+}
+
+func TestSpanQueries(t *testing.T) {
+	s := createProximityIndex(t)
+
+	quickFox := &query.SpanNearQuery{Clauses: []query.SpanQuery{spanTerm("quick"), spanTerm("fox")}, Slop: 2, InOrder: true}
+	tests := []struct {
+		name string
+		q    query.SpanQuery
+		want []string // sorted
+	}{
+		{"term", spanTerm("Quick"), []string{"exact", "gap1", "gap2", "reversed"}},
+		{"ordered", &query.SpanNearQuery{Clauses: []query.SpanQuery{spanTerm("quick"), spanTerm("fox")}, Slop: 1, InOrder: true},
+			[]string{"exact", "gap1"}},
+		{"unordered", &query.SpanNearQuery{Clauses: []query.SpanQuery{spanTerm("fox"), spanTerm("quick")}, Slop: 1},
+			[]string{"exact", "gap1", "reversed"}},
+		{"repeated term needs two positions", &query.SpanNearQuery{Clauses: []query.SpanQuery{spanTerm("fox"), spanTerm("fox")}, Slop: 3},
+			nil},
+		{"multi term", &query.SpanNearQuery{
+			Clauses: []query.SpanQuery{spanTerm("quick"), &query.SpanMultiQuery{Query: &query.PrefixQuery{Field: "body", Prefix: "b"}}},
+			InOrder: true,
+		}, []string{"gap1", "gap2"}},
+		{"or", &query.SpanNearQuery{
+			Clauses: []query.SpanQuery{&query.SpanOrQuery{Clauses: []query.SpanQuery{spanTerm("brown"), spanTerm("old")}}, spanTerm("fox")},
+			InOrder: true,
+		}, []string{"gap1", "gap2"}},
+		{"nested", &query.SpanNearQuery{Clauses: []query.SpanQuery{quickFox, spanTerm("ran")}, InOrder: true},
+			[]string{"exact", "gap1", "gap2"}},
+		{"not", &query.SpanNotQuery{Include: quickFox, Exclude: spanTerm("old")}, []string{"exact", "gap1"}},
+		{"not before", &query.SpanNotQuery{Include: spanTerm("fox"), Exclude: spanTerm("the"), Pre: 1},
+			[]string{"exact", "gap1", "gap2", "repeat"}},
+		{"first", &query.SpanFirstQuery{Match: spanTerm("fox"), End: 2}, []string{"repeat", "reversed"}},
+	}
+	for _, tt := range tests {
+		if got := sortedIDs(resultIDs(t, s, tt.q)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: RunQuery(%s) = %v, want %v", tt.name, tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestSpanNearQuery_TighterScoresHigher(t *testing.T) {
+	s := createProximityIndex(t)
+
+	q := &query.SpanNearQuery{Clauses: []query.SpanQuery{spanTerm("quick"), spanTerm("fox")}, Slop: 2, InOrder: true}
+	if got, want := resultIDs(t, s, q), []string{"exact", "gap1", "gap2"}; !slices.Equal(got, want) {
+		t.Errorf("RunQuery(%s) = %v, want %v", q, got, want)
+	}
+}
+
+func TestSpanQuery_InBoolQuery(t *testing.T) {
+	s := createProximityIndex(t)
+
+	q := &query.BoolQuery{
+		Must:    []query.Query{&query.SpanNearQuery{Clauses: []query.SpanQuery{spanTerm("quick"), spanTerm("fox")}, Slop: 2, InOrder: true}},
+		MustNot: []query.Query{&query.TermQuery{Field: "body", Term: "brown"}},
+	}
+	if got, want := resultIDs(t, s, q), []string{"exact"}; !slices.Equal(got, want) {
+		t.Errorf("RunQuery(%s) = %v, want %v", q, got, want)
+	}
+}
+
+func TestSpanQuery_Invalid(t *testing.T) {
+	s := createProximityIndex(t)
+
+	for _, q := range []query.SpanQuery{
+		&query.SpanNearQuery{Clauses: []query.SpanQuery{spanTerm("quick"), &query.SpanTermQuery{Field: "title", Term: "fox"}}},
+		&query.SpanMultiQuery{Query: &query.TermQuery{Field: "body", Term: "fox"}},
+		&query.SpanNearQuery{},
+		&query.SpanNotQuery{Include: spanTerm("fox")},
+		&query.SpanFirstQuery{Match: spanTerm("fox"), End: -1},
+	} {
+		if _, err := s.RunQuery(q); err == nil {
+			t.Errorf("RunQuery(%s): expected error", q)
+		}
+	}
+}
@@ -1,56 +1,148 @@
 package search
 
 import (
+	"math"
+	"strconv"
+
+	"harshagw/postings/internal/analysis"
 	"harshagw/postings/internal/index"
+	"harshagw/postings/internal/mapping"
 	"harshagw/postings/internal/segment"
 )
 
 type searchMatch struct {
-	docID       string
+	segmentIdx  int // index into the snapshot's segments, -1 for the builder
+	docNum      uint64
 	tf          float64
 	fieldLength uint64
 	field       string
-	segmentIdx  int
+	terms       []string // the matched term, or the terms of a matched phrase
 }
 
-// search searches for a term, optionally in a specific field.
-func (s *Searcher) termSearch(term, field string) ([]Result, error) {
-	seen := make(map[string]bool)
-	var matches []searchMatch
+// docKey identifies a live document by where it is stored. A document is
+// live in at most one place, so matches are grouped by docKey.
+type docKey struct {
+	segmentIdx int
+	docNum     uint64
+}
 
-	segments := s.snapshot.Segments()
-	for i := len(segments) - 1; i >= 0; i-- {
-		segSnap := segments[i]
-		segMatches := s.searchSegment(segSnap, term, field, i, seen)
-		matches = append(matches, segMatches...)
+// termQuerySearch runs a term query. Like indexed text, the term is analyzed
+// with each field's analyzer; _id is matched verbatim.
+func (s *Searcher) termQuerySearch(term, field string, c collector) error {
+	s.analyzedSearch(term, 0, s.termQueryFields(field), c)
+	return nil
+}
+
+// termQueryDocSet is termQuerySearch for set-based boolean queries.
+func (s *Searcher) termQueryDocSet(term, field string) *docSet {
+	fields := s.termQueryFields(field)
+
+	sets := []*docSet{newDocSet(s.snapshot)}
+	for _, f := range fields {
+		for _, tokens := range s.analyzeForField(term, f) {
+			if len(tokens) > 1 {
+				// Multi-token terms are phrase matches, which need positions.
+				var hits hitList
+				s.analyzedSearch(term, 0, fields, &hits)
+				return s.hitsToDocSet(hits)
+			}
+			sets = append(sets, s.termDocSet(tokens[0].Token, f))
+		}
 	}
+	return unionAll(sets)
+}
 
-	if s.snapshot.Builder() != nil {
-		builderMatches := s.searchBuilder(term, field, seen)
-		matches = append(matches, builderMatches...)
+func (s *Searcher) termQueryFields(field string) []string {
+	fields := s.getFieldsToSearch(field)
+	if field == "" {
+		fields = append(fields, segment.IDField)
 	}
+	return fields
+}
 
-	return s.scoreAndSort(matches, field), nil
+// analyzeForField analyzes query text the way field was indexed. It returns
+// the alternative token sequences of the text, of which a document needs to
+// match only one; there is more than one when the field's search analyzer
+// expands synonyms.
+func (s *Searcher) analyzeForField(text, field string) [][]analysis.TokenPosition {
+	if field == segment.IDField {
+		return [][]analysis.TokenPosition{{{Token: text}}}
+	}
+	switch s.snapshot.Mapping().TypeFor(field) {
+	case mapping.TypeNumeric:
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil
+		}
+		return [][]analysis.TokenPosition{{{Token: segment.EncodeNumeric(v)}}}
+	case mapping.TypeDate:
+		t, err := s.snapshot.Mapping().ParseDate(field, text)
+		if err != nil {
+			return nil
+		}
+		return [][]analysis.TokenPosition{{{Token: segment.EncodeNumeric(mapping.DateValue(t))}}}
+	}
+	alternatives := analysis.AnalyzeGraph(s.snapshot.SearchAnalyzerFor(field), text)
+	// Numbers are indexed as numeric terms in fields of any type, so a
+	// number also matches them in a text field.
+	if v, err := strconv.ParseFloat(text, 64); err == nil && !math.IsInf(v, 0) && !math.IsNaN(v) {
+		alternatives = append(alternatives, []analysis.TokenPosition{{Token: segment.EncodeNumeric(v)}})
+	}
+	return alternatives
 }
 
-func (s *Searcher) searchSegment(segSnap *index.SegmentSnapshot, term, field string, segIdx int, seen map[string]bool) []searchMatch {
-	var matches []searchMatch
-	seg := segSnap.Segment()
+// normalizeForField normalizes a prefix or fuzzy term the way field was
+// indexed, without splitting it into tokens.
+func (s *Searcher) normalizeForField(term, field string) string {
+	if s.snapshot.Mapping().TypeFor(field) != mapping.TypeText {
+		return term
+	}
+	return analysis.Normalize(s.snapshot.SearchAnalyzerFor(field), term)
+}
 
-	fields := []string{field}
-	if field == "" {
-		fields = seg.Fields()
+// analyzedSearch analyzes text per field and matches it in each field as a
+// term, or as a phrase with the given slop if the field's analyzer yields
+// several tokens. A document matches if any alternative token sequence of
+// the text matches.
+func (s *Searcher) analyzedSearch(text string, slop int, fields []string, c collector) {
+	var matches []searchMatch
+	for _, f := range fields {
+		for _, tokens := range s.analyzeForField(text, f) {
+			if len(tokens) == 1 {
+				matches = append(matches, s.termMatches(tokens[0].Token, f)...)
+				continue
+			}
+			matches = append(matches, s.phraseMatches(newPhrase(tokens, slop), f)...)
+		}
 	}
 
+	s.scoreMatches(matches, c)
+}
+
+// termSearch scores the documents containing term in any of fields.
+func (s *Searcher) termSearch(term string, fields []string, c collector) {
+	var matches []searchMatch
 	for _, f := range fields {
-		fieldMatches := s.searchSegmentField(segSnap, seg, term, f, segIdx, seen)
-		matches = append(matches, fieldMatches...)
+		matches = append(matches, s.termMatches(term, f)...)
 	}
+	s.scoreMatches(matches, c)
+}
 
+// termMatches returns the live documents containing term in field, from
+// the newest segment to the oldest and then the builder.
+func (s *Searcher) termMatches(term, field string) []searchMatch {
+	var matches []searchMatch
+	segments := s.snapshot.Segments()
+	for i := len(segments) - 1; i >= 0; i-- {
+		matches = append(matches, s.searchSegmentField(segments[i], segments[i].Segment(), term, field, i)...)
+	}
+	if builder := s.snapshot.Builder(); builder != nil {
+		matches = append(matches, s.searchBuilderField(builder, term, field)...)
+	}
 	return matches
 }
 
-func (s *Searcher) searchSegmentField(segSnap *index.SegmentSnapshot, seg *segment.Segment, term, field string, segIdx int, seen map[string]bool) []searchMatch {
+func (s *Searcher) searchSegmentField(segSnap *index.SegmentSnapshot, seg *segment.Segment, term, field string, segIdx int) []searchMatch {
 	var matches []searchMatch
 
 	postings, err := segSnap.Search(term, field)
@@ -59,40 +151,20 @@ func (s *Searcher) searchSegmentField(segSnap *index.SegmentSnapshot, seg *segme
 	}
 
 	for _, p := range postings {
-		extID, ok := seg.ExternalID(p.DocNum)
-		if !ok || seen[extID] {
-			continue
-		}
-		seen[extID] = true
 		matches = append(matches, searchMatch{
-			docID:       extID,
+			segmentIdx:  segIdx,
+			docNum:      p.DocNum,
 			tf:          float64(p.Frequency),
 			fieldLength: seg.FieldLength(field, p.DocNum),
 			field:       field,
-			segmentIdx:  segIdx,
+			terms:       []string{term},
 		})
 	}
 
 	return matches
 }
 
-func (s *Searcher) searchBuilder(term, field string, seen map[string]bool) []searchMatch {
-	var matches []searchMatch
-	builder := s.snapshot.Builder()
-
-	if field != "" {
-		matches = s.searchBuilderField(builder, term, field, seen)
-	} else {
-		for fieldName := range builder.Fields {
-			fieldMatches := s.searchBuilderField(builder, term, fieldName, seen)
-			matches = append(matches, fieldMatches...)
-		}
-	}
-
-	return matches
-}
-
-func (s *Searcher) searchBuilderField(builder *segment.Builder, term, field string, seen map[string]bool) []searchMatch {
+func (s *Searcher) searchBuilderField(builder *segment.Builder, term, field string) []searchMatch {
 	var matches []searchMatch
 
 	fieldTerms, ok := builder.Fields[field]
@@ -109,19 +181,14 @@ func (s *Searcher) searchBuilderField(builder *segment.Builder, term, field stri
 		if builder.IsDeleted(p.DocNum) {
 			continue
 		}
-		if p.DocNum < uint64(len(builder.DocIDs)) {
-			extID := builder.DocIDs[p.DocNum]
-			if !seen[extID] {
-				seen[extID] = true
-				matches = append(matches, searchMatch{
-					docID:       extID,
-					tf:          float64(p.Frequency),
-					fieldLength: builder.FieldLength(field, p.DocNum),
-					field:       field,
-					segmentIdx:  -1,
-				})
-			}
-		}
+		matches = append(matches, searchMatch{
+			segmentIdx:  -1,
+			docNum:      p.DocNum,
+			tf:          float64(p.Frequency),
+			fieldLength: builder.FieldLength(field, p.DocNum),
+			field:       field,
+			terms:       []string{term},
+		})
 	}
 
 	return matches
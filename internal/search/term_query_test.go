@@ -1,9 +1,11 @@
 package search
 
 import (
+	"slices"
 	"testing"
 
 	"harshagw/postings/internal/index"
+	"harshagw/postings/internal/mapping"
 	"harshagw/postings/internal/query"
 )
 
@@ -285,3 +287,154 @@ func TestTermQuery_EmptyTermReturnsEmpty(t *testing.T) {
 		t.Errorf("expected 0 results for empty term, got %d", len(results))
 	}
 }
+
+func TestTermQuery_UsesFieldAnalyzer(t *testing.T) {
+	m, err := mapping.Parse([]byte(`{"fields": {"sku": {"analyzer": "keyword"}}}`), nil)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	config := index.DefaultConfig(t.TempDir())
+	config.Mapping = m
+	idx, err := index.New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"sku": "AB-12", "body": "part AB-12 in stock"})
+	idx.Flush()
+	idx.Index("doc2", map[string]any{"sku": "AB", "body": "12 AB parts"})
+
+	s, sCleanup := createSearcher(t, idx)
+	defer sCleanup()
+
+	tests := []struct {
+		q    query.Query
+		want []string
+	}{
+		// Keyword field: the whole value is one term, case-sensitive.
+		{&query.TermQuery{Field: "sku", Term: "AB-12"}, []string{"doc1"}},
+		{&query.TermQuery{Field: "sku", Term: "ab-12"}, nil},
+		{&query.TermQuery{Field: "sku", Term: "AB"}, []string{"doc2"}},
+		// Simple field: "AB-12" analyzes to "ab 12" and is matched as a phrase.
+		{&query.TermQuery{Field: "body", Term: "AB-12"}, []string{"doc1"}},
+		// Unfielded: each field analyzes the term its own way.
+		{&query.TermQuery{Term: "AB-12"}, []string{"doc1"}},
+		{&query.BoolQuery{Must: []query.Query{
+			&query.TermQuery{Field: "sku", Term: "AB"},
+			&query.TermQuery{Field: "body", Term: "Parts"},
+		}}, []string{"doc2"}},
+	}
+
+	for _, tt := range tests {
+		results, err := s.RunQuery(tt.q)
+		if err != nil {
+			t.Fatalf("RunQuery(%v) error: %v", tt.q, err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.DocID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("RunQuery(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestTermQuery_EnglishAnalyzer(t *testing.T) {
+	config := index.DefaultConfig(t.TempDir())
+	config.AnalyzerName = "english"
+	idx, err := index.New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"body": "She runs the state of the art lab"})
+	idx.Flush()
+	idx.Index("doc2", map[string]any{"body": "Running a state machine"})
+
+	s, sCleanup := createSearcher(t, idx)
+	defer sCleanup()
+
+	tests := []struct {
+		q    query.Query
+		want []string
+	}{
+		// Stemming: "running" and "runs" share the stem "run".
+		{&query.TermQuery{Field: "body", Term: "running"}, []string{"doc1", "doc2"}},
+		// Stop words are never indexed.
+		{&query.TermQuery{Field: "body", Term: "the"}, nil},
+		// Dropped stop words keep their positions, so phrases still line up.
+		{&query.PhraseQuery{Field: "body", Phrase: "states of the arts"}, []string{"doc1"}},
+		{&query.PhraseQuery{Field: "body", Phrase: "state art"}, nil},
+	}
+
+	for _, tt := range tests {
+		results, err := s.RunQuery(tt.q)
+		if err != nil {
+			t.Fatalf("RunQuery(%v) error: %v", tt.q, err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.DocID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("RunQuery(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestQueries_NormalizeLikeTheIndex(t *testing.T) {
+	m, err := mapping.Parse([]byte(`{"fields": {"name": {"analyzer": "custom",
+		"options": {"token_filters": "nfkc,lowercase,asciifolding"}}}}`), nil)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	config := index.DefaultConfig(t.TempDir())
+	config.Mapping = m
+	idx, err := index.New(config)
+	if err != nil {
+		t.Fatalf("New index error: %v", err)
+	}
+	defer idx.Close()
+
+	idx.Index("doc1", map[string]any{"name": "Café Crème", "body": "Hello"})
+	idx.Flush()
+	idx.Index("doc2", map[string]any{"name": "café noir"})
+	idx.Index("doc3", map[string]any{"name": "ＣＡＦＥ ＬＡＴＴＥ"})
+
+	s, sCleanup := createSearcher(t, idx)
+	defer sCleanup()
+
+	tests := []struct {
+		q    query.Query
+		want []string
+	}{
+		{&query.TermQuery{Field: "name", Term: "cafe"}, []string{"doc1", "doc2", "doc3"}},
+		{&query.TermQuery{Field: "name", Term: "CAFÉ"}, []string{"doc1", "doc2", "doc3"}},
+		{&query.PhraseQuery{Field: "name", Phrase: "cafe creme"}, []string{"doc1"}},
+		{&query.PrefixQuery{Field: "name", Prefix: "Crè"}, []string{"doc1"}},
+		{&query.PrefixQuery{Field: "name", Prefix: "ＬＡＴ"}, []string{"doc3"}},
+		{&query.FuzzyQuery{Field: "name", Term: "Noïr", Fuzziness: 0}, []string{"doc2"}},
+		{&query.FuzzyQuery{Field: "name", Term: "Lattè", Fuzziness: 1}, []string{"doc3"}},
+		// Unmapped fields use the simple analyzer, which lowercases.
+		{&query.PrefixQuery{Prefix: "HEL"}, []string{"doc1"}},
+	}
+
+	for _, tt := range tests {
+		results, err := s.RunQuery(tt.q)
+		if err != nil {
+			t.Fatalf("RunQuery(%v) error: %v", tt.q, err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.DocID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("RunQuery(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
@@ -1,22 +1,6 @@
 package search
 
-import (
-	"slices"
-	"sort"
-)
-
-// sortByScore sorts the results by score in descending order.
-func sortByScore(results []Result) {
-	slices.SortFunc(results, func(a, b Result) int {
-		if a.Score > b.Score {
-			return -1
-		}
-		if a.Score < b.Score {
-			return 1
-		}
-		return 0
-	})
-}
+import "sort"
 
 // levenshteinDistance calculates the edit distance between two strings.
 func levenshteinDistance(a, b string) int {
@@ -39,18 +39,3 @@ func TestBinarySearchUint64(t *testing.T) {
 		t.Error("non-existing element should return false")
 	}
 }
-
-func TestSortByScore(t *testing.T) {
-	results := []Result{
-		{DocID: "doc1", Score: 1.0},
-		{DocID: "doc2", Score: 3.0},
-		{DocID: "doc3", Score: 2.0},
-	}
-
-	sortByScore(results)
-
-	// Verify sorted in descending order
-	if results[0].DocID != "doc2" || results[1].DocID != "doc3" || results[2].DocID != "doc1" {
-		t.Errorf("got order %s,%s,%s, want doc2,doc3,doc1", results[0].DocID, results[1].DocID, results[2].DocID)
-	}
-}
@@ -1,14 +1,13 @@
 package segment
 
 import (
-	"encoding/binary"
-	"encoding/json"
-	"os"
-	"path/filepath"
+	"reflect"
+	"sync"
 
 	"github.com/RoaringBitmap/roaring"
 
 	"harshagw/postings/internal/analysis"
+	"harshagw/postings/internal/mapping"
 )
 
 // Builder accumulates documents before flushing to an immutable segment.
@@ -19,11 +18,20 @@ type Builder struct {
 	DocIDs       []string                        // external IDs by docNum
 	Deleted      *roaring.Bitmap                 // deleted docNums
 	numDocs      uint64
-	analyzer     analysis.Analyzer
+	mapping      *mapping.IndexMapping
+
+	frozenMu sync.Mutex
+	frozen   *Builder // cached read-only view, reset on modification
 }
 
-// NewBuilder creates a new segment builder.
+// NewBuilder creates a new segment builder that analyzes every field with analyzer.
 func NewBuilder(analyzer analysis.Analyzer) *Builder {
+	return NewBuilderWithMapping(mapping.New(analyzer))
+}
+
+// NewBuilderWithMapping creates a new segment builder that picks each
+// field's analyzer from m.
+func NewBuilderWithMapping(m *mapping.IndexMapping) *Builder {
 	return &Builder{
 		Fields:       make(map[string]map[string][]Posting),
 		FieldLengths: make(map[string][]uint64),
@@ -31,7 +39,7 @@ func NewBuilder(analyzer analysis.Analyzer) *Builder {
 		DocIDs:       make([]string, 0),
 		Deleted:      roaring.New(),
 		numDocs:      0,
-		analyzer:     analyzer,
+		mapping:      m,
 	}
 }
 
@@ -40,6 +48,8 @@ const IDField = "_id"
 
 // Add adds a document to the builder and returns its docNum.
 func (b *Builder) Add(externalID string, doc map[string]any) uint64 {
+	b.invalidateFrozen()
+
 	docNum := b.numDocs
 	b.numDocs++
 
@@ -56,37 +66,27 @@ func (b *Builder) Add(externalID string, doc map[string]any) uint64 {
 		Positions: []uint64{1},
 	}}
 
-	// Index each user field
+	// Index each user field, flattening nested objects into dotted paths
+	fields := make(map[string]*docField)
 	for fieldName, value := range doc {
-		text, ok := value.(string)
-		if !ok {
-			continue
-		}
+		b.addValue(fields, fieldName, value)
+	}
 
+	for fieldName, f := range fields {
 		if b.Fields[fieldName] == nil {
 			b.Fields[fieldName] = make(map[string][]Posting)
 		}
-
-		tokens := b.analyzer.Analyze(text)
-
-		if b.FieldLengths[fieldName] == nil {
-			b.FieldLengths[fieldName] = make([]uint64, 0)
-		}
 		for len(b.FieldLengths[fieldName]) <= int(docNum) {
 			b.FieldLengths[fieldName] = append(b.FieldLengths[fieldName], 0)
 		}
-		b.FieldLengths[fieldName][docNum] = uint64(len(tokens))
+		b.FieldLengths[fieldName][docNum] = f.length
 
-		termPositions := make(map[string][]uint64)
-		for _, tp := range tokens {
-			termPositions[tp.Token] = append(termPositions[tp.Token], tp.Position)
-		}
-
-		for term, positions := range termPositions {
+		for term, occ := range f.terms {
 			b.Fields[fieldName][term] = append(b.Fields[fieldName][term], Posting{
 				DocNum:    docNum,
-				Frequency: uint64(len(positions)),
-				Positions: positions,
+				Frequency: uint64(len(occ.positions)),
+				Positions: occ.positions,
+				Offsets:   occ.offsets,
 			})
 		}
 	}
@@ -94,10 +94,141 @@ func (b *Builder) Add(externalID string, doc map[string]any) uint64 {
 	return docNum
 }
 
+// PositionGap separates the positions of consecutive values of a
+// multi-valued field so phrases don't match across values.
+const PositionGap = 100
+
+// docField collects the terms of one field of the document being added.
+type docField struct {
+	terms      map[string]*occurrences
+	length     uint64 // distinct token positions across all values
+	nextPos    uint64 // first position of the next value
+	offsets    bool   // record term offsets
+	nextOffset uint64 // offset of the next text value
+}
+
+// occurrences are the positions, and optionally offsets, of one term.
+type occurrences struct {
+	positions []uint64
+	offsets   []Offset
+}
+
+// add records the tokens of one value, placing it after earlier values.
+// Filters such as n-grams stack several tokens on one position; those count
+// once toward the field length, and a term repeated at the same position is
+// recorded once. textLen is the byte length of a string value, even an empty
+// one, or -1 for other values.
+func (f *docField) add(tokens []analysis.TokenPosition, textLen int) {
+	base := f.nextOffset
+	if textLen >= 0 {
+		f.nextOffset += uint64(textLen) + 1
+	}
+	if len(tokens) == 0 {
+		return
+	}
+
+	basePos := f.nextPos
+	var last uint64
+	for i, tp := range tokens {
+		pos := basePos + tp.Position
+		occ := f.terms[tp.Token]
+		if occ == nil {
+			occ = &occurrences{}
+			f.terms[tp.Token] = occ
+		}
+		if n := len(occ.positions); n == 0 || occ.positions[n-1] != pos {
+			occ.positions = append(occ.positions, pos)
+			if f.offsets {
+				occ.offsets = append(occ.offsets, Offset{Start: base + uint64(tp.Start), End: base + uint64(tp.End)})
+			}
+		}
+		if i == 0 || tp.Position != tokens[i-1].Position {
+			f.length++
+		}
+		last = max(last, pos)
+	}
+	f.nextPos = last + 1 + PositionGap
+}
+
+// addValue indexes value under fieldName. Objects add their keys as dotted
+// sub-fields and arrays add each element as another value of the field.
+func (b *Builder) addValue(fields map[string]*docField, fieldName string, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			b.addValue(fields, fieldName+"."+key, child)
+		}
+		return
+	case []any:
+		for _, elem := range v {
+			b.addValue(fields, fieldName, elem)
+		}
+		return
+	case []string:
+		for _, elem := range v {
+			b.addValue(fields, fieldName, elem)
+		}
+		return
+	case []map[string]any:
+		for _, elem := range v {
+			b.addValue(fields, fieldName, elem)
+		}
+		return
+	}
+
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		for i := 0; i < rv.Len(); i++ {
+			b.addValue(fields, fieldName, rv.Index(i).Interface())
+		}
+		return
+	}
+
+	tokens, ok := b.analyzeValue(fieldName, value)
+	if !ok {
+		return
+	}
+
+	f := fields[fieldName]
+	if f == nil {
+		f = &docField{terms: make(map[string]*occurrences), offsets: b.mapping.OffsetsFor(fieldName)}
+		fields[fieldName] = f
+	}
+	textLen := -1
+	if text, ok := value.(string); ok {
+		textLen = len(text)
+	}
+	f.add(tokens, textLen)
+}
+
+// analyzeValue turns a scalar into tokens according to the field's type:
+// text is analyzed, numbers and dates become a single numeric term.
+func (b *Builder) analyzeValue(fieldName string, value any) ([]analysis.TokenPosition, bool) {
+	text, isText := value.(string)
+	switch b.mapping.TypeFor(fieldName) {
+	case mapping.TypeDate:
+		if isText {
+			t, err := b.mapping.ParseDateValue(fieldName, text)
+			if err != nil {
+				return nil, false
+			}
+			return []analysis.TokenPosition{{Token: EncodeNumeric(mapping.DateValue(t))}}, true
+		}
+	case mapping.TypeText:
+		if isText {
+			return b.mapping.AnalyzerFor(fieldName).Analyze(text), true
+		}
+	}
+	if v, ok := numericValue(value); ok {
+		return []analysis.TokenPosition{{Token: EncodeNumeric(v)}}, true
+	}
+	return nil, false
+}
+
 // Delete marks a document as deleted. Returns true if found.
 func (b *Builder) Delete(externalID string) bool {
 	for i, id := range b.DocIDs {
 		if id == externalID && !b.Deleted.Contains(uint32(i)) {
+			b.invalidateFrozen()
 			b.Deleted.Add(uint32(i))
 			return true
 		}
@@ -105,6 +236,52 @@ func (b *Builder) Delete(externalID string) bool {
 	return false
 }
 
+// Freeze returns a read-only, point-in-time view of the builder that is safe
+// to search while the builder keeps accepting documents. Posting, document and
+// field-length slices are append-only, so the view shares their backing arrays
+// (capped at the current length) and only copies the maps and the deletion
+// bitmap. The view is cached until the builder is next modified.
+func (b *Builder) Freeze() *Builder {
+	b.frozenMu.Lock()
+	defer b.frozenMu.Unlock()
+
+	if b.frozen != nil {
+		return b.frozen
+	}
+
+	fields := make(map[string]map[string][]Posting, len(b.Fields))
+	for field, terms := range b.Fields {
+		frozenTerms := make(map[string][]Posting, len(terms))
+		for term, postings := range terms {
+			frozenTerms[term] = postings[:len(postings):len(postings)]
+		}
+		fields[field] = frozenTerms
+	}
+
+	fieldLengths := make(map[string][]uint64, len(b.FieldLengths))
+	for field, lengths := range b.FieldLengths {
+		fieldLengths[field] = lengths[:len(lengths):len(lengths)]
+	}
+
+	b.frozen = &Builder{
+		Fields:       fields,
+		FieldLengths: fieldLengths,
+		Docs:         b.Docs[:len(b.Docs):len(b.Docs)],
+		DocIDs:       b.DocIDs[:len(b.DocIDs):len(b.DocIDs)],
+		Deleted:      b.Deleted.Clone(),
+		numDocs:      b.numDocs,
+		mapping:      b.mapping,
+	}
+	return b.frozen
+}
+
+// invalidateFrozen drops the cached frozen view before a modification.
+func (b *Builder) invalidateFrozen() {
+	b.frozenMu.Lock()
+	b.frozen = nil
+	b.frozenMu.Unlock()
+}
+
 // IsDeleted checks if a docNum is deleted.
 func (b *Builder) IsDeleted(docNum uint64) bool {
 	return b.Deleted.Contains(uint32(docNum))
@@ -130,113 +307,36 @@ func (b *Builder) FieldLength(field string, docNum uint64) uint64 {
 
 // AvgFieldLength returns the average length of a field.
 func (b *Builder) AvgFieldLength(field string) float64 {
-	lengths, ok := b.FieldLengths[field]
-	if !ok || len(lengths) == 0 {
-		return 0
-	}
-	var total uint64
-	var count uint64
-	for i, l := range lengths {
-		if !b.IsDeleted(uint64(i)) && l > 0 {
-			total += l
-			count++
-		}
-	}
-	if count == 0 {
-		return 0
-	}
-	return float64(total) / float64(count)
+	return b.FieldStats(field).AvgLength()
 }
 
-// Build writes the segment to disk and returns the segment path.
-func (b *Builder) Build(dir, segmentID string) (string, error) {
-	segPath := filepath.Join(dir, segmentID+".seg")
-	tmpPath := segPath + ".tmp"
-
-	file, err := os.Create(tmpPath)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	// Write header
-	if _, err := file.WriteString(SegmentMagic); err != nil {
-		return "", err
-	}
-	if err := binary.Write(file, binary.BigEndian, SegmentVersion); err != nil {
-		return "", err
-	}
-	if err := binary.Write(file, binary.BigEndian, b.TotalDocs()); err != nil {
-		return "", err
-	}
-
-	// Reserve space for offsets
-	offsetsPos, _ := file.Seek(0, 1)
-	placeholder := make([]byte, 16)
-	file.Write(placeholder)
-
-	// Write stored fields
-	storedFieldsOffset, _ := file.Seek(0, 1)
-	chunkOffsets, err := b.writeStoredFields(file)
-	if err != nil {
-		return "", err
-	}
-
-	// Write fields index
-	fieldsIndexOffset, _ := file.Seek(0, 1)
-	fieldsMeta, err := b.writeFieldsIndex(file)
-	if err != nil {
-		return "", err
-	}
+// TermStats returns the number of live documents containing term in a field
+// and the term's total frequency in them.
+func (b *Builder) TermStats(term, field string) TermStats {
+	return termStats(b.Fields[field][term], b.Deleted)
+}
 
-	// Compute field stats for BM25 (excluding deleted docs)
-	for i := range fieldsMeta {
-		field := fieldsMeta[i].Name
-		if lengths, ok := b.FieldLengths[field]; ok {
-			var total uint64
-			var count uint64
-			for docNum, l := range lengths {
-				if l > 0 && !b.IsDeleted(uint64(docNum)) {
-					total += l
-					count++
-				}
-			}
-			fieldsMeta[i].TotalTokens = total
-			fieldsMeta[i].DocCount = count
+// FieldStats returns the number of live documents with a value in field and
+// their total length.
+func (b *Builder) FieldStats(field string) FieldStats {
+	var stats FieldStats
+	for docNum, l := range b.FieldLengths[field] {
+		if l > 0 && !b.IsDeleted(uint64(docNum)) {
+			stats.DocCount++
+			stats.SumTotalTermFreq += l
 		}
 	}
+	return stats
+}
 
-	footerOffset, _ := file.Seek(0, 1)
-	footer := Footer{
-		StoredFieldsOffset: uint64(storedFieldsOffset),
-		FieldsIndexOffset:  uint64(fieldsIndexOffset),
-		ChunkOffsets:       chunkOffsets,
-		FieldsMeta:         fieldsMeta,
-		DocIDs:             b.DocIDs,
-		NumDocs:            b.TotalDocs(),
-		FieldLengths:       b.FieldLengths,
-	}
-	footerData, err := json.Marshal(footer)
-	if err != nil {
-		return "", err
-	}
-	if _, err := file.Write(footerData); err != nil {
-		return "", err
-	}
-
-	binary.Write(file, binary.BigEndian, uint64(footerOffset))
-	binary.Write(file, binary.BigEndian, uint64(len(footerData)))
-
-	// Go back and write the actual offsets
-	file.Seek(offsetsPos, 0)
-	binary.Write(file, binary.BigEndian, uint64(storedFieldsOffset))
-	binary.Write(file, binary.BigEndian, uint64(fieldsIndexOffset))
-
-	file.Close()
-
-	if err := os.Rename(tmpPath, segPath); err != nil {
-		return "", err
-	}
-
-	return segPath, nil
+// Build writes the segment to disk and returns the segment path.
+func (b *Builder) Build(dir, segmentID string) (string, error) {
+	return writeSegment(dir, segmentID, segmentContents{
+		numDocs:      b.TotalDocs(),
+		docIDs:       b.DocIDs,
+		fieldLengths: b.FieldLengths,
+		deleted:      b.Deleted,
+		writeStored:  b.writeStoredFields,
+		writeFields:  b.writeFieldsIndex,
+	})
 }
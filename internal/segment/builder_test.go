@@ -1,11 +1,14 @@
 package segment
 
 import (
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"harshagw/postings/internal/analysis"
+	"harshagw/postings/internal/mapping"
 )
 
 func TestBuilder_Add_ReturnsDocNum(t *testing.T) {
@@ -190,17 +193,183 @@ func TestBuilder_Build_ExternalIDMapping(t *testing.T) {
 	}
 }
 
-// Builder ignores non-string fields
-func TestBuilder_IgnoresNonStringFields(t *testing.T) {
+// Builder indexes numbers as numeric terms and ignores other non-string values
+func TestBuilder_IndexesNumericFields(t *testing.T) {
 	b := NewBuilder(analysis.NewSimple())
 	b.Add("doc1", map[string]any{
-		"title": "Hello",
-		"count": 42,
-		"score": 3.14,
+		"title":  "Hello",
+		"count":  42,
+		"score":  3.14,
+		"active": true,
 	})
 
-	// Only title and _id should be indexed
-	if len(b.Fields) != 2 {
-		t.Errorf("expected 2 fields, got %d", len(b.Fields))
+	// title, count, score and _id should be indexed
+	if len(b.Fields) != 4 {
+		t.Errorf("expected 4 fields, got %d", len(b.Fields))
+	}
+	if _, ok := b.Fields["count"][EncodeNumeric(42)]; !ok {
+		t.Error("expected numeric term for count")
+	}
+	if _, ok := b.Fields["score"][EncodeNumeric(3.14)]; !ok {
+		t.Error("expected numeric term for score")
+	}
+}
+
+func TestBuilder_FlattensNestedObjects(t *testing.T) {
+	b := NewBuilder(analysis.NewSimple())
+	b.Add("doc1", map[string]any{
+		"author": map[string]any{
+			"name":    "Ada Lovelace",
+			"address": map[string]any{"city": "London"},
+		},
+		"comments": []any{
+			map[string]any{"by": "bob"},
+			map[string]any{"by": "carol"},
+		},
+	})
+
+	if _, ok := b.Fields["author.name"]["lovelace"]; !ok {
+		t.Error("expected term in author.name")
+	}
+	if _, ok := b.Fields["author.address.city"]["london"]; !ok {
+		t.Error("expected term in author.address.city")
+	}
+	if _, ok := b.Fields["author"]; ok {
+		t.Error("object field should not be indexed itself")
+	}
+	if got := b.FieldLength("comments.by", 0); got != 2 {
+		t.Errorf("comments.by length: got %d, want 2", got)
+	}
+}
+
+func TestBuilder_IndexesArraysWithPositionGap(t *testing.T) {
+	b := NewBuilder(analysis.NewSimple())
+	b.Add("doc1", map[string]any{
+		"tags":   []any{"big data", "data science", "science"},
+		"scores": []int{3, 7},
+	})
+
+	postings := b.Fields["tags"]["data"]
+	if len(postings) != 1 || postings[0].Frequency != 2 {
+		t.Fatalf("expected one posting with frequency 2, got %+v", postings)
+	}
+	// "data" ends value 0 at position 1; value 1 starts after the gap.
+	want := []uint64{1, 2 + PositionGap}
+	if got := postings[0].Positions; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("data positions: got %v, want %v", got, want)
+	}
+	if got := b.Fields["tags"]["science"][0].Positions; len(got) != 2 || got[1] != 2*(PositionGap+2) {
+		t.Errorf("science positions: got %v", got)
+	}
+	if got := b.FieldLength("tags", 0); got != 5 {
+		t.Errorf("tags length: got %d, want 5", got)
+	}
+
+	for _, v := range []float64{3, 7} {
+		if _, ok := b.Fields["scores"][EncodeNumeric(v)]; !ok {
+			t.Errorf("expected numeric term %v in scores", v)
+		}
+	}
+}
+
+func TestBuilder_StackedTokensShareAPosition(t *testing.T) {
+	a, err := analysis.Build("custom", map[string]string{"token_filters": "ngram", "min_gram": "1", "max_gram": "2"})
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	b := NewBuilder(a)
+	b.Add("doc1", map[string]any{"body": "aa b"})
+
+	// "aa" yields a, aa, a at position 0; the repeated gram is recorded once.
+	if got := b.Fields["body"]["a"][0].Positions; len(got) != 1 || got[0] != 0 {
+		t.Errorf("a positions: got %v, want [0]", got)
+	}
+	if got := b.Fields["body"]["b"][0].Positions; len(got) != 1 || got[0] != 1 {
+		t.Errorf("b positions: got %v, want [1]", got)
+	}
+	// Length counts positions, not grams.
+	if got := b.FieldLength("body", 0); got != 2 {
+		t.Errorf("body length: got %d, want 2", got)
+	}
+}
+
+func TestBuilder_RecordsOffsetsWhenMapped(t *testing.T) {
+	m := mapping.New(analysis.NewSimple())
+	m.Fields = map[string]mapping.FieldMapping{"body": {Offsets: true}}
+	b := NewBuilderWithMapping(m)
+	b.Add("doc1", map[string]any{"body": []any{"Hello world", "big world"}, "title": "hello"})
+
+	// The second value starts after the first plus one separator byte.
+	want := []Offset{{6, 11}, {16, 21}}
+	if got := b.Fields["body"]["world"][0].Offsets; !slices.Equal(got, want) {
+		t.Errorf("world offsets: got %v, want %v", got, want)
+	}
+	if got := b.Fields["title"]["hello"][0].Offsets; got != nil {
+		t.Errorf("title offsets: got %v, want none", got)
+	}
+
+	// An empty value still takes its separator byte.
+	b.Add("doc2", map[string]any{"body": []any{"", "hello world"}})
+	want = []Offset{{1, 6}}
+	if got := b.Fields["body"]["hello"][1].Offsets; !slices.Equal(got, want) {
+		t.Errorf("hello offsets after an empty value: got %v, want %v", got, want)
+	}
+}
+
+func TestEncodeNumeric_SortsByValue(t *testing.T) {
+	values := []float64{math.Inf(-1), -1e10, -2.5, -1, 0, 1e-9, 1, 2.5, 42, 1e10, math.Inf(1)}
+	for i := 1; i < len(values); i++ {
+		if EncodeNumeric(values[i-1]) >= EncodeNumeric(values[i]) {
+			t.Errorf("EncodeNumeric(%v) should sort before EncodeNumeric(%v)", values[i-1], values[i])
+		}
+	}
+	for _, v := range values {
+		if got, ok := DecodeNumeric(EncodeNumeric(v)); !ok || got != v {
+			t.Errorf("DecodeNumeric(EncodeNumeric(%v)) = %v, %v", v, got, ok)
+		}
+	}
+	if EncodeNumeric(math.Copysign(0, -1)) != EncodeNumeric(0) {
+		t.Error("expected -0 and 0 to encode the same")
+	}
+}
+
+func TestBuilder_Freeze_IsolatedFromLaterWrites(t *testing.T) {
+	b := NewBuilder(analysis.NewSimple())
+	b.Add("doc1", map[string]any{"title": "hello world"})
+
+	frozen := b.Freeze()
+
+	b.Add("doc2", map[string]any{"title": "hello again"})
+	b.Delete("doc1")
+
+	if frozen.NumDocs() != 1 {
+		t.Errorf("frozen NumDocs: got %d, want 1", frozen.NumDocs())
+	}
+	if frozen.IsDeleted(0) {
+		t.Error("frozen view should not see later deletion")
+	}
+	if got := len(frozen.Fields["title"]["hello"]); got != 1 {
+		t.Errorf("frozen postings for 'hello': got %d, want 1", got)
+	}
+	if _, ok := frozen.Fields["title"]["again"]; ok {
+		t.Error("frozen view should not see terms added later")
+	}
+	if len(frozen.DocIDs) != 1 {
+		t.Errorf("frozen DocIDs: got %d, want 1", len(frozen.DocIDs))
+	}
+}
+
+func TestBuilder_Freeze_CachedUntilModified(t *testing.T) {
+	b := NewBuilder(analysis.NewSimple())
+	b.Add("doc1", map[string]any{"title": "hello"})
+
+	first := b.Freeze()
+	if b.Freeze() != first {
+		t.Error("expected cached frozen view without modifications")
+	}
+
+	b.Add("doc2", map[string]any{"title": "world"})
+	if b.Freeze() == first {
+		t.Error("expected new frozen view after modification")
 	}
 }
@@ -2,13 +2,10 @@ package segment
 
 import (
 	"bytes"
-	"encoding/binary"
-	"encoding/json"
 	"os"
 	"sort"
 
 	"github.com/couchbase/vellum"
-	"github.com/golang/snappy"
 )
 
 // writeStoredFields writes chunked, compressed stored documents.
@@ -20,31 +17,12 @@ func (b *Builder) writeStoredFields(file *os.File) ([]uint64, error) {
 		if end > len(b.Docs) {
 			end = len(b.Docs)
 		}
-		chunk := b.Docs[i:end]
 
-		// Serialize chunk
-		chunkData, err := json.Marshal(chunk)
+		offset, err := writeStoredChunk(file, b.Docs[i:end])
 		if err != nil {
 			return nil, err
 		}
-
-		// Compress with snappy
-		compressed := snappy.Encode(nil, chunkData)
-
-		// Record offset
-		offset, err := file.Seek(0, 1)
-		if err != nil {
-			return nil, err
-		}
-		chunkOffsets = append(chunkOffsets, uint64(offset))
-
-		// Write length + compressed data
-		if err := binary.Write(file, binary.BigEndian, uint32(len(compressed))); err != nil {
-			return nil, err
-		}
-		if _, err := file.Write(compressed); err != nil {
-			return nil, err
-		}
+		chunkOffsets = append(chunkOffsets, offset)
 	}
 
 	return chunkOffsets, nil
@@ -75,7 +53,7 @@ func (b *Builder) writeFieldsIndex(file *os.File) ([]FieldMeta, error) {
 
 // writeFieldIndex writes FST and postings for a single field.
 func (b *Builder) writeFieldIndex(file *os.File, fieldName string, terms map[string][]Posting) (FieldMeta, error) {
-	meta := FieldMeta{Name: fieldName}
+	meta := FieldMeta{Name: fieldName, Offsets: b.mapping.OffsetsFor(fieldName)}
 
 	// Get sorted terms
 	termList := make([]string, 0, len(terms))
@@ -92,16 +70,20 @@ func (b *Builder) writeFieldIndex(file *os.File, fieldName string, terms map[str
 	for _, term := range termList {
 		postings := terms[term]
 
-		// Sort postings by docNum
-		sort.Slice(postings, func(i, j int) bool {
+		// Sort postings by docNum. They are appended in docNum order, so
+		// this only writes (and races with frozen views) when out of order.
+		byDocNum := func(i, j int) bool {
 			return postings[i].DocNum < postings[j].DocNum
-		})
+		}
+		if !sort.SliceIsSorted(postings, byDocNum) {
+			sort.Slice(postings, byDocNum)
+		}
 
 		offset, _ := file.Seek(0, 1)
 		relOffset := uint64(offset) - meta.PostingsOffset
 
 		termOffsets[term] = relOffset
-		encoded := EncodePostings(postings)
+		encoded := EncodePostings(postings, meta.Offsets)
 		if _, err := file.Write(encoded); err != nil {
 			return meta, err
 		}
@@ -130,8 +112,9 @@ func (b *Builder) writeFieldIndex(file *os.File, fieldName string, terms map[str
 	}
 
 	// Write FST size and data
-	binary.Write(file, binary.BigEndian, uint64(fstBuf.Len()))
-	file.Write(fstBuf.Bytes())
+	if err := writeDict(file, fstBuf.Bytes()); err != nil {
+		return meta, err
+	}
 
 	dictEnd, _ := file.Seek(0, 1)
 	meta.DictSize = uint64(dictEnd) - meta.DictOffset
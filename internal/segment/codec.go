@@ -18,6 +18,15 @@ type Posting struct {
 	DocNum    uint64
 	Frequency uint64
 	Positions []uint64
+	Offsets   []Offset // parallel to Positions; only for fields indexed with offsets
+}
+
+// Offset is the byte range of a term occurrence in its field's text. For a
+// multi-valued field, offsets run on across the values as if they were
+// joined by one separator byte.
+type Offset struct {
+	Start uint64
+	End   uint64
 }
 
 type Footer struct {
@@ -38,10 +47,13 @@ type FieldMeta struct {
 	PostingsSize   uint64 `json:"postings_size"`
 	TotalTokens    uint64 `json:"total_tokens,omitempty"`
 	DocCount       uint64 `json:"doc_count,omitempty"`
+	Offsets        bool   `json:"offsets,omitempty"` // postings include offsets
 }
 
-// EncodePostings encodes a posting list with delta encoding.
-func EncodePostings(postings []Posting) []byte {
+// EncodePostings encodes a posting list with delta encoding. With
+// withOffsets, each position's offsets follow the positions section;
+// positions without an offset are written as empty ranges.
+func EncodePostings(postings []Posting, withOffsets bool) []byte {
 	buf := make([]byte, 0, len(postings)*32)
 	tmp := make([]byte, binary.MaxVarintLen64)
 
@@ -74,11 +86,29 @@ func EncodePostings(postings []Posting) []byte {
 		}
 	}
 
+	if withOffsets {
+		for _, p := range postings {
+			var prevStart uint64
+			for j := range p.Positions {
+				var off Offset
+				if j < len(p.Offsets) {
+					off = p.Offsets[j]
+				}
+				n = binary.PutVarint(tmp, int64(off.Start-prevStart))
+				buf = append(buf, tmp[:n]...)
+				n = binary.PutUvarint(tmp, off.End-off.Start)
+				buf = append(buf, tmp[:n]...)
+				prevStart = off.Start
+			}
+		}
+	}
+
 	return buf
 }
 
-// DecodePostings decodes a posting list.
-func DecodePostings(data []byte) ([]Posting, error) {
+// DecodePostings decodes a posting list. withOffsets must match how the list
+// was encoded.
+func DecodePostings(data []byte, withOffsets bool) ([]Posting, error) {
 	r := bytes.NewReader(data)
 
 	count, err := binary.ReadUvarint(r)
@@ -124,6 +154,26 @@ func DecodePostings(data []byte) ([]Posting, error) {
 		}
 	}
 
+	if withOffsets {
+		for i := range postings {
+			postings[i].Offsets = make([]Offset, len(postings[i].Positions))
+			var prevStart uint64
+			for j := range postings[i].Offsets {
+				delta, err := binary.ReadVarint(r)
+				if err != nil {
+					return nil, err
+				}
+				length, err := binary.ReadUvarint(r)
+				if err != nil {
+					return nil, err
+				}
+				start := prevStart + uint64(delta)
+				postings[i].Offsets[j] = Offset{Start: start, End: start + length}
+				prevStart = start
+			}
+		}
+	}
+
 	return postings, nil
 }
 
@@ -8,8 +8,8 @@ import (
 )
 
 func TestEncodeDecodePostings_Empty(t *testing.T) {
-	encoded := EncodePostings([]Posting{})
-	decoded, err := DecodePostings(encoded)
+	encoded := EncodePostings([]Posting{}, false)
+	decoded, err := DecodePostings(encoded, false)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -25,8 +25,8 @@ func TestEncodeDecodePostings_DeltaEncoding(t *testing.T) {
 		{DocNum: 1001, Frequency: 1, Positions: []uint64{0}},
 		{DocNum: 2000, Frequency: 1, Positions: []uint64{0}},
 	}
-	encoded := EncodePostings(postings)
-	decoded, err := DecodePostings(encoded)
+	encoded := EncodePostings(postings, false)
+	decoded, err := DecodePostings(encoded, false)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -39,8 +39,8 @@ func TestEncodeDecodePostings_FrequencyAndPositions(t *testing.T) {
 	postings := []Posting{
 		{DocNum: 0, Frequency: 3, Positions: []uint64{0, 5, 10}},
 	}
-	encoded := EncodePostings(postings)
-	decoded, err := DecodePostings(encoded)
+	encoded := EncodePostings(postings, false)
+	decoded, err := DecodePostings(encoded, false)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -52,13 +52,37 @@ func TestEncodeDecodePostings_FrequencyAndPositions(t *testing.T) {
 	}
 }
 
+func TestEncodeDecodePostings_Offsets(t *testing.T) {
+	postings := []Posting{
+		{DocNum: 0, Frequency: 2, Positions: []uint64{0, 3}, Offsets: []Offset{{0, 5}, {20, 26}}},
+		{DocNum: 4, Frequency: 1, Positions: []uint64{1}, Offsets: []Offset{{6, 11}}},
+	}
+	encoded := EncodePostings(postings, true)
+	decoded, err := DecodePostings(encoded, true)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, postings) {
+		t.Errorf("got %+v, want %+v", decoded, postings)
+	}
+
+	// The bitmap decoder ignores the trailing offsets section.
+	bm, err := DecodePostingsBitmap(encoded, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if !bm.Contains(0) || !bm.Contains(4) || bm.GetCardinality() != 2 {
+		t.Errorf("unexpected bitmap: %v", bm.ToArray())
+	}
+}
+
 func TestDecodePostingsBitmap_AllDocs(t *testing.T) {
 	postings := []Posting{
 		{DocNum: 1, Frequency: 1, Positions: []uint64{0}},
 		{DocNum: 5, Frequency: 1, Positions: []uint64{0}},
 		{DocNum: 10, Frequency: 1, Positions: []uint64{0}},
 	}
-	encoded := EncodePostings(postings)
+	encoded := EncodePostings(postings, false)
 
 	bm, err := DecodePostingsBitmap(encoded, nil)
 	if err != nil {
@@ -78,7 +102,7 @@ func TestDecodePostingsBitmap_ExcludesDeleted(t *testing.T) {
 		{DocNum: 5, Frequency: 1, Positions: []uint64{0}},
 		{DocNum: 10, Frequency: 1, Positions: []uint64{0}},
 	}
-	encoded := EncodePostings(postings)
+	encoded := EncodePostings(postings, false)
 	deleted := newTestBitmap(5)
 
 	bm, err := DecodePostingsBitmap(encoded, deleted)
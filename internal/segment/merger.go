@@ -0,0 +1,317 @@
+package segment
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"sort"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/couchbase/vellum"
+)
+
+// DocDropped marks a document that was not carried into a merged segment.
+const DocDropped = ^uint64(0)
+
+// Merge writes a new segment containing the live documents of segs without
+// re-analyzing them: per-field dictionaries are merged in term order,
+// posting lists are concatenated with remapped docNums, and field lengths
+// and stored documents are copied across.
+//
+// deleted[i] holds the deleted docNums of segs[i] (may be nil). The returned
+// docMaps[i][oldDocNum] is the document's docNum in the merged segment, or
+// DocDropped if it was deleted.
+func Merge(segs []*Segment, deleted []*roaring.Bitmap, dir, segmentID string) (string, [][]uint64, error) {
+	m := newMerger(segs, deleted)
+
+	segPath, err := writeSegment(dir, segmentID, segmentContents{
+		numDocs:      m.numDocs,
+		docIDs:       m.docIDs,
+		fieldLengths: m.fieldLengths,
+		writeStored:  m.writeStoredFields,
+		writeFields:  m.writeFieldsIndex,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return segPath, m.docMaps, nil
+}
+
+// merger holds the docNum remapping shared by the stages of a merge.
+type merger struct {
+	segs         []*Segment
+	deleted      []*roaring.Bitmap
+	docMaps      [][]uint64
+	numDocs      uint64
+	docIDs       []string
+	fieldLengths map[string][]uint64
+}
+
+func newMerger(segs []*Segment, deleted []*roaring.Bitmap) *merger {
+	m := &merger{
+		segs:         segs,
+		deleted:      make([]*roaring.Bitmap, len(segs)),
+		docMaps:      make([][]uint64, len(segs)),
+		fieldLengths: make(map[string][]uint64),
+	}
+	copy(m.deleted, deleted)
+
+	// Assign new docNums in segment order, skipping deleted documents.
+	for i, seg := range segs {
+		docMap := make([]uint64, seg.NumDocs())
+		for docNum := range docMap {
+			if m.deleted[i] != nil && m.deleted[i].Contains(uint32(docNum)) {
+				docMap[docNum] = DocDropped
+				continue
+			}
+			docMap[docNum] = m.numDocs
+			extID, _ := seg.ExternalID(uint64(docNum))
+			m.docIDs = append(m.docIDs, extID)
+			m.numDocs++
+		}
+		m.docMaps[i] = docMap
+	}
+
+	for i, seg := range segs {
+		for field, lengths := range seg.footer.FieldLengths {
+			merged := m.fieldLengths[field]
+			if merged == nil {
+				merged = make([]uint64, m.numDocs)
+				m.fieldLengths[field] = merged
+			}
+			for docNum, l := range lengths {
+				if newDocNum := m.docMaps[i][docNum]; newDocNum != DocDropped {
+					merged[newDocNum] = l
+				}
+			}
+		}
+	}
+
+	return m
+}
+
+// writeStoredFields copies stored documents into new chunks. Full chunks
+// with no deletions that line up with the output are copied still compressed.
+func (m *merger) writeStoredFields(file *os.File) ([]uint64, error) {
+	var chunkOffsets []uint64
+	chunk := make([]json.RawMessage, 0, ChunkSize)
+
+	flush := func() error {
+		offset, err := writeStoredChunk(file, chunk)
+		if err != nil {
+			return err
+		}
+		chunkOffsets = append(chunkOffsets, offset)
+		chunk = chunk[:0]
+		return nil
+	}
+
+	for i, seg := range m.segs {
+		for c := range seg.footer.ChunkOffsets {
+			start := uint64(c) * ChunkSize
+			end := min(start+ChunkSize, seg.NumDocs())
+
+			if len(chunk) == 0 && end-start == ChunkSize && !m.hasDeletions(i, start, end) {
+				offset, err := file.Seek(0, 1)
+				if err != nil {
+					return nil, err
+				}
+				if _, err := file.Write(seg.rawChunk(c)); err != nil {
+					return nil, err
+				}
+				chunkOffsets = append(chunkOffsets, uint64(offset))
+				continue
+			}
+
+			docs, err := seg.loadChunk(c)
+			if err != nil {
+				return nil, err
+			}
+			for j, doc := range docs {
+				if m.docMaps[i][start+uint64(j)] == DocDropped {
+					continue
+				}
+				chunk = append(chunk, doc)
+				if len(chunk) == ChunkSize {
+					if err := flush(); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+
+	if len(chunk) > 0 {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	return chunkOffsets, nil
+}
+
+// hasDeletions reports whether any docNum in [start, end) of segment i is deleted.
+func (m *merger) hasDeletions(i int, start, end uint64) bool {
+	deleted := m.deleted[i]
+	if deleted == nil || deleted.IsEmpty() {
+		return false
+	}
+	count := deleted.Rank(uint32(end - 1))
+	if start > 0 {
+		count -= deleted.Rank(uint32(start - 1))
+	}
+	return count > 0
+}
+
+// writeFieldsIndex merges the dictionaries and postings of every field.
+func (m *merger) writeFieldsIndex(file *os.File) ([]FieldMeta, error) {
+	fieldSet := make(map[string]bool)
+	for _, seg := range m.segs {
+		for _, field := range seg.Fields() {
+			fieldSet[field] = true
+		}
+	}
+
+	fieldNames := make([]string, 0, len(fieldSet))
+	for name := range fieldSet {
+		fieldNames = append(fieldNames, name)
+	}
+	sort.Strings(fieldNames)
+
+	var fieldsMeta []FieldMeta
+	for _, fieldName := range fieldNames {
+		meta, err := m.writeFieldIndex(file, fieldName)
+		if err != nil {
+			return nil, err
+		}
+		fieldsMeta = append(fieldsMeta, meta)
+	}
+
+	return fieldsMeta, nil
+}
+
+// termCursor walks one segment's dictionary for a field.
+type termCursor struct {
+	seg  int
+	iter *vellum.FSTIterator
+	meta *FieldMeta
+}
+
+// writeFieldIndex streams the field's dictionaries in term order, writing the
+// concatenated postings of each term and building the merged FST.
+func (m *merger) writeFieldIndex(file *os.File, fieldName string) (FieldMeta, error) {
+	meta := FieldMeta{Name: fieldName}
+
+	var cursors []*termCursor
+	for i, seg := range m.segs {
+		fst, err := seg.getFST(fieldName)
+		if err != nil {
+			continue // field not present in this segment
+		}
+		iter, err := fst.Iterator(nil, nil)
+		if err == vellum.ErrIteratorDone {
+			continue
+		}
+		if err != nil {
+			return meta, err
+		}
+		cursors = append(cursors, &termCursor{seg: i, iter: iter, meta: seg.getFieldMeta(fieldName)})
+	}
+
+	// Offsets are kept only if every input has them.
+	meta.Offsets = len(cursors) > 0
+	for _, c := range cursors {
+		meta.Offsets = meta.Offsets && c.meta.Offsets
+	}
+
+	postingsStart, _ := file.Seek(0, 1)
+	meta.PostingsOffset = uint64(postingsStart)
+
+	var fstBuf bytes.Buffer
+	fstBuilder, err := vellum.New(&fstBuf, nil)
+	if err != nil {
+		return meta, err
+	}
+
+	for len(cursors) > 0 {
+		term, _ := cursors[0].iter.Current()
+		for _, c := range cursors[1:] {
+			if key, _ := c.iter.Current(); bytes.Compare(key, term) < 0 {
+				term = key
+			}
+		}
+		// Keys are only valid until the iterator advances.
+		term = bytes.Clone(term)
+
+		var postings []Posting
+		next := cursors[:0]
+		for _, c := range cursors {
+			key, val := c.iter.Current()
+			if bytes.Equal(key, term) {
+				seg := m.segs[c.seg]
+				decoded, err := decodePostings(seg.data[c.meta.PostingsOffset+val:], c.meta)
+				if err != nil {
+					return meta, err
+				}
+				for _, p := range decoded {
+					newDocNum := m.docMaps[c.seg][p.DocNum]
+					if newDocNum == DocDropped {
+						continue
+					}
+					p.DocNum = newDocNum
+					postings = append(postings, p)
+				}
+
+				if err := c.iter.Next(); err != nil {
+					if err != vellum.ErrIteratorDone {
+						return meta, err
+					}
+					continue
+				}
+			}
+			next = append(next, c)
+		}
+		cursors = next
+
+		// Every document containing the term was deleted.
+		if len(postings) == 0 {
+			continue
+		}
+
+		offset, _ := file.Seek(0, 1)
+		if _, err := file.Write(EncodePostings(postings, meta.Offsets)); err != nil {
+			return meta, err
+		}
+		if err := fstBuilder.Insert(term, uint64(offset)-meta.PostingsOffset); err != nil {
+			return meta, err
+		}
+	}
+
+	postingsEnd, _ := file.Seek(0, 1)
+	meta.PostingsSize = uint64(postingsEnd) - meta.PostingsOffset
+
+	if err := fstBuilder.Close(); err != nil {
+		return meta, err
+	}
+
+	dictStart, _ := file.Seek(0, 1)
+	meta.DictOffset = uint64(dictStart)
+
+	if err := writeDict(file, fstBuf.Bytes()); err != nil {
+		return meta, err
+	}
+
+	dictEnd, _ := file.Seek(0, 1)
+	meta.DictSize = uint64(dictEnd) - meta.DictOffset
+
+	return meta, nil
+}
+
+// rawChunk returns a stored fields chunk as written: length prefix plus
+// compressed data.
+func (s *Segment) rawChunk(chunkIdx int) []byte {
+	offset := s.footer.ChunkOffsets[chunkIdx]
+	chunkLen := binary.BigEndian.Uint32(s.data[offset:])
+	return s.data[offset : offset+4+uint64(chunkLen)]
+}
@@ -0,0 +1,188 @@
+package segment
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring"
+
+	"harshagw/postings/internal/analysis"
+	"harshagw/postings/internal/mapping"
+)
+
+// buildOrdered builds a segment with documents added in the given order.
+func buildOrdered(t *testing.T, dir, id string, ids []string, docs []map[string]any) *Segment {
+	t.Helper()
+	b := NewBuilder(analysis.NewSimple())
+	for i, docID := range ids {
+		b.Add(docID, docs[i])
+	}
+	path, err := b.Build(dir, id)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	seg, err := Open(path, id)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	return seg
+}
+
+func TestMerge_CombinesSegments(t *testing.T) {
+	dir := t.TempDir()
+	seg1 := buildOrdered(t, dir, "a", []string{"doc1", "doc2"}, []map[string]any{
+		{"title": "hello world", "year": 2020},
+		{"title": "goodbye world"},
+	})
+	defer seg1.Close()
+	seg2 := buildOrdered(t, dir, "b", []string{"doc3"}, []map[string]any{
+		{"title": "hello hello there", "body": "extra"},
+	})
+	defer seg2.Close()
+
+	path, docMaps, err := Merge([]*Segment{seg1, seg2}, []*roaring.Bitmap{newTestBitmap(1), nil}, dir, "merged")
+	if err != nil {
+		t.Fatalf("Merge error: %v", err)
+	}
+	merged, err := Open(path, "merged")
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer merged.Close()
+
+	if merged.NumDocs() != 2 {
+		t.Fatalf("expected 2 docs, got %d", merged.NumDocs())
+	}
+	if !slices.Equal(docMaps[0], []uint64{0, DocDropped}) || !slices.Equal(docMaps[1], []uint64{1}) {
+		t.Errorf("unexpected doc maps: %v", docMaps)
+	}
+
+	postings, _ := merged.Search("hello", "title", nil)
+	if len(postings) != 2 || postings[0].DocNum != 0 || postings[1].DocNum != 1 {
+		t.Fatalf("unexpected 'hello' postings: %+v", postings)
+	}
+	if postings[1].Frequency != 2 || !slices.Equal(postings[1].Positions, []uint64{0, 1}) {
+		t.Errorf("positions not preserved: %+v", postings[1])
+	}
+
+	// Terms only in deleted documents are dropped.
+	if postings, _ := merged.Search("goodbye", "title", nil); len(postings) != 0 {
+		t.Errorf("expected deleted doc's term to be dropped, got %+v", postings)
+	}
+
+	if docNum, ok := merged.DocNum("doc3"); !ok || docNum != 1 {
+		t.Errorf("DocNum(doc3) = %d, %v", docNum, ok)
+	}
+	if _, ok := merged.DocNum("doc2"); ok {
+		t.Error("deleted doc2 should not be in merged segment")
+	}
+
+	if merged.FieldLength("title", 1) != 3 {
+		t.Errorf("field length: got %d, want 3", merged.FieldLength("title", 1))
+	}
+	if avg := merged.AvgFieldLength("title"); avg != 2.5 {
+		t.Errorf("avg field length: got %f, want 2.5", avg)
+	}
+
+	doc, err := merged.LoadDoc(0)
+	if err != nil {
+		t.Fatalf("LoadDoc error: %v", err)
+	}
+	if doc["title"] != "hello world" || doc["year"] != float64(2020) {
+		t.Errorf("unexpected stored doc: %v", doc)
+	}
+}
+
+func TestMerge_CopiesAlignedChunks(t *testing.T) {
+	dir := t.TempDir()
+
+	n := ChunkSize + 10
+	ids := make([]string, n)
+	docs := make([]map[string]any, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("doc%d", i)
+		docs[i] = map[string]any{"title": fmt.Sprintf("item %d", i)}
+	}
+	seg1 := buildOrdered(t, dir, "a", ids, docs)
+	defer seg1.Close()
+	seg2 := buildOrdered(t, dir, "b", []string{"last"}, []map[string]any{{"title": "last item"}})
+	defer seg2.Close()
+
+	// Delete from the partial chunk only; the first chunk is copied as-is.
+	path, _, err := Merge([]*Segment{seg1, seg2}, []*roaring.Bitmap{newTestBitmap(uint32(ChunkSize + 1)), nil}, dir, "merged")
+	if err != nil {
+		t.Fatalf("Merge error: %v", err)
+	}
+	merged, err := Open(path, "merged")
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer merged.Close()
+
+	if merged.NumDocs() != uint64(n) {
+		t.Fatalf("expected %d docs, got %d", n, merged.NumDocs())
+	}
+
+	for _, tc := range []struct {
+		docNum uint64
+		title  string
+	}{
+		{0, "item 0"},
+		{ChunkSize - 1, fmt.Sprintf("item %d", ChunkSize-1)},
+		{ChunkSize + 1, fmt.Sprintf("item %d", ChunkSize+2)},
+		{uint64(n - 1), "last item"},
+	} {
+		doc, err := merged.LoadDoc(tc.docNum)
+		if err != nil {
+			t.Fatalf("LoadDoc(%d) error: %v", tc.docNum, err)
+		}
+		if doc["title"] != tc.title {
+			t.Errorf("LoadDoc(%d): got %v, want %q", tc.docNum, doc["title"], tc.title)
+		}
+	}
+}
+
+func TestMerge_PreservesOffsets(t *testing.T) {
+	dir := t.TempDir()
+	m := mapping.New(analysis.NewSimple())
+	m.Fields = map[string]mapping.FieldMapping{"body": {Offsets: true}}
+
+	var segs []*Segment
+	for i, text := range []string{"hello world", "say hello"} {
+		b := NewBuilderWithMapping(m)
+		b.Add(fmt.Sprintf("doc%d", i), map[string]any{"body": text})
+		id := fmt.Sprintf("s%d", i)
+		path, err := b.Build(dir, id)
+		if err != nil {
+			t.Fatalf("Build error: %v", err)
+		}
+		seg, err := Open(path, id)
+		if err != nil {
+			t.Fatalf("Open error: %v", err)
+		}
+		defer seg.Close()
+		segs = append(segs, seg)
+	}
+
+	path, _, err := Merge(segs, []*roaring.Bitmap{nil, nil}, dir, "merged")
+	if err != nil {
+		t.Fatalf("Merge error: %v", err)
+	}
+	merged, err := Open(path, "merged")
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer merged.Close()
+
+	if !merged.HasOffsets("body") {
+		t.Fatal("expected merged body field to keep offsets")
+	}
+	postings, _ := merged.Search("hello", "body", nil)
+	if len(postings) != 2 {
+		t.Fatalf("unexpected 'hello' postings: %+v", postings)
+	}
+	if !slices.Equal(postings[0].Offsets, []Offset{{0, 5}}) || !slices.Equal(postings[1].Offsets, []Offset{{4, 9}}) {
+		t.Errorf("offsets not preserved: %+v", postings)
+	}
+}
@@ -0,0 +1,145 @@
+package segment
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/couchbase/vellum"
+)
+
+// numericTermPrefix starts every numeric term. No analyzer emits it, so
+// numeric terms sort before and never collide with text terms in a field.
+const numericTermPrefix = 0x00
+
+// numericTermLen is the prefix byte plus the 8-byte sortable value.
+const numericTermLen = 9
+
+// EncodeNumeric returns the term for v. Terms sort in the same order as
+// their values: the sign bit is flipped for positives and all bits for
+// negatives, then the value is written big-endian.
+func EncodeNumeric(v float64) string {
+	if v == 0 {
+		v = 0 // fold -0 into 0
+	}
+	bits := math.Float64bits(v)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+
+	var buf [numericTermLen]byte
+	buf[0] = numericTermPrefix
+	binary.BigEndian.PutUint64(buf[1:], bits)
+	return string(buf[:])
+}
+
+// DecodeNumeric returns the value of a numeric term.
+func DecodeNumeric(term string) (float64, bool) {
+	if !IsNumericTerm(term) {
+		return 0, false
+	}
+	bits := binary.BigEndian.Uint64([]byte(term[1:]))
+	if bits&(1<<63) != 0 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits), true
+}
+
+// IsNumericTerm reports whether term was produced by EncodeNumeric.
+func IsNumericTerm(term string) bool {
+	return len(term) == numericTermLen && term[0] == numericTermPrefix
+}
+
+// NumericTermRange returns the [start, end) term range covering values
+// between min and max. Use ±Inf for open bounds.
+func NumericTermRange(min, max float64, minInclusive, maxInclusive bool) (start, end []byte) {
+	start = []byte(EncodeNumeric(min))
+	if !minInclusive {
+		start = append(start, 0)
+	}
+	end = []byte(EncodeNumeric(max))
+	if maxInclusive {
+		end = append(end, 0)
+	}
+	return start, end
+}
+
+// numericValue converts a JSON-decoded or Go numeric value to float64.
+func numericValue(value any) (float64, bool) {
+	var v float64
+	switch n := value.(type) {
+	case float64:
+		v = n
+	case float32:
+		v = float64(n)
+	case int:
+		v = float64(n)
+	case int8:
+		v = float64(n)
+	case int16:
+		v = float64(n)
+	case int32:
+		v = float64(n)
+	case int64:
+		v = float64(n)
+	case uint:
+		v = float64(n)
+	case uint8:
+		v = float64(n)
+	case uint16:
+		v = float64(n)
+	case uint32:
+		v = float64(n)
+	case uint64:
+		v = float64(n)
+	case json.Number:
+		f, err := n.Float64()
+		if err != nil {
+			return 0, false
+		}
+		v = f
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		if err != nil {
+			return 0, false
+		}
+		v = f
+	default:
+		return 0, false
+	}
+	if math.IsNaN(v) {
+		return 0, false
+	}
+	return v, true
+}
+
+// TermRangeBitmap returns the docs containing any term in [start, end).
+func (s *Segment) TermRangeBitmap(fieldName string, start, end []byte, deleted *roaring.Bitmap) (*roaring.Bitmap, error) {
+	fst, err := s.getFST(fieldName)
+	if err != nil {
+		return nil, err
+	}
+	meta := s.getFieldMeta(fieldName)
+
+	result := roaring.New()
+	iter, err := fst.Iterator(start, end)
+	for err == nil {
+		_, val := iter.Current()
+		bm, decodeErr := DecodePostingsBitmap(s.data[meta.PostingsOffset+val:], deleted)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		result.Or(bm)
+		err = iter.Next()
+	}
+	if err != vellum.ErrIteratorDone {
+		return nil, err
+	}
+	return result, nil
+}
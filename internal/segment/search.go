@@ -68,7 +68,7 @@ func (s *Segment) Search(term, fieldName string, deleted *roaring.Bitmap) ([]Pos
 
 	meta := s.getFieldMeta(fieldName)
 	postingsOffset := meta.PostingsOffset + val
-	postings, err := decodePostings(s.data[postingsOffset:])
+	postings, err := decodePostings(s.data[postingsOffset:], meta)
 	if err != nil {
 		return nil, err
 	}
@@ -87,6 +87,19 @@ func (s *Segment) Search(term, fieldName string, deleted *roaring.Bitmap) ([]Pos
 	return postings, nil
 }
 
+// TermStats returns the number of documents containing term in a field and
+// the term's total frequency in them, excluding deleted documents.
+func (s *Segment) TermStats(term, fieldName string, deleted *roaring.Bitmap) (TermStats, error) {
+	if s.getFieldMeta(fieldName) == nil {
+		return TermStats{}, nil
+	}
+	postings, err := s.Search(term, fieldName, deleted)
+	if err != nil {
+		return TermStats{}, err
+	}
+	return termStats(postings, nil), nil
+}
+
 // SearchBitmap returns just the docNum bitmap for a term (no freq/positions).
 func (s *Segment) SearchBitmap(term, fieldName string, deleted *roaring.Bitmap) (*roaring.Bitmap, error) {
 	fst, err := s.getFST(fieldName)
@@ -107,9 +120,15 @@ func (s *Segment) SearchBitmap(term, fieldName string, deleted *roaring.Bitmap)
 	return DecodePostingsBitmap(s.data[postingsOffset:], deleted)
 }
 
-// decodePostings decodes a posting list from segment data.
-func decodePostings(data []byte) ([]Posting, error) {
-	return DecodePostings(data)
+// decodePostings decodes a posting list of the field described by meta.
+func decodePostings(data []byte, meta *FieldMeta) ([]Posting, error) {
+	return DecodePostings(data, meta.Offsets)
+}
+
+// HasOffsets reports whether a field's postings include term offsets.
+func (s *Segment) HasOffsets(fieldName string) bool {
+	meta := s.getFieldMeta(fieldName)
+	return meta != nil && meta.Offsets
 }
 
 // searchWithAutomaton is a helper that searches FST using any vellum automaton.
@@ -162,6 +181,30 @@ func (s *Segment) FuzzyTerms(term string, fuzziness uint8, fieldName string) ([]
 	return s.searchWithAutomaton(fieldName, aut)
 }
 
+// PrefixTerms returns all terms in a field that start with prefix.
+func (s *Segment) PrefixTerms(prefix, fieldName string) ([]string, error) {
+	fst, err := s.getFST(fieldName)
+	if err != nil {
+		return nil, err
+	}
+
+	start := []byte(prefix)
+	iter, err := fst.Iterator(start, prefixSuccessor(start))
+
+	var terms []string
+	for err == nil {
+		key, _ := iter.Current()
+		terms = append(terms, string(key))
+		err = iter.Next()
+	}
+
+	if err != vellum.ErrIteratorDone {
+		return nil, fmt.Errorf("failed to iterate prefix: %w", err)
+	}
+
+	return terms, nil
+}
+
 // PrefixPostings returns all postings for terms matching the prefix.
 // More efficient than PrefixTerms + multiple Search calls.
 func (s *Segment) PrefixPostings(prefix, fieldName string, deleted *roaring.Bitmap) ([]Posting, error) {
@@ -189,7 +232,7 @@ func (s *Segment) PrefixPostings(prefix, fieldName string, deleted *roaring.Bitm
 		_, val := iter.Current()
 
 		postingsOffset := meta.PostingsOffset + val
-		postings, decodeErr := decodePostings(s.data[postingsOffset:])
+		postings, decodeErr := decodePostings(s.data[postingsOffset:], meta)
 		if decodeErr == nil {
 			for _, p := range postings {
 				if deleted != nil && deleted.Contains(uint32(p.DocNum)) {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 
 	"github.com/RoaringBitmap/roaring"
 	"github.com/couchbase/vellum"
@@ -25,6 +26,9 @@ type Segment struct {
 
 	fsts   map[string]*vellum.FST
 	fstsMu sync.RWMutex
+
+	refs     atomic.Int64
+	obsolete atomic.Bool
 }
 
 // Open opens an existing segment file with mmap.
@@ -77,7 +81,7 @@ func Open(path, segmentID string) (*Segment, error) {
 		fieldMetaByName[footer.FieldsMeta[i].Name] = &footer.FieldsMeta[i]
 	}
 
-	return &Segment{
+	seg := &Segment{
 		id:              segmentID,
 		path:            path,
 		file:            file,
@@ -85,7 +89,46 @@ func Open(path, segmentID string) (*Segment, error) {
 		footer:          footer,
 		fieldMetaByName: fieldMetaByName,
 		fsts:            make(map[string]*vellum.FST),
-	}, nil
+	}
+	seg.refs.Store(1)
+	return seg, nil
+}
+
+// AddRef takes an additional reference on the segment. Every AddRef must be
+// paired with a DecRef.
+func (s *Segment) AddRef() {
+	s.refs.Add(1)
+}
+
+// DecRef releases a reference. When the last reference is released the
+// segment is closed, and its file is removed if it was marked obsolete.
+func (s *Segment) DecRef() error {
+	refs := s.refs.Add(-1)
+	if refs > 0 {
+		return nil
+	}
+	if refs < 0 {
+		return fmt.Errorf("segment %s: reference count below zero", s.id)
+	}
+
+	err := s.Close()
+	if s.obsolete.Load() {
+		if rmErr := os.Remove(s.path); rmErr != nil && err == nil {
+			err = rmErr
+		}
+	}
+	return err
+}
+
+// MarkObsolete flags the segment file for removal once the last reference
+// is released.
+func (s *Segment) MarkObsolete() {
+	s.obsolete.Store(true)
+}
+
+// Refs returns the current reference count.
+func (s *Segment) Refs() int64 {
+	return s.refs.Load()
 }
 
 // ID returns the segment ID.
@@ -131,7 +174,7 @@ func (s *Segment) DocNum(externalID string) (uint64, bool) {
 
 	meta := s.getFieldMeta(IDField)
 	postingsOffset := meta.PostingsOffset + val
-	postings, err := decodePostings(s.data[postingsOffset:])
+	postings, err := decodePostings(s.data[postingsOffset:], meta)
 	if err != nil || len(postings) == 0 {
 		return 0, false
 	}
@@ -160,7 +203,7 @@ func (s *Segment) DocNumbers(externalIDs []string) *roaring.Bitmap {
 		}
 
 		postingsOffset := meta.PostingsOffset + val
-		postings, err := decodePostings(s.data[postingsOffset:])
+		postings, err := decodePostings(s.data[postingsOffset:], meta)
 		if err == nil && len(postings) > 0 {
 			bm.Add(uint32(postings[0].DocNum))
 		}
@@ -197,6 +240,19 @@ func (s *Segment) AvgFieldLength(field string) float64 {
 	return float64(meta.TotalTokens) / float64(meta.DocCount)
 }
 
+// FieldStats returns the number of documents with a value in field and
+// their total length, excluding deleted documents.
+func (s *Segment) FieldStats(field string, deleted *roaring.Bitmap) FieldStats {
+	var stats FieldStats
+	for docNum, l := range s.footer.FieldLengths[field] {
+		if l > 0 && (deleted == nil || !deleted.Contains(uint32(docNum))) {
+			stats.DocCount++
+			stats.SumTotalTermFreq += l
+		}
+	}
+	return stats
+}
+
 // LoadDoc loads a document by docNum from stored fields.
 func (s *Segment) LoadDoc(docNum uint64) (map[string]any, error) {
 	if docNum >= s.footer.NumDocs {
@@ -204,8 +260,27 @@ func (s *Segment) LoadDoc(docNum uint64) (map[string]any, error) {
 	}
 
 	// Find the chunk containing this document
-	chunkIdx := docNum / ChunkSize
-	if int(chunkIdx) >= len(s.footer.ChunkOffsets) {
+	chunk, err := s.loadChunk(int(docNum / ChunkSize))
+	if err != nil {
+		return nil, err
+	}
+
+	// Return the specific document
+	docInChunk := docNum % ChunkSize
+	if int(docInChunk) >= len(chunk) {
+		return nil, fmt.Errorf("document index out of range in chunk")
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(chunk[docInChunk], &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse document: %w", err)
+	}
+	return doc, nil
+}
+
+// loadChunk decompresses a stored fields chunk into its raw documents.
+func (s *Segment) loadChunk(chunkIdx int) ([]json.RawMessage, error) {
+	if chunkIdx >= len(s.footer.ChunkOffsets) {
 		return nil, fmt.Errorf("chunk index out of range")
 	}
 
@@ -222,18 +297,12 @@ func (s *Segment) LoadDoc(docNum uint64) (map[string]any, error) {
 	}
 
 	// Parse chunk
-	var chunk []map[string]any
+	var chunk []json.RawMessage
 	if err := json.Unmarshal(decompressed, &chunk); err != nil {
 		return nil, fmt.Errorf("failed to parse chunk: %w", err)
 	}
 
-	// Return the specific document
-	docInChunk := docNum % ChunkSize
-	if int(docInChunk) >= len(chunk) {
-		return nil, fmt.Errorf("document index out of range in chunk")
-	}
-
-	return chunk[docInChunk], nil
+	return chunk, nil
 }
 
 // Close releases segment resources.
@@ -248,9 +317,12 @@ func (s *Segment) Close() error {
 
 	if s.data != nil {
 		s.data.Unmap()
+		s.data = nil
 	}
 	if s.file != nil {
-		return s.file.Close()
+		err := s.file.Close()
+		s.file = nil
+		return err
 	}
 	return nil
 }
@@ -1,6 +1,7 @@
 package segment
 
 import (
+	"os"
 	"slices"
 	"testing"
 
@@ -262,6 +263,30 @@ func TestSegment_AvgFieldLength_ComputesAverage(t *testing.T) {
 	}
 }
 
+func TestSegment_TermAndFieldStats_ExcludeDeleted(t *testing.T) {
+	seg := makeSegment(t, map[string]map[string]any{
+		"doc1": {"title": "go go"},
+		"doc2": {"title": "go fast now"},
+		"doc3": {"body": "go"},
+	})
+	defer seg.Close()
+
+	deleted := seg.DocNumbers([]string{"doc2"})
+	stats, err := seg.TermStats("go", "title", deleted)
+	if err != nil {
+		t.Fatalf("TermStats error: %v", err)
+	}
+	if want := (TermStats{DocFreq: 1, TotalTermFreq: 2}); stats != want {
+		t.Errorf("TermStats: got %+v, want %+v", stats, want)
+	}
+	if got, want := seg.FieldStats("title", deleted), (FieldStats{DocCount: 1, SumTotalTermFreq: 2}); got != want {
+		t.Errorf("FieldStats: got %+v, want %+v", got, want)
+	}
+	if stats, err := seg.TermStats("go", "missing", nil); err != nil || stats != (TermStats{}) {
+		t.Errorf("TermStats on missing field: got %+v, %v", stats, err)
+	}
+}
+
 func TestSegment_Search_FrequencyAndPositions(t *testing.T) {
 	seg := makeSegment(t, map[string]map[string]any{
 		"doc1": {"title": "go is go and go"}, // "go" at positions 0, 2, 4
@@ -314,3 +339,26 @@ func TestSegment_Search_NonExistentField(t *testing.T) {
 		t.Error("expected error for non-existent field")
 	}
 }
+
+func TestSegment_DecRef_RemovesObsoleteFile(t *testing.T) {
+	seg := makeSegment(t, map[string]map[string]any{
+		"doc1": {"title": "hello"},
+	})
+
+	seg.AddRef()
+	seg.MarkObsolete()
+
+	if err := seg.DecRef(); err != nil {
+		t.Fatalf("DecRef error: %v", err)
+	}
+	if _, err := os.Stat(seg.Path()); err != nil {
+		t.Fatalf("file removed while still referenced: %v", err)
+	}
+
+	if err := seg.DecRef(); err != nil {
+		t.Fatalf("DecRef error: %v", err)
+	}
+	if _, err := os.Stat(seg.Path()); !os.IsNotExist(err) {
+		t.Errorf("expected segment file to be removed after last DecRef")
+	}
+}
@@ -0,0 +1,48 @@
+package segment
+
+import "github.com/RoaringBitmap/roaring"
+
+// TermStats are the statistics of one term in one field.
+type TermStats struct {
+	DocFreq       uint64 // documents containing the term
+	TotalTermFreq uint64 // occurrences of the term across those documents
+}
+
+// Add accumulates other into s.
+func (s *TermStats) Add(other TermStats) {
+	s.DocFreq += other.DocFreq
+	s.TotalTermFreq += other.TotalTermFreq
+}
+
+// FieldStats are the statistics of one field.
+type FieldStats struct {
+	DocCount         uint64 // documents with at least one token in the field
+	SumTotalTermFreq uint64 // field length summed over those documents
+}
+
+// Add accumulates other into s.
+func (s *FieldStats) Add(other FieldStats) {
+	s.DocCount += other.DocCount
+	s.SumTotalTermFreq += other.SumTotalTermFreq
+}
+
+// AvgLength returns the average field length, or 0 if no document has the field.
+func (s FieldStats) AvgLength() float64 {
+	if s.DocCount == 0 {
+		return 0
+	}
+	return float64(s.SumTotalTermFreq) / float64(s.DocCount)
+}
+
+// termStats sums postings, skipping documents in deleted (may be nil).
+func termStats(postings []Posting, deleted *roaring.Bitmap) TermStats {
+	var stats TermStats
+	for _, p := range postings {
+		if deleted != nil && deleted.Contains(uint32(p.DocNum)) {
+			continue
+		}
+		stats.DocFreq++
+		stats.TotalTermFreq += p.Frequency
+	}
+	return stats
+}
@@ -0,0 +1,153 @@
+package segment
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/golang/snappy"
+)
+
+// segmentContents supplies the sections of a segment file to writeSegment.
+type segmentContents struct {
+	numDocs      uint64
+	docIDs       []string
+	fieldLengths map[string][]uint64
+	deleted      *roaring.Bitmap // excluded from field stats (may be nil)
+	writeStored  func(file *os.File) ([]uint64, error)
+	writeFields  func(file *os.File) ([]FieldMeta, error)
+}
+
+// writeSegment writes a segment file and returns its path. The file is
+// written to a temporary path and renamed into place once complete.
+func writeSegment(dir, segmentID string, c segmentContents) (string, error) {
+	segPath := filepath.Join(dir, segmentID+".seg")
+	tmpPath := segPath + ".tmp"
+
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	// Write header
+	if _, err := file.WriteString(SegmentMagic); err != nil {
+		return "", err
+	}
+	if err := binary.Write(file, binary.BigEndian, SegmentVersion); err != nil {
+		return "", err
+	}
+	if err := binary.Write(file, binary.BigEndian, c.numDocs); err != nil {
+		return "", err
+	}
+
+	// Reserve space for offsets
+	offsetsPos, _ := file.Seek(0, 1)
+	placeholder := make([]byte, 16)
+	file.Write(placeholder)
+
+	// Write stored fields
+	storedFieldsOffset, _ := file.Seek(0, 1)
+	chunkOffsets, err := c.writeStored(file)
+	if err != nil {
+		return "", err
+	}
+
+	// Write fields index
+	fieldsIndexOffset, _ := file.Seek(0, 1)
+	fieldsMeta, err := c.writeFields(file)
+	if err != nil {
+		return "", err
+	}
+
+	// Compute field stats for BM25 (excluding deleted docs)
+	for i := range fieldsMeta {
+		field := fieldsMeta[i].Name
+		if lengths, ok := c.fieldLengths[field]; ok {
+			var total uint64
+			var count uint64
+			for docNum, l := range lengths {
+				if l > 0 && (c.deleted == nil || !c.deleted.Contains(uint32(docNum))) {
+					total += l
+					count++
+				}
+			}
+			fieldsMeta[i].TotalTokens = total
+			fieldsMeta[i].DocCount = count
+		}
+	}
+
+	footerOffset, _ := file.Seek(0, 1)
+	footer := Footer{
+		StoredFieldsOffset: uint64(storedFieldsOffset),
+		FieldsIndexOffset:  uint64(fieldsIndexOffset),
+		ChunkOffsets:       chunkOffsets,
+		FieldsMeta:         fieldsMeta,
+		DocIDs:             c.docIDs,
+		NumDocs:            c.numDocs,
+		FieldLengths:       c.fieldLengths,
+	}
+	footerData, err := json.Marshal(footer)
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Write(footerData); err != nil {
+		return "", err
+	}
+
+	binary.Write(file, binary.BigEndian, uint64(footerOffset))
+	binary.Write(file, binary.BigEndian, uint64(len(footerData)))
+
+	// Go back and write the actual offsets
+	file.Seek(offsetsPos, 0)
+	binary.Write(file, binary.BigEndian, uint64(storedFieldsOffset))
+	binary.Write(file, binary.BigEndian, uint64(fieldsIndexOffset))
+
+	file.Close()
+
+	if err := os.Rename(tmpPath, segPath); err != nil {
+		return "", err
+	}
+
+	return segPath, nil
+}
+
+// writeStoredChunk serializes and compresses a chunk of stored documents
+// and returns the offset it was written at.
+func writeStoredChunk(file *os.File, chunk any) (uint64, error) {
+	// Serialize chunk
+	chunkData, err := json.Marshal(chunk)
+	if err != nil {
+		return 0, err
+	}
+
+	// Compress with snappy
+	compressed := snappy.Encode(nil, chunkData)
+
+	// Record offset
+	offset, err := file.Seek(0, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	// Write length + compressed data
+	if err := binary.Write(file, binary.BigEndian, uint32(len(compressed))); err != nil {
+		return 0, err
+	}
+	if _, err := file.Write(compressed); err != nil {
+		return 0, err
+	}
+
+	return uint64(offset), nil
+}
+
+// writeDict writes a serialized FST dictionary prefixed by its size.
+func writeDict(file *os.File, fst []byte) error {
+	if err := binary.Write(file, binary.BigEndian, uint64(len(fst))); err != nil {
+		return err
+	}
+	_, err := file.Write(fst)
+	return err
+}
@@ -0,0 +1,157 @@
+package similarity
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Factory builds a similarity from string options.
+type Factory func(options map[string]string) (Similarity, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		"bm25": func(options map[string]string) (Similarity, error) {
+			s := NewBM25()
+			if err := floatOptions(options, map[string]option{"k1": atLeast(&s.K1, 0), "b": between(&s.B, 0, 1)}); err != nil {
+				return nil, err
+			}
+			return s, nil
+		},
+		"bm25f": func(options map[string]string) (Similarity, error) {
+			weights, err := weightsOption(options["weights"])
+			if err != nil {
+				return nil, err
+			}
+			s := NewBM25F(weights)
+			rest := maps.Clone(options)
+			delete(rest, "weights")
+			if err := floatOptions(rest, map[string]option{"k1": atLeast(&s.K1, 0), "b": between(&s.B, 0, 1)}); err != nil {
+				return nil, err
+			}
+			return s, nil
+		},
+		"tfidf": func(options map[string]string) (Similarity, error) {
+			if err := floatOptions(options, nil); err != nil {
+				return nil, err
+			}
+			return TFIDF{}, nil
+		},
+		"dfr": func(options map[string]string) (Similarity, error) {
+			s := NewDFR()
+			if err := floatOptions(options, map[string]option{"c": above(&s.C, 0)}); err != nil {
+				return nil, err
+			}
+			return s, nil
+		},
+		"lm_dirichlet": func(options map[string]string) (Similarity, error) {
+			s := NewLMDirichlet()
+			if err := floatOptions(options, map[string]option{"mu": above(&s.Mu, 0)}); err != nil {
+				return nil, err
+			}
+			return s, nil
+		},
+		"constant": func(options map[string]string) (Similarity, error) {
+			s := &Constant{Value: 1}
+			if err := floatOptions(options, map[string]option{"score": atLeast(&s.Value, 0)}); err != nil {
+				return nil, err
+			}
+			return s, nil
+		},
+	}
+)
+
+// option is a numeric option, where it is stored and the values it takes.
+type option struct {
+	target *float64
+	valid  func(float64) bool
+	want   string // the valid values, for errors
+}
+
+func atLeast(target *float64, min float64) option {
+	return option{target, func(v float64) bool { return v >= min }, fmt.Sprintf(">= %g", min)}
+}
+
+func above(target *float64, min float64) option {
+	return option{target, func(v float64) bool { return v > min }, fmt.Sprintf("> %g", min)}
+}
+
+func between(target *float64, min, max float64) option {
+	return option{target, func(v float64) bool { return v >= min && v <= max }, fmt.Sprintf("between %g and %g", min, max)}
+}
+
+// floatOptions parses the options named in targets into them. Other options
+// and values out of an option's range are an error.
+func floatOptions(options map[string]string, targets map[string]option) error {
+	for key, value := range options {
+		opt, ok := targets[key]
+		if !ok {
+			return fmt.Errorf("unknown option %q", key)
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsInf(v, 0) || !opt.valid(v) {
+			return fmt.Errorf("invalid %s option %q: want a number %s", key, value, opt.want)
+		}
+		*opt.target = v
+	}
+	return nil
+}
+
+// weightsOption parses field weights written as "title:3,body:1". Weights
+// must be finite and not negative.
+func weightsOption(s string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		field, value, ok := strings.Cut(part, ":")
+		w, err := strconv.ParseFloat(value, 64)
+		if !ok || err != nil || math.IsNaN(w) || math.IsInf(w, 0) || w < 0 {
+			return nil, fmt.Errorf("invalid weights option %q", s)
+		}
+		weights[strings.TrimSpace(field)] = w
+	}
+	return weights, nil
+}
+
+// Register makes a similarity available by name, replacing any existing one.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// Build creates the named similarity with the given options.
+func Build(name string, options map[string]string) (Similarity, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown similarity: %s", name)
+	}
+
+	s, err := factory(options)
+	if err != nil {
+		return nil, fmt.Errorf("similarity %s: %w", name, err)
+	}
+	return s, nil
+}
+
+// Names returns the registered similarity names in sorted order.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
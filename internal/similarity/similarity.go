@@ -0,0 +1,325 @@
+// Package similarity scores term matches from a document's term frequency
+// and field length and the collection's term and field statistics.
+package similarity
+
+import (
+	"fmt"
+	"math"
+)
+
+// Stats are the statistics a term match in one field is scored with.
+type Stats struct {
+	Field            string
+	DocFreq          float64 // documents containing the term in the field
+	TotalTermFreq    float64 // occurrences of the term in the field
+	DocCount         float64 // documents with the field
+	SumTotalTermFreq float64 // field length summed over those documents
+	Freq             float64 // occurrences in the document; a phrase counts once
+	FieldLength      float64 // length of the field in the document, 0 if unknown
+}
+
+// AvgFieldLength returns the average length of the field, or 1 if unknown.
+func (s Stats) AvgFieldLength() float64 {
+	if s.DocCount == 0 || s.SumTotalTermFreq == 0 {
+		return 1
+	}
+	return s.SumTotalTermFreq / s.DocCount
+}
+
+// length returns the document's field length, or the average if unknown.
+func (s Stats) length() float64 {
+	if s.FieldLength == 0 {
+		return s.AvgFieldLength()
+	}
+	return s.FieldLength
+}
+
+// docCount returns the number of documents with the field, which is at
+// least the number containing the term.
+func (s Stats) docCount() float64 {
+	return max(s.DocCount, s.DocFreq)
+}
+
+// Similarity scores a term match. A phrase scores the sum over its terms,
+// each with the phrase's frequency.
+type Similarity interface {
+	Score(stats Stats) float64
+	// Explain returns Score broken down into its factors.
+	Explain(stats Stats) *Explanation
+	String() string
+}
+
+// MultiFieldSimilarity is a Similarity that can score the matches of a term
+// in several fields of a document as one match.
+type MultiFieldSimilarity interface {
+	Similarity
+	ScoreFields(fields []Stats) float64
+	ExplainFields(fields []Stats) *Explanation
+}
+
+// Explanation is a node in the breakdown of a score: Value is computed from
+// Details as stated in Description.
+type Explanation struct {
+	Value       float64
+	Description string
+	Details     []*Explanation
+}
+
+func explain(value float64, description string, details ...*Explanation) *Explanation {
+	return &Explanation{Value: value, Description: description, Details: details}
+}
+
+// BM25 is Okapi BM25. K1 controls term frequency saturation and B how much
+// the field length normalizes the frequency.
+type BM25 struct {
+	K1 float64
+	B  float64
+}
+
+// NewBM25 returns BM25 with the usual k1 = 1.2 and b = 0.75.
+func NewBM25() *BM25 {
+	return &BM25{K1: 1.2, B: 0.75}
+}
+
+func (s *BM25) Score(stats Stats) float64 {
+	return bm25IDF(stats.DocFreq, stats.docCount()) * s.tf(stats)
+}
+
+func (s *BM25) tf(stats Stats) float64 {
+	return stats.Freq * (s.K1 + 1) / (stats.Freq + s.K1*(1-s.B+s.B*stats.length()/stats.AvgFieldLength()))
+}
+
+func (s *BM25) Explain(stats Stats) *Explanation {
+	return explain(s.Score(stats), "computed as idf * tf from:",
+		explainBM25IDF(stats.DocFreq, stats.docCount()),
+		explain(s.tf(stats), "tf, computed as freq * (k1 + 1) / (freq + k1 * (1 - b + b * dl / avgdl)) from:",
+			explain(stats.Freq, "freq, occurrences of the term in the field"),
+			explain(s.K1, "k1, term saturation"),
+			explain(s.B, "b, length normalization"),
+			explain(stats.length(), "dl, length of the field"),
+			explain(stats.AvgFieldLength(), "avgdl, average length of the field"),
+		),
+	)
+}
+
+func (s *BM25) String() string {
+	return fmt.Sprintf("BM25(k1=%g, b=%g)", s.K1, s.B)
+}
+
+func bm25IDF(df, n float64) float64 {
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+func explainBM25IDF(df, n float64) *Explanation {
+	return explain(bm25IDF(df, n), "idf, computed as log(1 + (N - df + 0.5) / (df + 0.5)) from:",
+		explain(df, "df, documents containing the term in the field"),
+		explain(n, "N, documents with the field"),
+	)
+}
+
+// BM25F scores a term's matches in several fields as one match of a single
+// field: each field's frequency is normalized by its length with B and
+// multiplied by the field's weight, and the weighted sum is saturated once
+// with K1. The IDF uses the largest document frequency and document count of
+// the fields. In a single field with weight 1 it equals BM25.
+type BM25F struct {
+	K1      float64
+	B       float64
+	Weights map[string]float64 // field -> weight; unlisted fields weigh 1
+}
+
+// NewBM25F returns BM25F with k1 = 1.2, b = 0.75 and the given weights.
+func NewBM25F(weights map[string]float64) *BM25F {
+	return &BM25F{K1: 1.2, B: 0.75, Weights: weights}
+}
+
+func (s *BM25F) weight(field string) float64 {
+	if w, ok := s.Weights[field]; ok {
+		return w
+	}
+	return 1
+}
+
+func (s *BM25F) Score(stats Stats) float64 {
+	return s.ScoreFields([]Stats{stats})
+}
+
+func (s *BM25F) Explain(stats Stats) *Explanation {
+	return s.ExplainFields([]Stats{stats})
+}
+
+func (s *BM25F) ScoreFields(fields []Stats) float64 {
+	df, n := s.collection(fields)
+	tf := s.tf(fields)
+	return bm25IDF(df, n) * tf * (s.K1 + 1) / (tf + s.K1)
+}
+
+// collection returns the document frequency and count the IDF uses.
+func (s *BM25F) collection(fields []Stats) (df, n float64) {
+	for _, stats := range fields {
+		df = max(df, stats.DocFreq)
+		n = max(n, stats.docCount())
+	}
+	return df, n
+}
+
+// tf returns the weighted sum of the length-normalized frequencies.
+func (s *BM25F) tf(fields []Stats) float64 {
+	var tf float64
+	for _, stats := range fields {
+		tf += s.fieldTF(stats)
+	}
+	return tf
+}
+
+func (s *BM25F) fieldTF(stats Stats) float64 {
+	return s.weight(stats.Field) * stats.Freq / (1 - s.B + s.B*stats.length()/stats.AvgFieldLength())
+}
+
+func (s *BM25F) ExplainFields(fields []Stats) *Explanation {
+	df, n := s.collection(fields)
+	tf := explain(s.tf(fields), "tf, sum over fields of weight * freq / (1 - b + b * dl / avgdl):")
+	for _, stats := range fields {
+		tf.Details = append(tf.Details, explain(s.fieldTF(stats), fmt.Sprintf("field %s:", stats.Field),
+			explain(s.weight(stats.Field), "weight"),
+			explain(stats.Freq, "freq, occurrences of the term in the field"),
+			explain(s.B, "b, length normalization"),
+			explain(stats.length(), "dl, length of the field"),
+			explain(stats.AvgFieldLength(), "avgdl, average length of the field"),
+		))
+	}
+	return explain(s.ScoreFields(fields), "computed as idf * tf * (k1 + 1) / (tf + k1) from:",
+		explainBM25IDF(df, n),
+		tf,
+		explain(s.K1, "k1, term saturation"),
+	)
+}
+
+func (s *BM25F) String() string {
+	return fmt.Sprintf("BM25F(k1=%g, b=%g)", s.K1, s.B)
+}
+
+// TFIDF is classic TF-IDF with a logarithmic term frequency and a smoothed
+// IDF, without length normalization.
+type TFIDF struct{}
+
+func (TFIDF) Score(stats Stats) float64 {
+	return tfidfTF(stats.Freq) * tfidfIDF(stats.DocFreq, stats.docCount())
+}
+
+func (TFIDF) Explain(stats Stats) *Explanation {
+	df, n := stats.DocFreq, stats.docCount()
+	return explain(TFIDF{}.Score(stats), "computed as tf * idf from:",
+		explain(tfidfTF(stats.Freq), "tf, computed as 1 + log(freq) from:",
+			explain(stats.Freq, "freq, occurrences of the term in the field"),
+		),
+		explain(tfidfIDF(df, n), "idf, computed as log((N + 1) / (df + 1)) + 1 from:",
+			explain(df, "df, documents containing the term in the field"),
+			explain(n, "N, documents with the field"),
+		),
+	)
+}
+
+func (TFIDF) String() string { return "TF-IDF" }
+
+func tfidfTF(freq float64) float64 {
+	if freq > 0 {
+		return 1 + math.Log(freq)
+	}
+	return 0
+}
+
+func tfidfIDF(df, n float64) float64 {
+	return math.Log((n+1)/(df+1)) + 1
+}
+
+// DFR is divergence from randomness with the In basic model, the Laplace
+// after-effect and H2 length normalization. C scales the normalization.
+type DFR struct {
+	C float64
+}
+
+// NewDFR returns DFR with c = 1.
+func NewDFR() *DFR {
+	return &DFR{C: 1}
+}
+
+func (s *DFR) Score(stats Stats) float64 {
+	tfn := s.tfn(stats)
+	return tfn * math.Log2((stats.docCount()+1)/(stats.DocFreq+0.5)) / (1 + tfn)
+}
+
+// tfn is the H2-normalized term frequency.
+func (s *DFR) tfn(stats Stats) float64 {
+	return stats.Freq * math.Log2(1+s.C*stats.AvgFieldLength()/stats.length())
+}
+
+func (s *DFR) Explain(stats Stats) *Explanation {
+	return explain(s.Score(stats), "computed as tfn * log2((N + 1) / (df + 0.5)) / (1 + tfn) from:",
+		explain(s.tfn(stats), "tfn, computed as freq * log2(1 + c * avgdl / dl) from:",
+			explain(stats.Freq, "freq, occurrences of the term in the field"),
+			explain(s.C, "c, normalization"),
+			explain(stats.length(), "dl, length of the field"),
+			explain(stats.AvgFieldLength(), "avgdl, average length of the field"),
+		),
+		explain(stats.DocFreq, "df, documents containing the term in the field"),
+		explain(stats.docCount(), "N, documents with the field"),
+	)
+}
+
+func (s *DFR) String() string {
+	return fmt.Sprintf("DFR(In, L, H2, c=%g)", s.C)
+}
+
+// LMDirichlet is a language model with Dirichlet smoothing: the document's
+// term probability is smoothed towards the term's probability in the field
+// over all documents, with Mu pseudo-occurrences. Scores below zero are 0.
+type LMDirichlet struct {
+	Mu float64
+}
+
+// NewLMDirichlet returns LMDirichlet with mu = 2000.
+func NewLMDirichlet() *LMDirichlet {
+	return &LMDirichlet{Mu: 2000}
+}
+
+func (s *LMDirichlet) Score(stats Stats) float64 {
+	score := math.Log(1+stats.Freq/(s.Mu*s.collectionProbability(stats))) + math.Log(s.Mu/(stats.length()+s.Mu))
+	return max(score, 0)
+}
+
+// collectionProbability is the smoothed probability of the term in the field.
+func (s *LMDirichlet) collectionProbability(stats Stats) float64 {
+	return (stats.TotalTermFreq + 1) / (stats.SumTotalTermFreq + 1)
+}
+
+func (s *LMDirichlet) Explain(stats Stats) *Explanation {
+	return explain(s.Score(stats), "computed as max(0, log(1 + freq / (mu * p)) + log(mu / (dl + mu))) from:",
+		explain(stats.Freq, "freq, occurrences of the term in the field"),
+		explain(s.Mu, "mu, smoothing"),
+		explain(s.collectionProbability(stats), "p, computed as (ttf + 1) / (total length + 1) from:",
+			explain(stats.TotalTermFreq, "ttf, occurrences of the term in the field"),
+			explain(stats.SumTotalTermFreq, "total length of the field"),
+		),
+		explain(stats.length(), "dl, length of the field"),
+	)
+}
+
+func (s *LMDirichlet) String() string {
+	return fmt.Sprintf("LMDirichlet(mu=%g)", s.Mu)
+}
+
+// Constant gives every match the same score, e.g. for filter-like fields.
+type Constant struct {
+	Value float64
+}
+
+func (s *Constant) Score(Stats) float64 { return s.Value }
+
+func (s *Constant) Explain(Stats) *Explanation {
+	return explain(s.Value, "constant score")
+}
+
+func (s *Constant) String() string {
+	return fmt.Sprintf("Constant(%g)", s.Value)
+}
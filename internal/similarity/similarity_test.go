@@ -0,0 +1,131 @@
+package similarity
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestSimilarities_Formulas(t *testing.T) {
+	cases := []struct {
+		sim   Similarity
+		stats Stats
+		want  float64
+	}{
+		{NewBM25(), Stats{DocFreq: 1, DocCount: 2, SumTotalTermFreq: 2, Freq: 1, FieldLength: 1}, math.Log(2)},
+		{TFIDF{}, Stats{DocFreq: 1, DocCount: 1, Freq: math.E}, 2},
+		{NewDFR(), Stats{DocFreq: 1, DocCount: 2, SumTotalTermFreq: 2, Freq: 1, FieldLength: 1}, 0.5},
+		{NewLMDirichlet(), Stats{TotalTermFreq: 1, SumTotalTermFreq: 1999, Freq: 4, FieldLength: 2000}, math.Log(1.5)},
+		{NewLMDirichlet(), Stats{TotalTermFreq: 1, SumTotalTermFreq: 1999, Freq: 1, FieldLength: 2000}, 0},
+		{&Constant{Value: 3}, Stats{Freq: 5}, 3},
+	}
+	for _, c := range cases {
+		if got := c.sim.Score(c.stats); !approx(got, c.want) {
+			t.Errorf("%s: Score = %v, want %v", c.sim, got, c.want)
+		}
+		if got := c.sim.Explain(c.stats).Value; got != c.sim.Score(c.stats) {
+			t.Errorf("%s: Explain value %v differs from Score", c.sim, got)
+		}
+	}
+}
+
+func TestBM25_LongerFieldScoresLower(t *testing.T) {
+	s := NewBM25()
+	short := Stats{DocFreq: 2, DocCount: 10, SumTotalTermFreq: 50, Freq: 1, FieldLength: 2}
+	long := short
+	long.FieldLength = 20
+	if s.Score(short) <= s.Score(long) {
+		t.Errorf("expected the shorter field to score higher: %v <= %v", s.Score(short), s.Score(long))
+	}
+
+	s.B = 0
+	if s.Score(short) != s.Score(long) {
+		t.Errorf("with b = 0 length should not matter: %v != %v", s.Score(short), s.Score(long))
+	}
+}
+
+func TestBM25F_CombinesFields(t *testing.T) {
+	title := Stats{Field: "title", DocFreq: 2, DocCount: 10, SumTotalTermFreq: 30, Freq: 1, FieldLength: 3}
+	body := Stats{Field: "body", DocFreq: 2, DocCount: 10, SumTotalTermFreq: 300, Freq: 2, FieldLength: 25}
+
+	s := NewBM25F(nil)
+	if got, want := s.Score(title), NewBM25().Score(title); !approx(got, want) {
+		t.Errorf("single field: BM25F %v, BM25 %v", got, want)
+	}
+
+	both := s.ScoreFields([]Stats{title, body})
+	if both <= s.Score(title) || both <= s.Score(body) {
+		t.Errorf("matching both fields should beat either: %v", both)
+	}
+	if both >= s.Score(title)+s.Score(body) {
+		t.Errorf("frequencies should saturate across fields: %v", both)
+	}
+	if got := s.ExplainFields([]Stats{title, body}).Value; got != both {
+		t.Errorf("ExplainFields value %v differs from ScoreFields %v", got, both)
+	}
+
+	doubled := title
+	doubled.Freq = 2
+	weighted := NewBM25F(map[string]float64{"title": 2})
+	if got, want := weighted.Score(title), s.Score(doubled); !approx(got, want) {
+		t.Errorf("weight 2 should count like twice the frequency: %v, want %v", got, want)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	s, err := Build("bm25f", map[string]string{"weights": "title:3, body:0.5", "k1": "1"})
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	bm25f := s.(*BM25F)
+	if bm25f.K1 != 1 || bm25f.B != 0.75 || bm25f.Weights["title"] != 3 || bm25f.Weights["body"] != 0.5 {
+		t.Errorf("unexpected bm25f: %+v", bm25f)
+	}
+
+	for _, good := range []struct {
+		name    string
+		options map[string]string
+	}{
+		{"bm25", map[string]string{"k1": "0", "b": "0"}},
+		{"bm25", map[string]string{"b": "1"}},
+		{"lm_dirichlet", map[string]string{"mu": "0.5"}},
+	} {
+		if _, err := Build(good.name, good.options); err != nil {
+			t.Errorf("Build(%s, %v) error: %v", good.name, good.options, err)
+		}
+	}
+
+	for _, bad := range []struct {
+		name    string
+		options map[string]string
+	}{
+		{"nope", nil},
+		{"bm25", map[string]string{"k1": "x"}},
+		{"bm25", map[string]string{"b": "-1"}},
+		{"bm25", map[string]string{"b": "5"}},
+		{"bm25", map[string]string{"k1": "-0.5"}},
+		{"bm25", map[string]string{"k1": "NaN"}},
+		{"bm25f", map[string]string{"b": "1.5"}},
+		{"lm_dirichlet", map[string]string{"mu": "0"}},
+		{"dfr", map[string]string{"c": "0"}},
+		{"constant", map[string]string{"score": "Inf"}},
+		{"tfidf", map[string]string{"k1": "1"}},
+		{"bm25f", map[string]string{"weights": "title"}},
+		{"bm25f", map[string]string{"weights": "title:NaN"}},
+		{"bm25f", map[string]string{"weights": "title:Inf, body:1"}},
+		{"bm25f", map[string]string{"weights": "body:-Inf"}},
+	} {
+		if _, err := Build(bad.name, bad.options); err == nil {
+			t.Errorf("Build(%s, %v): expected error", bad.name, bad.options)
+		}
+	}
+
+	want := []string{"bm25", "bm25f", "constant", "dfr", "lm_dirichlet", "tfidf"}
+	if got := Names(); !slices.Equal(got, want) {
+		t.Errorf("Names = %v, want %v", got, want)
+	}
+}
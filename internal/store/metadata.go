@@ -17,6 +17,7 @@ var (
 	bucketMeta      = []byte("meta")
 	keySegmentList  = []byte("list")
 	keyEpoch        = []byte("epoch")
+	keyMapping      = []byte("mapping")
 )
 
 // DocMapping stores segment ID and docNum for an external ID.
@@ -114,6 +115,19 @@ func (m *Metadata) GetEpoch() (uint64, error) {
 	return epoch, err
 }
 
+// GetMapping returns the stored field mapping JSON, or nil if none was stored.
+func (m *Metadata) GetMapping() ([]byte, error) {
+	var mapping []byte
+	err := m.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketMeta)
+		if data := b.Get(keyMapping); data != nil {
+			mapping = bytes.Clone(data)
+		}
+		return nil
+	})
+	return mapping, err
+}
+
 func (m *Metadata) Close() error {
 	return m.db.Close()
 }
@@ -178,6 +192,12 @@ func (t *Tx) SetDocMapping(externalID, segmentID string, docNum uint64) error {
 	return b.Put([]byte(externalID), data)
 }
 
+// SetMapping stores the field mapping JSON.
+func (t *Tx) SetMapping(data []byte) error {
+	b := t.tx.Bucket(bucketMeta)
+	return b.Put(keyMapping, data)
+}
+
 // IncrementEpoch increments and returns the epoch.
 func (t *Tx) IncrementEpoch() (uint64, error) {
 	b := t.tx.Bucket(bucketMeta)